@@ -0,0 +1,419 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+)
+
+// Matrix is a symmetric matrix of pairwise values of traders, e.g. correlations.
+type Matrix struct {
+	UIDs   []string    `json:"uids"`   // Encrypted UIDs of the traders, in the order of the rows and columns
+	Values [][]float64 `json:"values"` // Values of every pair of traders
+}
+
+// At returns the value of the pair of traders, 0 if any of them isn't in the matrix.
+func (m Matrix) At(a, b string) float64 {
+	i, j := m.index(a), m.index(b)
+	if i < 0 || j < 0 {
+		return 0
+	}
+	return m.Values[i][j]
+}
+
+// index returns the index of the trader, -1 if they aren't in the matrix.
+func (m Matrix) index(uid string) int {
+	for i, u := range m.UIDs {
+		if u == uid {
+			return i
+		}
+	}
+	return -1
+}
+
+// String formats the matrix as a table, with UIDs shortened to their first 8 characters.
+func (m Matrix) String() string {
+	short := func(uid string) string {
+		if len(uid) > 8 {
+			return uid[:8]
+		}
+		return uid
+	}
+
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	for _, uid := range m.UIDs {
+		fmt.Fprintf(tw, "\t%s", short(uid))
+	}
+	fmt.Fprintln(tw, "\t")
+
+	for i, row := range m.Values {
+		fmt.Fprint(tw, short(m.UIDs[i]))
+		for _, v := range row {
+			fmt.Fprintf(tw, "\t%.2f", v)
+		}
+		fmt.Fprintln(tw, "\t")
+	}
+
+	tw.Flush()
+	return sb.String()
+}
+
+// Crowding is a symbol held in the same direction by many traders at once.
+type Crowding struct {
+	Symbol    string               `json:"symbol"`    // Symbol (e.g. BTCUSDT)
+	Direction bfldb.TradeDirection `json:"direction"` // Direction the traders hold the symbol in
+	UIDs      []string             `json:"uids"`      // Encrypted UIDs of the traders, sorted
+	Share     float64              `json:"share"`     // Share of all traders analyzed holding the symbol in the direction
+}
+
+// CorrelationReport is the result of a correlation analysis, see Correlate.
+type CorrelationReport struct {
+	From     time.Time  `json:"from"`     // Time of the first event analyzed
+	To       time.Time  `json:"to"`       // Time of the last event analyzed
+	Exposure Matrix     `json:"exposure"` // Pearson correlation of net exposures (signed notionals by symbol) over time
+	Timing   Matrix     `json:"timing"`   // Pearson correlation of positions opened or added to, by symbol and direction, over time
+	Clusters [][]string `json:"clusters"` // Groups of traders behaving alike, largest first, each of at least 2 traders
+	Crowded  []Crowding `json:"crowded"`  // Symbols held in the same direction by many traders as of the last event, most crowded first
+}
+
+// correlationConfig is the configuration of a correlation analysis.
+type correlationConfig struct {
+	interval  time.Duration // how often exposures are sampled and how long the windows positions are opened within are
+	threshold float64       // minimum correlation of traders clustered together
+	minCrowd  int           // minimum number of traders holding a symbol in the same direction for it to be crowded
+}
+
+type CorrelationOption func(*correlationConfig)
+
+// Correlate analyzes how alike traders behave from their recorded events, e.g. the ones recorded by the storage
+// package. Only PositionChanged and Snapshot events are used.
+//
+// Net exposures of the traders are sampled every interval, and their position changes are bucketed into windows of
+// the same length. Traders whose exposure or timing correlation reaches the threshold are clustered together,
+// along with all the traders they're clustered with. By default, the interval is an hour, the threshold is 0.7
+// and symbols are crowded once 3 traders hold them in the same direction.
+func Correlate(events []bfldb.Event, opts ...CorrelationOption) CorrelationReport {
+	cfg := correlationConfig{interval: time.Hour, threshold: 0.7, minCrowd: 3}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.interval <= 0 {
+		cfg.interval = time.Hour
+	}
+
+	evs := positionEvents(events)
+
+	var r CorrelationReport
+	if len(evs) == 0 {
+		return r
+	}
+	r.From, r.To = evs[0].Meta().Time, evs[len(evs)-1].Meta().Time
+
+	// index all traders and symbols
+	uids := make(map[string]int)
+	symbols := make(map[string]int)
+	for _, e := range evs {
+		uids[e.Meta().UID] = 0
+		for _, p := range eventPositions(e) {
+			symbols[p.Ticker] = 0
+		}
+	}
+	r.Exposure.UIDs = sortedKeys(uids)
+	for i, uid := range r.Exposure.UIDs {
+		uids[uid] = i
+	}
+	for i, s := range sortedKeys(symbols) {
+		symbols[s] = i
+	}
+	r.Timing.UIDs = r.Exposure.UIDs
+
+	// replay the events, sampling exposures before every event past the next sample
+	exposures := make([]map[string]float64, len(uids))
+	for i := range exposures {
+		exposures[i] = make(map[string]float64)
+	}
+	exposureVecs := make([]sparse, len(uids))
+	timingVecs := make([]sparse, len(uids))
+	for i := range exposureVecs {
+		exposureVecs[i] = make(sparse)
+		timingVecs[i] = make(sparse)
+	}
+
+	samples := 0
+	sample := func() {
+		for i, exp := range exposures {
+			for s, v := range exp {
+				exposureVecs[i][samples*len(symbols)+symbols[s]] = v
+			}
+		}
+		samples++
+	}
+
+	next := r.From
+	for _, e := range evs {
+		for !e.Meta().Time.Before(next) {
+			sample()
+			next = next.Add(cfg.interval)
+		}
+
+		i := uids[e.Meta().UID]
+		switch e := e.(type) {
+		case bfldb.Snapshot:
+			exposures[i] = make(map[string]float64, len(e.Positions))
+			for _, p := range e.Positions {
+				exposures[i][p.Ticker] = notional(p)
+			}
+
+		case bfldb.PositionChanged:
+			p := e.Position
+			if p.Type == bfldb.Closed {
+				delete(exposures[i], p.Ticker)
+			} else {
+				exposures[i][p.Ticker] = notional(p)
+			}
+
+			if p.Type == bfldb.Opened || p.Type == bfldb.AddedTo {
+				window := int(e.Time.Sub(r.From) / cfg.interval)
+				idx := (window*len(symbols)+symbols[p.Ticker])*2 + int(p.Direction-bfldb.Short)
+				timingVecs[i][idx]++
+			}
+		}
+	}
+	sample()
+
+	windows := int(r.To.Sub(r.From)/cfg.interval) + 1
+	r.Exposure.Values = correlations(exposureVecs, samples*len(symbols))
+	r.Timing.Values = correlations(timingVecs, windows*len(symbols)*2)
+
+	r.Clusters = cluster(r.Exposure.UIDs, func(i, j int) bool {
+		return r.Exposure.Values[i][j] >= cfg.threshold || r.Timing.Values[i][j] >= cfg.threshold
+	})
+	r.Crowded = crowded(r.Exposure.UIDs, exposures, cfg.minCrowd)
+
+	return r
+}
+
+// positionEvents returns the PositionChanged and Snapshot events, sorted by their time.
+func positionEvents(events []bfldb.Event) []bfldb.Event {
+	evs := make([]bfldb.Event, 0, len(events))
+	for _, e := range events {
+		switch e.(type) {
+		case bfldb.PositionChanged, bfldb.Snapshot:
+			evs = append(evs, e)
+		}
+	}
+
+	sort.SliceStable(evs, func(i, j int) bool {
+		return evs[i].Meta().Time.Before(evs[j].Meta().Time)
+	})
+
+	return evs
+}
+
+// eventPositions returns the positions of a PositionChanged or Snapshot event.
+func eventPositions(e bfldb.Event) []bfldb.Position {
+	switch e := e.(type) {
+	case bfldb.PositionChanged:
+		return []bfldb.Position{e.Position}
+	case bfldb.Snapshot:
+		return e.Positions
+	}
+	return nil
+}
+
+// notional returns the signed notional of the position, positive for long and negative for short.
+func notional(p bfldb.Position) float64 {
+	price := p.MarkPrice
+	if price == 0 {
+		price = p.EntryPrice
+	}
+
+	n := p.Amount * price
+	if p.Direction == bfldb.Short {
+		return -n
+	}
+	return n
+}
+
+// sparse is a sparse vector, with all missing values being 0.
+type sparse map[int]float64
+
+// pearson returns the Pearson correlation of two sparse vectors of n dimensions,
+// 0 if any of them doesn't vary.
+func pearson(a, b sparse, n int) float64 {
+	if n == 0 {
+		return 0
+	}
+
+	var sa, sb, saa, sbb, sab float64
+	for i, v := range a {
+		sa += v
+		saa += v * v
+		sab += v * b[i]
+	}
+	for _, v := range b {
+		sb += v
+		sbb += v * v
+	}
+
+	fn := float64(n)
+	cov := sab/fn - sa/fn*sb/fn
+	va := saa/fn - sa/fn*sa/fn
+	vb := sbb/fn - sb/fn*sb/fn
+	// allow for rounding errors of vectors that don't vary
+	if va <= 1e-12*saa/fn || vb <= 1e-12*sbb/fn {
+		return 0
+	}
+
+	return math.Max(-1, math.Min(1, cov/math.Sqrt(va*vb)))
+}
+
+// correlations returns the matrix of pairwise correlations of the vectors, all of n dimensions.
+// Every vector is perfectly correlated with itself.
+func correlations(vecs []sparse, n int) [][]float64 {
+	m := make([][]float64, len(vecs))
+	for i := range m {
+		m[i] = make([]float64, len(vecs))
+		m[i][i] = 1
+	}
+
+	for i := range vecs {
+		for j := i + 1; j < len(vecs); j++ {
+			c := pearson(vecs[i], vecs[j], n)
+			m[i][j], m[j][i] = c, c
+		}
+	}
+
+	return m
+}
+
+// cluster groups the traders alike, directly or through other traders, into clusters of at least 2 traders,
+// sorted from the largest one.
+func cluster(uids []string, alike func(i, j int) bool) [][]string {
+	parent := make([]int, len(uids))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range uids {
+		for j := i + 1; j < len(uids); j++ {
+			if alike(i, j) {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	groups := make(map[int][]string)
+	for i, uid := range uids {
+		root := find(i)
+		groups[root] = append(groups[root], uid)
+	}
+
+	var clusters [][]string
+	for _, g := range groups {
+		if len(g) > 1 {
+			clusters = append(clusters, g)
+		}
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i]) != len(clusters[j]) {
+			return len(clusters[i]) > len(clusters[j])
+		}
+		return clusters[i][0] < clusters[j][0]
+	})
+
+	return clusters
+}
+
+// crowded returns the symbols held in the same direction by at least min traders, most crowded first.
+func crowded(uids []string, exposures []map[string]float64, min int) []Crowding {
+	type side struct {
+		symbol    string
+		direction bfldb.TradeDirection
+	}
+
+	holders := make(map[side][]string)
+	for i, exp := range exposures {
+		for s, v := range exp {
+			k := side{symbol: s, direction: bfldb.Long}
+			if v < 0 {
+				k.direction = bfldb.Short
+			}
+			holders[k] = append(holders[k], uids[i])
+		}
+	}
+
+	var cs []Crowding
+	for k, hs := range holders {
+		if len(hs) < min {
+			continue
+		}
+
+		sort.Strings(hs)
+		cs = append(cs, Crowding{
+			Symbol:    k.symbol,
+			Direction: k.direction,
+			UIDs:      hs,
+			Share:     float64(len(hs)) / float64(len(uids)),
+		})
+	}
+
+	sort.Slice(cs, func(i, j int) bool {
+		if len(cs[i].UIDs) != len(cs[j].UIDs) {
+			return len(cs[i].UIDs) > len(cs[j].UIDs)
+		}
+		if cs[i].Symbol != cs[j].Symbol {
+			return cs[i].Symbol < cs[j].Symbol
+		}
+		return cs[i].Direction < cs[j].Direction
+	})
+
+	return cs
+}
+
+// sortedKeys returns the keys of the map, sorted.
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WithInterval sets how often exposures are sampled and how long the windows position changes are bucketed into are.
+func WithInterval(d time.Duration) CorrelationOption {
+	return func(c *correlationConfig) {
+		c.interval = d
+	}
+}
+
+// WithClusterThreshold sets the minimum correlation of traders clustered together.
+func WithClusterThreshold(t float64) CorrelationOption {
+	return func(c *correlationConfig) {
+		c.threshold = t
+	}
+}
+
+// WithMinCrowd sets how many traders have to hold a symbol in the same direction for it to be crowded.
+func WithMinCrowd(n int) CorrelationOption {
+	return func(c *correlationConfig) {
+		c.minCrowd = n
+	}
+}
@@ -0,0 +1,65 @@
+package analysis
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorrelate(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	var events []bfldb.Event
+	add := func(uid string, h int, typ bfldb.PositionType, dir bfldb.TradeDirection, symbol string, amount float64) {
+		events = append(events, bfldb.PositionChanged{
+			EventMeta: bfldb.EventMeta{UID: uid, Time: start.Add(time.Duration(h) * time.Hour)},
+			Position:  bfldb.Position{Type: typ, Direction: dir, Ticker: symbol, Amount: amount, MarkPrice: 10},
+		})
+	}
+
+	// A and B trade the same, C the opposite, D trades something else entirely
+	for _, uid := range []string{"A", "B"} {
+		add(uid, 1, bfldb.Opened, bfldb.Long, "BTCUSDT", 1)
+		add(uid, 3, bfldb.Closed, bfldb.Long, "BTCUSDT", 0)
+		add(uid, 5, bfldb.Opened, bfldb.Short, "ETHUSDT", 2)
+	}
+	add("C", 1, bfldb.Opened, bfldb.Short, "BTCUSDT", 1)
+	add("C", 3, bfldb.Closed, bfldb.Short, "BTCUSDT", 0)
+	add("C", 5, bfldb.Opened, bfldb.Long, "ETHUSDT", 2)
+	add("D", 2, bfldb.Opened, bfldb.Long, "SOLUSDT", 5)
+
+	// a snapshot shows D also holds an ETHUSDT short
+	events = append(events, bfldb.Snapshot{
+		EventMeta: bfldb.EventMeta{UID: "D", Time: start.Add(6 * time.Hour)},
+		Positions: []bfldb.Position{{Type: bfldb.Existing, Direction: bfldb.Short, Ticker: "ETHUSDT", Amount: 1, MarkPrice: 10}},
+	})
+
+	r := Correlate(events)
+	require.Equal(t, start.Add(time.Hour), r.From)
+	require.Equal(t, start.Add(6*time.Hour), r.To)
+	require.Equal(t, []string{"A", "B", "C", "D"}, r.Exposure.UIDs)
+
+	require.InDelta(t, 1, r.Exposure.At("A", "B"), 1e-9)
+	require.InDelta(t, -1, r.Exposure.At("A", "C"), 1e-9)
+	require.InDelta(t, 1, r.Timing.At("A", "B"), 1e-9)
+	require.Less(t, r.Timing.At("A", "C"), 0.0)
+	require.Less(t, r.Exposure.At("A", "D"), 0.7)
+	require.Equal(t, 1.0, r.Exposure.At("D", "D"))
+	require.Equal(t, 0.0, r.Exposure.At("A", "X"))
+
+	require.Equal(t, [][]string{{"A", "B"}}, r.Clusters)
+	require.Equal(t, []Crowding{{Symbol: "ETHUSDT", Direction: bfldb.Short, UIDs: []string{"A", "B", "D"}, Share: 0.75}}, r.Crowded)
+
+	r = Correlate(events, WithMinCrowd(4), WithClusterThreshold(-1))
+	require.Empty(t, r.Crowded)
+	require.Equal(t, [][]string{{"A", "B", "C", "D"}}, r.Clusters)
+
+	// the report is valid JSON, without any NaNs
+	_, err := json.Marshal(r)
+	require.NoError(t, err)
+
+	require.Contains(t, r.Exposure.String(), "  C  -1.00  -1.00   1.00")
+	require.Empty(t, Correlate(nil).Exposure.UIDs)
+}
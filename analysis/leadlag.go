@@ -0,0 +1,363 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+)
+
+// LeadLag is a lead-lag relationship between two traders, where the follower keeps opening the same positions
+// as the leader shortly after them.
+type LeadLag struct {
+	Leader    string        `json:"leader"`    // Encrypted UID of the trader opening positions first
+	Follower  string        `json:"follower"`  // Encrypted UID of the trader opening the same positions afterwards
+	Matches   int           `json:"matches"`   // Positions of the follower opened after the same position of the leader
+	Opens     int           `json:"opens"`     // All positions opened by the follower
+	Expected  float64       `json:"expected"`  // Matches expected by chance, if the traders were independent
+	PValue    float64       `json:"pValue"`    // Probability of at least as many matches by chance
+	Adjusted  float64       `json:"adjusted"`  // P-value corrected for the number of pairs of traders tested, see WithCorrection
+	MedianLag time.Duration `json:"medianLag"` // Median delay of the follower's positions after the leader's ones
+}
+
+// Correction is a correction of p-values for the number of pairs of traders tested, as the more pairs are tested,
+// the more of them match by chance.
+type Correction int
+
+const (
+	BenjaminiHochberg Correction = iota + 1 // Limits the expected share of relationships reported by chance to alpha
+	Bonferroni                              // Limits the probability of any relationship being reported by chance to alpha
+	NoCorrection                            // Compares p-values of every pair with alpha on its own
+)
+
+func (c Correction) String() string {
+	switch c {
+	default:
+		return ""
+	case BenjaminiHochberg:
+		return "benjamini-hochberg"
+	case Bonferroni:
+		return "bonferroni"
+	case NoCorrection:
+		return "none"
+	}
+}
+
+// Originator is a trader leading others without following anyone themselves.
+type Originator struct {
+	UID       string   `json:"uid"`       // Encrypted UID of the trader
+	Followers []string `json:"followers"` // Encrypted UIDs of the traders following them, sorted
+}
+
+// LeadLagReport is the result of a lead-lag analysis, see DetectLeadLag.
+type LeadLagReport struct {
+	From          time.Time     `json:"from"`          // Time of the first event analyzed
+	To            time.Time     `json:"to"`            // Time of the last event analyzed
+	MaxLag        time.Duration `json:"maxLag"`        // Maximum delay of a follower's position after the leader's one
+	Relationships []LeadLag     `json:"relationships"` // Significant relationships, the most significant first
+	Originators   []Originator  `json:"originators"`   // Likely originators, the most followed first
+}
+
+// leadLagConfig is the configuration of a lead-lag analysis.
+type leadLagConfig struct {
+	maxLag     time.Duration // maximum delay of a follower's position after the leader's one
+	alpha      float64       // maximum adjusted p-value of significant relationships
+	minMatches int           // minimum matches of significant relationships
+	correction Correction    // correction of p-values for the number of pairs tested
+}
+
+type LeadLagOption func(*leadLagConfig)
+
+// open is a single position opened by a trader.
+type open struct {
+	time time.Time            // when the position was opened
+	side bfldb.TradeDirection // direction of the position
+}
+
+// DetectLeadLag detects traders following other traders from their recorded events, e.g. the ones recorded by
+// the storage package. Only Opened positions of PositionChanged events are used.
+//
+// A position of a trader matches a position of another trader if it's opened with the same symbol and direction
+// within the maximum lag after it. The matches are compared with the ones expected by chance, given how much
+// of the time analyzed is covered by the leader's positions, and relationships whose p-value (the probability of
+// at least as many matches by chance, from a Poisson distribution), corrected for the number of pairs tested,
+// is at most alpha are reported. If both traders of a pair significantly lead each other, only the more
+// significant direction is reported.
+//
+// By default, the maximum lag is a minute, alpha is 0.01, at least 3 matches are required and p-values are
+// corrected with the Benjamini-Hochberg procedure.
+func DetectLeadLag(events []bfldb.Event, opts ...LeadLagOption) LeadLagReport {
+	cfg := leadLagConfig{maxLag: time.Minute, alpha: 0.01, minMatches: 3, correction: BenjaminiHochberg}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	r := LeadLagReport{MaxLag: cfg.maxLag}
+
+	// opens by trader and symbol
+	opens := make(map[string]map[string][]open)
+	for _, e := range events {
+		m := e.Meta()
+		if r.From.IsZero() || m.Time.Before(r.From) {
+			r.From = m.Time
+		}
+		if m.Time.After(r.To) {
+			r.To = m.Time
+		}
+
+		pc, ok := e.(bfldb.PositionChanged)
+		if !ok || pc.Position.Type != bfldb.Opened {
+			continue
+		}
+
+		if opens[m.UID] == nil {
+			opens[m.UID] = make(map[string][]open)
+		}
+		opens[m.UID][pc.Position.Ticker] = append(opens[m.UID][pc.Position.Ticker], open{time: m.Time, side: pc.Position.Direction})
+	}
+
+	span := r.To.Sub(r.From)
+	if span <= 0 || cfg.maxLag <= 0 {
+		return r
+	}
+
+	uids := make([]string, 0, len(opens))
+	for uid, bySymbol := range opens {
+		uids = append(uids, uid)
+		for _, ops := range bySymbol {
+			sort.Slice(ops, func(i, j int) bool { return ops[i].time.Before(ops[j].time) })
+		}
+	}
+	sort.Strings(uids)
+
+	var tested []LeadLag
+	for _, leader := range uids {
+		for _, follower := range uids {
+			if leader == follower {
+				continue
+			}
+
+			ll := leadLag(opens[leader], opens[follower], cfg.maxLag, r.To, span)
+			ll.Leader, ll.Follower = leader, follower
+			tested = append(tested, ll)
+		}
+	}
+	adjust(tested, cfg.correction)
+
+	significant := make(map[[2]string]LeadLag)
+	for _, ll := range tested {
+		if ll.Matches >= cfg.minMatches && ll.Adjusted <= cfg.alpha {
+			significant[[2]string{ll.Leader, ll.Follower}] = ll
+		}
+	}
+
+	for k, ll := range significant {
+		// traders leading each other are likely trading on the same signal, keep the stronger direction only
+		if rev, ok := significant[[2]string{k[1], k[0]}]; ok {
+			if rev.PValue < ll.PValue || (rev.PValue == ll.PValue && rev.Matches > ll.Matches) ||
+				(rev.PValue == ll.PValue && rev.Matches == ll.Matches && k[1] < k[0]) {
+				continue
+			}
+		}
+		r.Relationships = append(r.Relationships, ll)
+	}
+
+	sort.Slice(r.Relationships, func(i, j int) bool {
+		a, b := r.Relationships[i], r.Relationships[j]
+		if a.PValue != b.PValue {
+			return a.PValue < b.PValue
+		}
+		if a.Leader != b.Leader {
+			return a.Leader < b.Leader
+		}
+		return a.Follower < b.Follower
+	})
+
+	r.Originators = originators(r.Relationships)
+
+	return r
+}
+
+// leadLag counts the follower's opens matching the leader's ones. Opens are sorted by time for every symbol.
+func leadLag(leader, follower map[string][]open, maxLag time.Duration, end time.Time, span time.Duration) LeadLag {
+	var (
+		ll   LeadLag
+		lags []time.Duration
+	)
+
+	for symbol, fos := range follower {
+		los := leader[symbol]
+
+		for _, side := range []bfldb.TradeDirection{bfldb.Short, bfldb.Long} {
+			// share of the time analyzed the follower would match the leader within by chance
+			p := float64(covered(los, side, maxLag, end)) / float64(span)
+
+			for _, fo := range fos {
+				if fo.side != side {
+					continue
+				}
+
+				ll.Opens++
+				ll.Expected += p
+
+				// the latest position of the leader opened before the follower's one
+				i := sort.Search(len(los), func(i int) bool { return !los[i].time.Before(fo.time) })
+				for i--; i >= 0 && fo.time.Sub(los[i].time) <= maxLag; i-- {
+					if los[i].side == side {
+						ll.Matches++
+						lags = append(lags, fo.time.Sub(los[i].time))
+						break
+					}
+				}
+			}
+		}
+	}
+
+	ll.PValue = poissonTail(ll.Matches, ll.Expected)
+
+	if len(lags) > 0 {
+		sort.Slice(lags, func(i, j int) bool { return lags[i] < lags[j] })
+		ll.MedianLag = lags[len(lags)/2]
+	}
+
+	return ll
+}
+
+// adjust sets the adjusted p-values of all relationships tested with the correction.
+func adjust(lls []LeadLag, c Correction) {
+	m := float64(len(lls))
+
+	switch c {
+	case NoCorrection:
+		for i := range lls {
+			lls[i].Adjusted = lls[i].PValue
+		}
+
+	case Bonferroni:
+		for i := range lls {
+			lls[i].Adjusted = math.Min(1, lls[i].PValue*m)
+		}
+
+	default:
+		// the k-th smallest p-value is scaled by m/k, keeping the adjusted p-values monotonic
+		order := make([]int, len(lls))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool { return lls[order[i]].PValue < lls[order[j]].PValue })
+
+		adj := 1.0
+		for k := len(order) - 1; k >= 0; k-- {
+			i := order[k]
+			adj = math.Min(adj, lls[i].PValue*m/float64(k+1))
+			lls[i].Adjusted = adj
+		}
+	}
+}
+
+// covered returns how long the windows of maxLag after positions opened in the direction cover in total,
+// overlapping windows counted once and cut at the end of the time analyzed.
+func covered(ops []open, side bfldb.TradeDirection, maxLag time.Duration, end time.Time) time.Duration {
+	var (
+		total time.Duration
+		until time.Time
+	)
+
+	for _, o := range ops {
+		if o.side != side {
+			continue
+		}
+
+		from, to := o.time, o.time.Add(maxLag)
+		if to.After(end) {
+			to = end
+		}
+		if from.Before(until) {
+			from = until
+		}
+		if to.After(from) {
+			total += to.Sub(from)
+			until = to
+		}
+	}
+
+	return total
+}
+
+// poissonTail returns the probability of at least k events of a Poisson distribution with the mean passed in.
+func poissonTail(k int, mean float64) float64 {
+	if k <= 0 {
+		return 1
+	}
+	if mean <= 0 {
+		return 0
+	}
+
+	// 1 - P(X < k)
+	term := math.Exp(-mean)
+	cdf := term
+	for i := 1; i < k; i++ {
+		term *= mean / float64(i)
+		cdf += term
+	}
+
+	return math.Max(0, 1-cdf)
+}
+
+// originators returns the leaders of the relationships that don't follow anyone, the most followed first.
+func originators(lls []LeadLag) []Originator {
+	followers := make(map[string][]string)
+	following := make(map[string]bool)
+	for _, ll := range lls {
+		followers[ll.Leader] = append(followers[ll.Leader], ll.Follower)
+		following[ll.Follower] = true
+	}
+
+	var origs []Originator
+	for uid, fs := range followers {
+		if following[uid] {
+			continue
+		}
+
+		sort.Strings(fs)
+		origs = append(origs, Originator{UID: uid, Followers: fs})
+	}
+
+	sort.Slice(origs, func(i, j int) bool {
+		if len(origs[i].Followers) != len(origs[j].Followers) {
+			return len(origs[i].Followers) > len(origs[j].Followers)
+		}
+		return origs[i].UID < origs[j].UID
+	})
+
+	return origs
+}
+
+// WithMaxLag sets the maximum delay of a follower's position after the leader's one.
+func WithMaxLag(d time.Duration) LeadLagOption {
+	return func(c *leadLagConfig) {
+		c.maxLag = d
+	}
+}
+
+// WithSignificance sets the maximum adjusted p-value of relationships reported, e.g. 0.01.
+func WithSignificance(alpha float64) LeadLagOption {
+	return func(c *leadLagConfig) {
+		c.alpha = alpha
+	}
+}
+
+// WithMinMatches sets the minimum number of matching positions of relationships reported.
+func WithMinMatches(n int) LeadLagOption {
+	return func(c *leadLagConfig) {
+		c.minMatches = n
+	}
+}
+
+// WithCorrection sets how p-values are corrected for the number of pairs of traders tested.
+func WithCorrection(c Correction) LeadLagOption {
+	return func(cfg *leadLagConfig) {
+		cfg.correction = c
+	}
+}
@@ -0,0 +1,100 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectLeadLag(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	var events []bfldb.Event
+	add := func(uid string, at time.Duration, typ bfldb.PositionType, dir bfldb.TradeDirection, symbol string) {
+		events = append(events, bfldb.PositionChanged{
+			EventMeta: bfldb.EventMeta{UID: uid, Time: start.Add(at)},
+			Position:  bfldb.Position{Type: typ, Direction: dir, Ticker: symbol, Amount: 1},
+		})
+	}
+
+	symbols := []string{"BTCUSDT", "ETHUSDT", "SOLUSDT", "XRPUSDT", "BNBUSDT"}
+	for i, s := range symbols {
+		at := time.Duration(i) * 6 * time.Hour
+
+		// A opens, B copies A 20 seconds later and C copies B another 20 seconds later
+		add("A", at, bfldb.Opened, bfldb.Long, s)
+		add("B", at+20*time.Second, bfldb.Opened, bfldb.Long, s)
+		add("C", at+40*time.Second, bfldb.Opened, bfldb.Long, s)
+
+		// D trades the same symbols, but in the opposite direction and hours later
+		add("D", at+3*time.Hour, bfldb.Opened, bfldb.Short, s)
+
+		add("A", at+time.Hour, bfldb.Closed, bfldb.Long, s)
+	}
+
+	r := DetectLeadLag(events)
+	require.Equal(t, start, r.From)
+	require.Equal(t, start.Add(27*time.Hour), r.To)
+	require.Equal(t, time.Minute, r.MaxLag)
+
+	// C matches A as well, but B leads C on its own
+	leads := make(map[[2]string]LeadLag)
+	for _, ll := range r.Relationships {
+		leads[[2]string{ll.Leader, ll.Follower}] = ll
+	}
+	require.Len(t, leads, 3)
+	require.Contains(t, leads, [2]string{"A", "B"})
+	require.Contains(t, leads, [2]string{"A", "C"})
+	require.Contains(t, leads, [2]string{"B", "C"})
+
+	ab := leads[[2]string{"A", "B"}]
+	require.Equal(t, 5, ab.Matches)
+	require.Equal(t, 5, ab.Opens)
+	require.Equal(t, 20*time.Second, ab.MedianLag)
+	require.Less(t, ab.PValue, 1e-10)
+	require.Less(t, ab.PValue, ab.Adjusted)
+	require.Equal(t, 40*time.Second, leads[[2]string{"A", "C"}].MedianLag)
+
+	require.Equal(t, []Originator{{UID: "A", Followers: []string{"B", "C"}}}, r.Originators)
+
+	// not enough matches within 10 seconds
+	r = DetectLeadLag(events, WithMaxLag(10*time.Second))
+	require.Empty(t, r.Relationships)
+	require.Empty(t, r.Originators)
+
+	r = DetectLeadLag(events, WithMinMatches(6))
+	require.Empty(t, r.Relationships)
+}
+
+func TestPoissonTail(t *testing.T) {
+	require.Equal(t, 1.0, poissonTail(0, 2))
+	require.InDelta(t, 1-0.1353352832, poissonTail(1, 2), 1e-9)
+	require.InDelta(t, 1-0.1353352832*3, poissonTail(2, 2), 1e-9)
+	require.Equal(t, 0.0, poissonTail(1, 0))
+}
+
+func TestAdjust(t *testing.T) {
+	pvalues := []float64{0.03, 0.01, 0.5, 0.02}
+
+	tests := []struct {
+		correction Correction
+		want       []float64
+	}{
+		{correction: NoCorrection, want: pvalues},
+		{correction: Bonferroni, want: []float64{0.12, 0.04, 1, 0.08}},
+		{correction: BenjaminiHochberg, want: []float64{0.04, 0.04, 0.5, 0.04}},
+	}
+
+	for _, tt := range tests {
+		lls := make([]LeadLag, len(pvalues))
+		for i, p := range pvalues {
+			lls[i].PValue = p
+		}
+
+		adjust(lls, tt.correction)
+		for i, ll := range lls {
+			require.InDeltaf(t, tt.want[i], ll.Adjusted, 1e-12, "%s %d", tt.correction, i)
+		}
+	}
+}
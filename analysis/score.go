@@ -0,0 +1,318 @@
+// Package analysis analyzes leaderboard traders, to help choosing whom to follow.
+//
+// A Scorer ranks traders by a configurable composite score of their leaderboard performance, profile
+// and locally observed statistics. Correlate finds traders behaving alike and symbols crowded by many of them,
+// and DetectLeadLag finds traders copying others with a delay.
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+)
+
+// Candidate is a trader that can be ranked by a Scorer.
+type Candidate struct {
+	UID         string                `json:"uid"`         // Encrypted UID of the trader
+	Performance bfldb.UserPerformance `json:"performance"` // Leaderboard performance
+	Info        bfldb.UserBaseInfo    `json:"info"`        // Profile
+	Stats       Stats                 `json:"stats"`       // Locally observed statistics, see ObserveStats
+}
+
+// Ranked is a candidate ranked by a Scorer.
+type Ranked struct {
+	Candidate
+	Score  float64            `json:"score"`  // Composite score, the weighted average of the normalized metrics the candidate was measured by
+	Scores map[string]float64 `json:"scores"` // Normalized scores of the metrics, from 0 for the worst candidate to 1 for the best one
+}
+
+// Metric measures a single aspect of a candidate, a higher value being better.
+// It returns NaN if the candidate can't be measured, e.g. since the trader wasn't observed yet.
+type Metric func(c Candidate) float64
+
+// weighted is a metric of a Scorer.
+type weighted struct {
+	name   string  // name of the metric
+	metric Metric  // the metric itself
+	weight float64 // weight of the metric in the composite score
+}
+
+// Scorer ranks candidates by a composite score of weighted metrics.
+//
+// Every metric is normalized across the candidates ranked, from 0 for the worst candidate to 1 for the best one,
+// and the composite score is the weighted average of all of them. Metrics a candidate can't be measured by
+// are left out of its score, rather than counting as the best or worst value.
+type Scorer struct {
+	metrics []weighted // metrics of the score
+}
+
+type ScorerOption func(*Scorer)
+
+// NewScorer creates a new Scorer with the metrics passed in, see WithMetric.
+//
+// Without any metrics, the monthly ROI, PNL and ROI leaderboard rank, win rate, drawdown, leverage, sharing
+// and follower count are used.
+func NewScorer(opts ...ScorerOption) *Scorer {
+	var s Scorer
+
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	if len(s.metrics) == 0 {
+		for _, opt := range []ScorerOption{
+			WithMetric("roi", ROI("MONTHLY"), 2),
+			WithMetric("pnl", PnL("MONTHLY"), 1),
+			WithMetric("rank", LeaderboardRank("MONTHLY", "ROI"), 1),
+			WithMetric("win_rate", WinRate(), 1),
+			WithMetric("drawdown", Drawdown(), 1),
+			WithMetric("leverage", Leverage(), 0.5),
+			WithMetric("sharing", Sharing(), 0.5),
+			WithMetric("followers", Followers(), 0.25),
+		} {
+			opt(&s)
+		}
+	}
+
+	return &s
+}
+
+// Rank scores all candidates and returns them sorted from the best one.
+func (s *Scorer) Rank(cs []Candidate) []Ranked {
+	rs := make([]Ranked, len(cs))
+	for i, c := range cs {
+		rs[i] = Ranked{Candidate: c, Scores: make(map[string]float64, len(s.metrics))}
+	}
+
+	// total weight of the metrics every candidate was measured by
+	totals := make([]float64, len(cs))
+
+	for _, m := range s.metrics {
+		values := make([]float64, len(cs))
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for i, c := range cs {
+			values[i] = m.metric(c)
+			if !math.IsNaN(values[i]) {
+				lo, hi = math.Min(lo, values[i]), math.Max(hi, values[i])
+			}
+		}
+
+		for i, v := range values {
+			if math.IsNaN(v) {
+				continue
+			}
+
+			// all candidates are equally good
+			norm := 1.0
+			if hi > lo {
+				norm = (v - lo) / (hi - lo)
+			}
+
+			rs[i].Scores[m.name] = norm
+			rs[i].Score += norm * m.weight
+			totals[i] += m.weight
+		}
+	}
+
+	for i := range rs {
+		if totals[i] > 0 {
+			rs[i].Score /= totals[i]
+		}
+	}
+
+	sort.SliceStable(rs, func(i, j int) bool {
+		return rs[i].Score > rs[j].Score
+	})
+
+	return rs
+}
+
+// Collect fetches the performance and profile of every trader and creates candidates out of them, along with
+// their statistics, if any. Traders that couldn't be fetched are left out and returned as a bfldb.BatchError.
+func Collect(ctx context.Context, c *bfldb.Client, uids []string, stats map[string]Stats) ([]Candidate, error) {
+	if c == nil {
+		c = bfldb.NewClient()
+	}
+
+	cs := make([]Candidate, 0, len(uids))
+	failed := make(bfldb.BatchError)
+
+	for _, uid := range uids {
+		u := c.NewUser(uid)
+
+		perf, err := u.GetOtherPerformance(ctx)
+		if err == nil && !perf.Success {
+			err = fmt.Errorf("bad response message: %v", perf.Message)
+		}
+		if err != nil {
+			failed[uid] = fmt.Errorf("failed to get performance: %w", err)
+			continue
+		}
+
+		info, err := u.GetOtherLeaderboardBaseInfo(ctx)
+		if err == nil && !info.Success {
+			err = fmt.Errorf("bad response message: %v", info.Message)
+		}
+		if err != nil {
+			failed[uid] = fmt.Errorf("failed to get base info: %w", err)
+			continue
+		}
+
+		st, ok := stats[uid]
+		if !ok {
+			st = Stats{UID: uid}
+		}
+
+		cs = append(cs, Candidate{UID: uid, Performance: perf.Data, Info: info.Data, Stats: st})
+	}
+
+	if len(failed) > 0 {
+		return cs, failed
+	}
+
+	return cs, nil
+}
+
+// Rotate makes the watcher watch the top n of the ranked candidates only, adding the ones that aren't watched yet
+// and removing all other users. It returns the UIDs added and removed.
+func Rotate(w *bfldb.Watcher, ranked []Ranked, n int) (added, removed []string) {
+	top := make(map[string]bool, n)
+	for i := 0; i < n && i < len(ranked); i++ {
+		top[ranked[i].UID] = true
+	}
+
+	for _, uid := range w.UIDs() {
+		if top[uid] {
+			delete(top, uid)
+			continue
+		}
+
+		if w.Remove(uid) == nil {
+			removed = append(removed, uid)
+		}
+	}
+
+	for i := 0; i < n && i < len(ranked); i++ {
+		uid := ranked[i].UID
+		if top[uid] && w.Add(uid) == nil {
+			added = append(added, uid)
+		}
+	}
+
+	return added, removed
+}
+
+// RotateEvery ranks the candidates returned by the function passed in every interval, rotating the watcher
+// towards the top n of them, until the context is cancelled. Rotation is skipped if no candidates are returned.
+func RotateEvery(ctx context.Context, w *bfldb.Watcher, s *Scorer, n int, interval time.Duration, candidates func(ctx context.Context) []Candidate) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if cs := candidates(ctx); len(cs) > 0 {
+			Rotate(w, s.Rank(cs), n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ROI measures the ROI of the period (e.g. MONTHLY), 0 if it's not known.
+func ROI(period string) Metric {
+	return func(c Candidate) float64 {
+		s, _ := c.Performance.Stat(period, "ROI")
+		return s.Value
+	}
+}
+
+// PnL measures the PNL of the period (e.g. MONTHLY), 0 if it's not known.
+func PnL(period string) Metric {
+	return func(c Candidate) float64 {
+		s, _ := c.Performance.Stat(period, "PNL")
+		return s.Value
+	}
+}
+
+// LeaderboardRank measures the leaderboard rank by the statistic of the period (e.g. MONTHLY and ROI),
+// the first rank being the best one and unranked candidates the worst ones.
+func LeaderboardRank(period, statistic string) Metric {
+	return func(c Candidate) float64 {
+		s, _ := c.Performance.Stat(period, statistic)
+		if s.Rank <= 0 {
+			return 0
+		}
+		return 1 / float64(s.Rank)
+	}
+}
+
+// Followers measures the follower count.
+func Followers() Metric {
+	return func(c Candidate) float64 {
+		return float64(c.Info.FollowerCount)
+	}
+}
+
+// WinRate measures the locally observed win rate, NaN if no trades were observed.
+func WinRate() Metric {
+	return func(c Candidate) float64 {
+		if c.Stats.Trades == 0 {
+			return math.NaN()
+		}
+		return c.Stats.WinRate()
+	}
+}
+
+// Drawdown measures the locally observed maximum drawdown, a smaller drawdown being better.
+// It's NaN if the trader wasn't observed.
+func Drawdown() Metric {
+	return func(c Candidate) float64 {
+		if c.Stats.Observed <= 0 {
+			return math.NaN()
+		}
+		return -c.Stats.MaxDrawdown
+	}
+}
+
+// Leverage measures the locally observed average leverage, a lower leverage being better.
+// It's NaN if no leverage was observed.
+func Leverage() Metric {
+	return func(c Candidate) float64 {
+		if c.Stats.AvgLeverage <= 0 {
+			return math.NaN()
+		}
+		return -c.Stats.AvgLeverage
+	}
+}
+
+// Sharing measures how consistently positions were shared, see Stats.Sharing. It's NaN if the trader wasn't observed.
+func Sharing() Metric {
+	return func(c Candidate) float64 {
+		if c.Stats.Observed <= 0 {
+			return math.NaN()
+		}
+		return c.Stats.Sharing()
+	}
+}
+
+// WithMetric adds a metric with the name and weight passed in to the composite score.
+// Metrics with the same name are replaced.
+func WithMetric(name string, m Metric, weight float64) ScorerOption {
+	return func(s *Scorer) {
+		for i := range s.metrics {
+			if s.metrics[i].name == name {
+				s.metrics[i] = weighted{name: name, metric: m, weight: weight}
+				return
+			}
+		}
+
+		s.metrics = append(s.metrics, weighted{name: name, metric: m, weight: weight})
+	}
+}
@@ -0,0 +1,115 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScorer_Rank(t *testing.T) {
+	perf := func(roi float64, rank int) bfldb.UserPerformance {
+		return bfldb.UserPerformance{PerformanceRetList: []bfldb.PerformanceStat{
+			{PeriodType: "MONTHLY", StatisticsType: "ROI", Value: roi, Rank: rank},
+		}}
+	}
+
+	cs := []Candidate{
+		{UID: "A", Performance: perf(0.1, 0), Stats: Stats{Trades: 4, Wins: 3}},
+		{UID: "B", Performance: perf(0.5, 1), Stats: Stats{Trades: 4, Wins: 1}},
+		{UID: "C", Performance: perf(0.3, 10), Stats: Stats{Trades: 4, Wins: 2}},
+	}
+
+	s := NewScorer(WithMetric("roi", ROI("MONTHLY"), 1), WithMetric("rank", LeaderboardRank("MONTHLY", "ROI"), 1))
+	rs := s.Rank(cs)
+	require.Equal(t, "B", rs[0].UID)
+	require.Equal(t, 1.0, rs[0].Score)
+	require.Equal(t, "C", rs[1].UID)
+	require.InDelta(t, 0.5*0.5+0.5*(0.1/1), rs[1].Score, 1e-9)
+	require.Equal(t, map[string]float64{"roi": 0, "rank": 0}, rs[2].Scores)
+
+	// win rate outweighs the rest
+	s = NewScorer(WithMetric("roi", ROI("MONTHLY"), 1), WithMetric("win_rate", WinRate(), 3))
+	require.Equal(t, "A", s.Rank(cs)[0].UID)
+
+	// the same metric is replaced
+	s = NewScorer(WithMetric("m", WinRate(), 1), WithMetric("m", ROI("MONTHLY"), 1))
+	require.Equal(t, "B", s.Rank(cs)[0].UID)
+
+	require.Len(t, NewScorer().Rank(cs), 3)
+}
+
+func TestScorer_Unobserved(t *testing.T) {
+	cs := []Candidate{
+		{UID: "A", Stats: Stats{Trades: 1, MaxDrawdown: 20, AvgLeverage: 10, Shared: 30 * time.Minute, Observed: time.Hour}},
+		{UID: "B", Stats: Stats{Trades: 1, MaxDrawdown: 10, AvgLeverage: 5, Shared: time.Hour, Observed: time.Hour}},
+		{UID: "C"},
+	}
+
+	// C wasn't observed, so it isn't the best one by default
+	s := NewScorer(WithMetric("drawdown", Drawdown(), 1), WithMetric("leverage", Leverage(), 1), WithMetric("sharing", Sharing(), 1))
+	rs := s.Rank(cs)
+	require.Equal(t, []string{"B", "A", "C"}, []string{rs[0].UID, rs[1].UID, rs[2].UID})
+	require.Equal(t, 1.0, rs[0].Score)
+	require.Equal(t, map[string]float64{"drawdown": 0, "leverage": 0, "sharing": 0}, rs[1].Scores)
+	require.Empty(t, rs[2].Scores)
+	require.Zero(t, rs[2].Score)
+
+	// metrics C can be measured by still count
+	s = NewScorer(WithMetric("followers", Followers(), 1), WithMetric("drawdown", Drawdown(), 1))
+	cs[2].Info.FollowerCount = 10
+	rs = s.Rank(cs)
+	require.Equal(t, "C", rs[0].UID)
+	require.Equal(t, map[string]float64{"followers": 1}, rs[0].Scores)
+}
+
+func TestCollect(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req bfldb.BaseInfoRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		switch {
+		case req.EncryptedUID == "bad":
+			w.Write([]byte(`{"success":false,"message":"user not found"}`))
+		case strings.HasSuffix(r.URL.Path, "/getOtherPerformance"):
+			w.Write([]byte(`{"success":true,"data":{"performanceRetList":[{"periodType":"MONTHLY","statisticsType":"ROI","value":0.25,"rank":3}],"lastTradeTime":1}}`))
+		case strings.HasSuffix(r.URL.Path, "/getOtherLeaderboardBaseInfo"):
+			w.Write([]byte(`{"success":true,"data":{"nickName":"Trader","followerCount":42}}`))
+		}
+	}))
+	defer api.Close()
+
+	cs, err := Collect(context.Background(), bfldb.NewClient(bfldb.WithClientAPIBase(api.URL)), []string{"A", "bad"}, map[string]Stats{"A": {UID: "A", Trades: 1}})
+
+	var be bfldb.BatchError
+	require.ErrorAs(t, err, &be)
+	require.Contains(t, be, "bad")
+
+	require.Len(t, cs, 1)
+	require.Equal(t, "A", cs[0].UID)
+	require.Equal(t, 42, cs[0].Info.FollowerCount)
+	require.Equal(t, 1, cs[0].Stats.Trades)
+
+	s, ok := cs[0].Performance.Stat("MONTHLY", "ROI")
+	require.True(t, ok)
+	require.Equal(t, bfldb.PerformanceStat{PeriodType: "MONTHLY", StatisticsType: "ROI", Value: 0.25, Rank: 3}, s)
+}
+
+func TestRotate(t *testing.T) {
+	w := bfldb.NewWatcher(nil)
+	require.NoError(t, w.Add("A"))
+	require.NoError(t, w.Add("B"))
+
+	ranked := []Ranked{{Candidate: Candidate{UID: "C"}}, {Candidate: Candidate{UID: "A"}}, {Candidate: Candidate{UID: "B"}}}
+
+	added, removed := Rotate(w, ranked, 2)
+	require.Equal(t, []string{"C"}, added)
+	require.Equal(t, []string{"B"}, removed)
+	require.Equal(t, []string{"A", "C"}, w.UIDs())
+}
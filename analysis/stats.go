@@ -0,0 +1,123 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+)
+
+// Stats are statistics of a trader observed locally, from their recorded events.
+type Stats struct {
+	UID         string        `json:"uid"`         // Encrypted UID of the trader
+	Trades      int           `json:"trades"`      // Positions closed
+	Wins        int           `json:"wins"`        // Positions closed in profit
+	PnL         float64       `json:"pnl"`         // Realized PNL, estimated from entry and mark prices
+	MaxDrawdown float64       `json:"maxDrawdown"` // Largest decline of the realized PNL from its peak
+	AvgLeverage float64       `json:"avgLeverage"` // Average leverage of positions opened and added to
+	MaxLeverage int           `json:"maxLeverage"` // Highest leverage of positions opened and added to
+	Shared      time.Duration `json:"shared"`      // How long positions were shared for
+	Observed    time.Duration `json:"observed"`    // How long the trader was observed for
+}
+
+// WinRate returns the share of positions closed in profit, 0 if no positions were closed.
+func (s Stats) WinRate() float64 {
+	if s.Trades == 0 {
+		return 0
+	}
+	return float64(s.Wins) / float64(s.Trades)
+}
+
+// Sharing returns the share of the time positions were shared for, 1 if the trader wasn't observed for any time.
+func (s Stats) Sharing() float64 {
+	if s.Observed <= 0 {
+		return 1
+	}
+	return float64(s.Shared) / float64(s.Observed)
+}
+
+// ObserveStats computes statistics of the trader with the UID passed in from their events,
+// e.g. the ones recorded by the storage package. Events of other traders are ignored.
+//
+// The PNL of closed positions is estimated from their entry and mark prices as of the latest poll. Positions are
+// expected to be shared until a SharingChanged event says otherwise.
+func ObserveStats(UID string, events []bfldb.Event) Stats {
+	evs := make([]bfldb.Event, 0, len(events))
+	for _, e := range events {
+		if e.Meta().UID == UID {
+			evs = append(evs, e)
+		}
+	}
+	sort.SliceStable(evs, func(i, j int) bool {
+		return evs[i].Meta().Time.Before(evs[j].Meta().Time)
+	})
+
+	s := Stats{UID: UID}
+	if len(evs) == 0 {
+		return s
+	}
+
+	var (
+		peak      float64
+		leverages int
+		shared    = true
+		since     = evs[0].Meta().Time
+	)
+
+	for _, e := range evs {
+		switch e := e.(type) {
+		case bfldb.PositionChanged:
+			p := e.Position
+
+			switch p.Type {
+			case bfldb.Opened, bfldb.AddedTo:
+				s.AvgLeverage += float64(p.Leverage)
+				leverages++
+				if p.Leverage > s.MaxLeverage {
+					s.MaxLeverage = p.Leverage
+				}
+
+			case bfldb.PartiallyClosed, bfldb.Closed:
+				pnl := realizedPnL(p)
+				s.PnL += pnl
+				peak = math.Max(peak, s.PnL)
+				s.MaxDrawdown = math.Max(s.MaxDrawdown, peak-s.PnL)
+
+				if p.Type == bfldb.Closed {
+					s.Trades++
+					if pnl > 0 {
+						s.Wins++
+					}
+				}
+			}
+
+		case bfldb.SharingChanged:
+			if shared {
+				s.Shared += e.Time.Sub(since)
+			}
+			shared, since = e.Shared, e.Time
+		}
+	}
+
+	last := evs[len(evs)-1].Meta().Time
+	if shared {
+		s.Shared += last.Sub(since)
+	}
+	s.Observed = last.Sub(evs[0].Meta().Time)
+
+	if leverages > 0 {
+		s.AvgLeverage /= float64(leverages)
+	}
+
+	return s
+}
+
+// realizedPnL estimates the PNL realized by the closed or partially closed position.
+func realizedPnL(p bfldb.Position) float64 {
+	pnl := (p.MarkPrice - p.EntryPrice) * (p.PrevAmount - p.Amount)
+	if p.Direction == bfldb.Short {
+		return -pnl
+	}
+	return pnl
+}
@@ -0,0 +1,46 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserveStats(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	meta := func(uid string, h int) bfldb.EventMeta {
+		return bfldb.EventMeta{UID: uid, Time: start.Add(time.Duration(h) * time.Hour)}
+	}
+
+	events := []bfldb.Event{
+		bfldb.PositionChanged{EventMeta: meta("A", 0), Position: bfldb.Position{Type: bfldb.Opened, Direction: bfldb.Long, Ticker: "BTCUSDT", Amount: 2, Leverage: 10}},
+		bfldb.PositionChanged{EventMeta: meta("A", 1), Position: bfldb.Position{Type: bfldb.PartiallyClosed, Direction: bfldb.Long, Ticker: "BTCUSDT", PrevAmount: 2, Amount: 1, EntryPrice: 100, MarkPrice: 150}},
+		bfldb.PositionChanged{EventMeta: meta("A", 2), Position: bfldb.Position{Type: bfldb.Closed, Direction: bfldb.Long, Ticker: "BTCUSDT", PrevAmount: 1, EntryPrice: 100, MarkPrice: 80}},
+		bfldb.SharingChanged{EventMeta: meta("A", 4), Shared: false},
+		bfldb.PositionChanged{EventMeta: meta("B", 5), Position: bfldb.Position{Type: bfldb.Opened, Direction: bfldb.Long, Ticker: "BTCUSDT", Amount: 2, Leverage: 125}},
+		bfldb.SharingChanged{EventMeta: meta("A", 6), Shared: true},
+		bfldb.PositionChanged{EventMeta: meta("A", 7), Position: bfldb.Position{Type: bfldb.Opened, Direction: bfldb.Short, Ticker: "ETHUSDT", Amount: 1, Leverage: 20}},
+		bfldb.PositionChanged{EventMeta: meta("A", 8), Position: bfldb.Position{Type: bfldb.Closed, Direction: bfldb.Short, Ticker: "ETHUSDT", PrevAmount: 1, EntryPrice: 100, MarkPrice: 90}},
+	}
+
+	s := ObserveStats("A", events)
+	require.Equal(t, Stats{
+		UID:         "A",
+		Trades:      2,
+		Wins:        1,
+		PnL:         40,
+		MaxDrawdown: 20,
+		AvgLeverage: 15,
+		MaxLeverage: 20,
+		Shared:      6 * time.Hour,
+		Observed:    8 * time.Hour,
+	}, s)
+	require.Equal(t, 0.5, s.WinRate())
+	require.Equal(t, 0.75, s.Sharing())
+
+	s = ObserveStats("C", events)
+	require.Equal(t, Stats{UID: "C"}, s)
+	require.Equal(t, 1.0, s.Sharing())
+}
@@ -0,0 +1,169 @@
+package bfldb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// NicknameResult is the result of a single search of a batch, see Client.SearchNicknames.
+type NicknameResult struct {
+	Nickname string            // Nickname searched for
+	Users    []NicknameDetails // Users found, empty if Err is set
+	Err      error             // Error the search failed with
+}
+
+// Resolution is the result of a single resolution of a batch, see Resolver.ResolveAll.
+type Resolution struct {
+	Nickname string          // Nickname resolved
+	User     NicknameDetails // User best matching the nickname, empty if Err is set
+	Err      error           // Error the resolution failed with, ErrNoMatch if no user matches
+}
+
+// BatchError is an error of a batch, with the errors of all failed items mapped by the item (e.g. a nickname).
+type BatchError map[string]error
+
+func (e BatchError) Error() string {
+	items := make([]string, 0, len(e))
+	for item := range e {
+		items = append(items, item)
+	}
+	sort.Strings(items)
+
+	for i, item := range items {
+		items[i] = fmt.Sprintf("%s: %v", item, e[item])
+	}
+
+	return fmt.Sprintf("%d item(s) failed: %s", len(e), strings.Join(items, "; "))
+}
+
+var _ error = BatchError{}
+
+// batchConfig is the configuration of a batch.
+type batchConfig struct {
+	concurrency int          // maximum items processed at once
+	limiter     *RateLimiter // limits the rate items are started at, nil means unlimited
+	failFast    bool         // whether or not the first failure cancels the remaining items
+}
+
+type BatchOption func(*batchConfig)
+
+// runBatch calls fn for every item index from 0 to n, with the batch configured by opts.
+// By default, 4 items are processed at once, without any rate limit, and failures don't affect other items.
+//
+// fn has to store its result itself, and report the context's error once it's cancelled.
+// It returns once all items are processed.
+func runBatch(ctx context.Context, n int, opts []BatchOption, fn func(ctx context.Context, i int) error) {
+	cfg := batchConfig{concurrency: 4}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.concurrency < 1 {
+		cfg.concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	items := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < cfg.concurrency && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range items {
+				if cfg.limiter != nil {
+					// fails only once the context is cancelled, which fn reports
+					cfg.limiter.Wait(ctx)
+				}
+
+				if err := fn(ctx, i); err != nil && cfg.failFast {
+					cancel()
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		items <- i
+	}
+	close(items)
+
+	wg.Wait()
+}
+
+// SearchNicknames searches for all of the nicknames in a batch, see WithConcurrency, WithRateLimiter
+// and WithFailFast. Results are in the same order as the nicknames, each with its own error.
+func (c *Client) SearchNicknames(ctx context.Context, nicknames []string, opts ...BatchOption) []NicknameResult {
+	results := make([]NicknameResult, len(nicknames))
+
+	runBatch(ctx, len(nicknames), opts, func(ctx context.Context, i int) error {
+		results[i].Nickname = nicknames[i]
+
+		if err := ctx.Err(); err != nil {
+			results[i].Err = err
+			return err
+		}
+
+		res, err := c.SearchNickname(ctx, nicknames[i])
+		if err == nil && !res.Success {
+			err = fmt.Errorf("bad response message: %v", res.Message)
+		}
+		if err != nil {
+			results[i].Err = fmt.Errorf("failed to search nickname: %w", err)
+			return results[i].Err
+		}
+
+		results[i].Users = res.Data
+		return nil
+	})
+
+	return results
+}
+
+// ResolveAll resolves all of the nicknames in a batch, see WithConcurrency, WithRateLimiter and WithFailFast.
+// Results are in the same order as the nicknames, each with its own error.
+func (r *Resolver) ResolveAll(ctx context.Context, nicknames []string, opts ...BatchOption) []Resolution {
+	results := make([]Resolution, len(nicknames))
+
+	runBatch(ctx, len(nicknames), opts, func(ctx context.Context, i int) error {
+		results[i].Nickname = nicknames[i]
+
+		if err := ctx.Err(); err != nil {
+			results[i].Err = err
+			return err
+		}
+
+		results[i].User, results[i].Err = r.Resolve(ctx, nicknames[i])
+		return results[i].Err
+	})
+
+	return results
+}
+
+// WithConcurrency sets the maximum number of items of a batch processed at once.
+func WithConcurrency(n int) BatchOption {
+	return func(c *batchConfig) {
+		c.concurrency = n
+	}
+}
+
+// WithRateLimiter makes every item of a batch wait for the rate limiter first. The rate limiter can be shared
+// with other batches or with users and clients through the RateLimit middleware.
+func WithRateLimiter(l *RateLimiter) BatchOption {
+	return func(c *batchConfig) {
+		c.limiter = l
+	}
+}
+
+// WithFailFast cancels all remaining items of a batch once any of them fails,
+// instead of carrying on and returning partial results.
+func WithFailFast() BatchOption {
+	return func(c *batchConfig) {
+		c.failFast = true
+	}
+}
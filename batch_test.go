@@ -0,0 +1,102 @@
+package bfldb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newSearchAPI creates a server answering nickname searches with a single user of the same nickname,
+// failing searches for "fail". It keeps track of the maximum number of concurrent requests.
+func newSearchAPI(t *testing.T, inFlight, maxInFlight *int32) *httptest.Server {
+	t.Helper()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(inFlight, 1)
+		defer atomic.AddInt32(inFlight, -1)
+		for {
+			m := atomic.LoadInt32(maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(maxInFlight, m, n) {
+				break
+			}
+		}
+
+		var req SearchNicknameRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		time.Sleep(10 * time.Millisecond)
+		if req.Nickname == "fail" {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		fmt.Fprintf(w, `{"success":true,"data":[{"encryptedUid":"%s-uid","nickname":"%s"}]}`, req.Nickname, req.Nickname)
+	}))
+	t.Cleanup(api.Close)
+
+	return api
+}
+
+func TestClient_SearchNicknames(t *testing.T) {
+	var inFlight, maxInFlight int32
+	c := NewClient(WithClientAPIBase(newSearchAPI(t, &inFlight, &maxInFlight).URL))
+
+	nicks := []string{"a", "b", "fail", "c", "d", "e"}
+	results := c.SearchNicknames(context.Background(), nicks, WithConcurrency(2))
+
+	require.Len(t, results, len(nicks))
+	for i, res := range results {
+		require.Equal(t, nicks[i], res.Nickname)
+
+		if res.Nickname == "fail" {
+			require.Error(t, res.Err)
+			continue
+		}
+
+		require.NoError(t, res.Err)
+		require.Equal(t, res.Nickname+"-uid", res.Users[0].EncryptedUID)
+	}
+	require.Equal(t, int32(2), atomic.LoadInt32(&maxInFlight))
+
+	// the failure cancels everything that didn't start yet
+	results = c.SearchNicknames(context.Background(), nicks, WithConcurrency(1), WithFailFast())
+	require.NoError(t, results[1].Err)
+	for _, res := range results[2:] {
+		require.Error(t, res.Err)
+	}
+	require.ErrorIs(t, results[3].Err, context.Canceled)
+}
+
+func TestResolver_ResolveAll(t *testing.T) {
+	var inFlight, maxInFlight int32
+	r := NewResolver(NewClient(WithClientAPIBase(newSearchAPI(t, &inFlight, &maxInFlight).URL)))
+
+	results := r.ResolveAll(context.Background(), []string{"a", "fail"}, WithRateLimiter(NewRateLimiter(time.Millisecond, 1)))
+	require.NoError(t, results[0].Err)
+	require.Equal(t, "a-uid", results[0].User.EncryptedUID)
+	require.Error(t, results[1].Err)
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(20*time.Millisecond, 2)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		require.NoError(t, l.Wait(ctx))
+	}
+
+	// 2 requests are allowed right away, the other 2 wait for their tokens
+	require.GreaterOrEqual(t, time.Since(start), 35*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	require.ErrorIs(t, l.Wait(ctx), context.Canceled)
+}
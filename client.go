@@ -0,0 +1,171 @@
+package bfldb
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// Client holds configuration shared by requests that are not tied to a specific User (e.g. nickname searches)
+// and can be used to create Users with the same configuration.
+type Client struct {
+	mtx     sync.RWMutex      // Synchronization for apiBase and headers
+	apiBase string            // API base used for requests
+	headers map[string]string // headers
+
+	client   *http.Client // http client
+	observer Observer     // observer notified about requests
+	tracer   Tracer       // tracer starting spans around requests
+
+	middlewares []Middleware // middlewares wrapping every request
+}
+
+type ClientOption func(*Client)
+
+// NewClient creates a new Client.
+func NewClient(opts ...ClientOption) *Client {
+	c := Client{
+		client:   http.DefaultClient,
+		observer: nopObserver{},
+		tracer:   nopTracer{},
+		headers:  defaultHeaders,
+		apiBase:  defaultApiBase,
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return &c
+}
+
+// SetAPIBase sets the API base used for requests.
+func (c *Client) SetAPIBase(s string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.apiBase = s
+}
+
+// APIBase returns the API base used for requests.
+func (c *Client) APIBase() string {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	return c.apiBase
+}
+
+// SetHeaders sets headers the client uses for every request.
+func (c *Client) SetHeaders(h map[string]string) {
+	headers := make(map[string]string, len(h))
+	// copy them so it doesn't matter if the input is modified by caller later
+	for k, v := range h {
+		headers[k] = v
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.headers = headers
+}
+
+// Headers returns headers the client uses for every request.
+func (c *Client) Headers() map[string]string {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	headers := make(map[string]string, len(c.headers))
+
+	// copy them so it doesn't matter if they are modified by caller later
+	for k, v := range c.headers {
+		headers[k] = v
+	}
+
+	return headers
+}
+
+// NewUser creates a new User sharing the client's API base, headers and HTTP client.
+//
+// Options passed in are applied after the client's configuration, so they can override it.
+func (c *Client) NewUser(UID string, opts ...UserOption) *User {
+	return NewUser(UID, append([]UserOption{
+		WithAPIBase(c.APIBase()),
+		WithHeaders(c.Headers()),
+		WithHTTPClient(c.client),
+		WithObserver(c.observer),
+		WithTracer(c.tracer),
+		WithMiddleware(c.middlewares...),
+	}, opts...)...)
+}
+
+// SearchNickname searches for a nickname.
+func (c *Client) SearchNickname(ctx context.Context, nickname string) (LdbAPIRes[[]NicknameDetails], error) {
+	var res LdbAPIRes[[]NicknameDetails]
+	return res, doPost(ctx, c.request("/v1/public/future/leaderboard", "/searchNickname", SearchNicknameRequest{Nickname: nickname}), &res)
+}
+
+// request creates a new request to the endpoint and path on client's API base.
+func (c *Client) request(endpoint, path string, body any) request {
+	return request{
+		client:      c.client,
+		observer:    c.observer,
+		tracer:      c.tracer,
+		middlewares: c.middlewares,
+		endpoint:    c.APIBase() + endpoint,
+		path:        path,
+		headers:     c.Headers(),
+		body:        body,
+	}
+}
+
+// WithClientAPIBase sets the API base used for requests.
+func WithClientAPIBase(s string) ClientOption {
+	return func(c *Client) {
+		c.apiBase = s
+	}
+}
+
+// WithClientHeaders sets headers the client uses for every request.
+func WithClientHeaders(h map[string]string) ClientOption {
+	return func(c *Client) {
+		c.headers = h
+	}
+}
+
+// WithClientHTTPClient sets client's HTTP Client.
+func WithClientHTTPClient(hc *http.Client) ClientOption {
+	return func(c *Client) {
+		c.client = hc
+	}
+}
+
+// WithClientProxyPool sends all of client's requests through the proxy pool passed in.
+func WithClientProxyPool(pp *ProxyPool) ClientOption {
+	return func(c *Client) {
+		c.client = &http.Client{Transport: pp}
+	}
+}
+
+// WithClientObserver sets an observer notified about every request the client makes,
+// users created by the client are observed by it as well.
+func WithClientObserver(o Observer) ClientOption {
+	return func(c *Client) {
+		c.observer = o
+	}
+}
+
+// WithClientTracer sets a tracer starting spans around every request the client makes,
+// users created by the client are traced by it as well.
+func WithClientTracer(t Tracer) ClientOption {
+	return func(c *Client) {
+		c.tracer = t
+	}
+}
+
+// WithClientMiddleware appends middlewares wrapping every request the client makes,
+// users created by the client use them as well, before their own.
+func WithClientMiddleware(mws ...Middleware) ClientOption {
+	return func(c *Client) {
+		c.middlewares = append(c.middlewares, mws...)
+	}
+}
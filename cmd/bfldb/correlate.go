@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+	"github.com/rtunazzz/bfldb/analysis"
+	"github.com/rtunazzz/bfldb/storage"
+)
+
+// runCorrelate runs the correlate command.
+func runCorrelate(ctx context.Context, args []string) error {
+	var g globalFlags
+	fs := newFlagSet("correlate", commands["correlate"].usage, &g)
+	db := fs.String("db", "bfldb.db", "SQLite database written by 'watch --db'")
+	window := fs.Duration("window", time.Hour, "how often exposures are sampled and how long the windows positions are opened within are")
+	threshold := fs.Float64("threshold", 0.7, "minimum correlation of traders clustered together")
+	minCrowd := fs.Int("min-crowd", 3, "minimum number of traders holding a symbol in the same direction for it to be crowded")
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	events, err := loadEvents(ctx, *db, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	r := analysis.Correlate(events,
+		analysis.WithInterval(*window),
+		analysis.WithClusterThreshold(*threshold),
+		analysis.WithMinCrowd(*minCrowd),
+	)
+
+	if g.json {
+		return printJSON(r)
+	}
+
+	fmt.Printf("Exposure correlation (%s - %s):\n%s\n", r.From.Format(timeLayout), r.To.Format(timeLayout), r.Exposure)
+	fmt.Printf("Timing correlation:\n%s\n", r.Timing)
+
+	fmt.Println("Clusters:")
+	for i, c := range r.Clusters {
+		fmt.Printf("  %d. %s\n", i+1, strings.Join(c, ", "))
+	}
+	fmt.Println()
+
+	t := newTable(os.Stdout, "SYMBOL", "SIDE", "TRADERS", "SHARE")
+	for _, c := range r.Crowded {
+		t.row(c.Symbol, c.Direction.String(), fmt.Sprint(len(c.UIDs)), fmt.Sprintf("%.0f%%", c.Share*100))
+	}
+	return t.flush()
+}
+
+// loadEvents loads position changes, snapshots and sharing changes of the traders from a SQLite database written by `watch --db`,
+// or of all traders if there are none.
+func loadEvents(ctx context.Context, path string, uids []string) ([]bfldb.Event, error) {
+	// opening a database that doesn't exist would create it
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	st, err := storage.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+
+	queries := []storage.Query{{}}
+	if len(uids) > 0 {
+		queries = queries[:0]
+		for _, uid := range uids {
+			queries = append(queries, storage.Query{UID: uid})
+		}
+	}
+
+	var events []bfldb.Event
+	for _, q := range queries {
+		snaps, err := st.Snapshots(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range snaps {
+			events = append(events, s)
+		}
+
+		es, err := st.Events(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range es {
+			events = append(events, e)
+		}
+
+		scs, err := st.SharingChanges(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range scs {
+			events = append(events, e)
+		}
+	}
+
+	return events, nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+	"github.com/rtunazzz/bfldb/export"
+	"github.com/rtunazzz/bfldb/storage"
+)
+
+// sqliteHeader starts every SQLite database file.
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+// runExport runs the export command.
+func runExport(ctx context.Context, args []string) error {
+	var g globalFlags
+	fs := newFlagSet("export", commands["export"].usage, &g)
+	from := fs.String("from", "", "recorder file to export, either JSON lines written by 'watch --json' or a SQLite database written by 'watch --db' (exports traders live if not set)")
+	format := fs.String("format", "", "output format, csv or parquet (defaults to the extension of --out, csv otherwise)")
+	out := fs.String("out", "", "path of the output file (defaults to STDOUT)")
+	snapshots := fs.Bool("snapshots", false, "export snapshots of open positions instead of position changes (fetched once when exporting live)")
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	if *from == "" && fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("export: expected at least one uid to export live")
+	}
+
+	if *format == "" {
+		*format = "csv"
+		if filepath.Ext(*out) == ".parquet" {
+			*format = "parquet"
+		}
+	}
+
+	var dst io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer f.Close()
+		dst = f
+	}
+
+	var w export.Writer
+	switch *format {
+	default:
+		return fmt.Errorf("export: unknown format %q", *format)
+	case "csv":
+		w = export.NewCSVWriter(dst)
+	case "parquet":
+		w = export.NewParquetWriter(dst)
+	}
+
+	var err error
+	switch {
+	case *from == "":
+		err = exportLive(ctx, &g, w, fs.Args(), *snapshots)
+	case isSQLite(*from):
+		err = exportDB(ctx, w, *from, fs.Args(), *snapshots)
+	default:
+		err = exportJSONL(w, *from, fs.Args(), *snapshots)
+	}
+	if err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+// isSQLite returns whether or not the file at path is a SQLite database.
+func isSQLite(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	b := make([]byte, len(sqliteHeader))
+	if _, err := io.ReadFull(f, b); err != nil {
+		return false
+	}
+
+	return bytes.Equal(b, sqliteHeader)
+}
+
+// exportJSONL exports records of the traders from JSON lines written by `watch --json`, or of all traders
+// if there are none. With snapshots set, only positions of snapshots are exported.
+func exportJSONL(w export.Writer, path string, uids []string, snapshots bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open recorder file: %w", err)
+	}
+	defer f.Close()
+
+	only := make(map[string]bool, len(uids))
+	for _, uid := range uids {
+		only[uid] = true
+	}
+
+	return export.DecodeJSONL(f, func(r export.Record) error {
+		if len(only) > 0 && !only[r.UID] {
+			return nil
+		}
+		if (r.Type == bfldb.Existing) != snapshots {
+			return nil
+		}
+
+		return w.Write(r)
+	})
+}
+
+// exportDB exports position changes or snapshots of the traders from a SQLite database written by `watch --db`,
+// or of all traders if there are none.
+func exportDB(ctx context.Context, w export.Writer, path string, uids []string, snapshots bool) error {
+	st, err := storage.Open(path)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	// export everything at once if no traders were passed in
+	queries := []storage.Query{{}}
+	if len(uids) > 0 {
+		queries = queries[:0]
+		for _, uid := range uids {
+			queries = append(queries, storage.Query{UID: uid})
+		}
+	}
+
+	nicknames := make(map[string]string)
+	nickname := func(uid string) string {
+		if n, ok := nicknames[uid]; ok {
+			return n
+		}
+
+		// the profile is optional
+		p, _ := st.Profile(ctx, uid)
+		nicknames[uid] = p.Info.NickName
+		return p.Info.NickName
+	}
+
+	for _, q := range queries {
+		if snapshots {
+			snaps, err := st.Snapshots(ctx, q)
+			if err != nil {
+				return err
+			}
+
+			for _, snap := range snaps {
+				for _, p := range snap.Positions {
+					if err := w.Write(export.NewRecord(snap.UID, nickname(snap.UID), snap.Time, p)); err != nil {
+						return err
+					}
+				}
+			}
+			continue
+		}
+
+		es, err := st.Events(ctx, q)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range es {
+			if err := w.Write(export.NewRecord(e.UID, nickname(e.UID), e.Time, e.Position)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// exportLive exports position changes of the traders until the context is cancelled.
+// With snapshots set, their currently open positions are exported once instead.
+func exportLive(ctx context.Context, g *globalFlags, w export.Writer, uids []string, snapshots bool) error {
+	users, err := g.users(uids)
+	if err != nil {
+		return err
+	}
+
+	nicknames := make(map[string]string, len(uids))
+	for _, u := range users {
+		// the nickname is optional
+		if res, err := u.GetOtherLeaderboardBaseInfo(ctx); err == nil && res.Success {
+			nicknames[u.UID] = res.Data.NickName
+		}
+	}
+
+	if snapshots {
+		for _, u := range users {
+			res, err := u.GetOtherPosition(ctx)
+			if err == nil && !res.Success {
+				err = fmt.Errorf("bad response message: %v", res.Message)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to fetch positions of %s: %w", u.UID, err)
+			}
+
+			for _, r := range export.SnapshotRecords(u.UID, nicknames[u.UID], time.Now(), res.Data) {
+				if err := w.Write(r); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	records := make(chan export.Record)
+	var wg sync.WaitGroup
+
+	for _, u := range users {
+		u := u
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for e := range u.Subscribe(ctx) {
+				switch e := e.(type) {
+				case bfldb.PositionChanged:
+					records <- export.NewRecord(u.UID, nicknames[u.UID], e.Time, e.Position)
+				case bfldb.ErrorEvent:
+					fmt.Fprintf(os.Stderr, "bfldb: [%s] %v\n", u.UID, e.Err)
+				}
+			}
+		}()
+	}
+
+	// close the channel once all of the subscriptions are done
+	go func() {
+		wg.Wait()
+		close(records)
+	}()
+
+	for r := range records {
+		if err != nil {
+			// keep draining, so the cancelled subscriptions can finish
+			continue
+		}
+
+		if err = w.Write(r); err != nil {
+			cancel()
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+)
+
+// globalFlags are flags shared by all commands.
+type globalFlags struct {
+	apiBase     string        // API base used for requests
+	interval    time.Duration // Delay between position refreshes
+	headersFile string        // Path to a JSON file with request headers
+	proxies     string        // Comma separated proxy URLs requests are spread across
+	json        bool          // Print JSON instead of tables
+}
+
+// newFlagSet creates a new flag set for the command with the global flags registered.
+func newFlagSet(name, usage string, g *globalFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: bfldb %s %s\n\nFlags:\n", name, usage)
+		fs.PrintDefaults()
+	}
+
+	fs.StringVar(&g.apiBase, "api-base", "", "API base used for requests (defaults to Binance's)")
+	fs.DurationVar(&g.interval, "interval", 5*time.Second, "delay between position refreshes")
+	fs.StringVar(&g.headersFile, "headers-file", "", "path to a JSON object of headers sent with every request")
+	fs.StringVar(&g.proxies, "proxies", "", "comma separated list of HTTP or SOCKS5 proxy URLs requests are spread across")
+	fs.BoolVar(&g.json, "json", false, "print JSON instead of tables")
+
+	return fs
+}
+
+// headers reads the headers file, if any was set.
+func (g *globalFlags) headers() (map[string]string, error) {
+	if g.headersFile == "" {
+		return nil, nil
+	}
+
+	b, err := os.ReadFile(g.headersFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read headers file: %w", err)
+	}
+
+	var h map[string]string
+	if err := json.Unmarshal(b, &h); err != nil {
+		return nil, fmt.Errorf("failed to parse headers file: %w", err)
+	}
+
+	return h, nil
+}
+
+// client creates a new client configured by the flags, followed by the options passed in.
+func (g *globalFlags) client(extra ...bfldb.ClientOption) (*bfldb.Client, error) {
+	var opts []bfldb.ClientOption
+
+	if g.apiBase != "" {
+		opts = append(opts, bfldb.WithClientAPIBase(g.apiBase))
+	}
+
+	h, err := g.headers()
+	if err != nil {
+		return nil, err
+	}
+	if h != nil {
+		opts = append(opts, bfldb.WithClientHeaders(h))
+	}
+
+	if g.proxies != "" {
+		pp, err := bfldb.NewProxyPool(strings.Split(g.proxies, ","))
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, bfldb.WithClientProxyPool(pp))
+	}
+
+	return bfldb.NewClient(append(opts, extra...)...), nil
+}
+
+// user creates a new user configured by the flags, followed by the options passed in.
+func (g *globalFlags) user(uid string, extra ...bfldb.UserOption) (*bfldb.User, error) {
+	us, err := g.users([]string{uid}, extra...)
+	if err != nil {
+		return nil, err
+	}
+
+	return us[0], nil
+}
+
+// users creates new users configured by the flags, followed by the options passed in. All of them are created
+// by a single client, so they share its proxy pool.
+func (g *globalFlags) users(uids []string, extra ...bfldb.UserOption) ([]*bfldb.User, error) {
+	c, err := g.client()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := append([]bfldb.UserOption{bfldb.WithCustomRefresh(g.interval)}, extra...)
+
+	us := make([]*bfldb.User, 0, len(uids))
+	for _, uid := range uids {
+		us = append(us, c.NewUser(uid, opts...))
+	}
+
+	return us, nil
+}
+
+// parseArgs parses the arguments and makes sure at least min positional arguments are left.
+func parseArgs(fs *flag.FlagSet, args []string, min int) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < min {
+		fs.Usage()
+		return fmt.Errorf("%s: expected at least %d argument(s), got %d", fs.Name(), min, fs.NArg())
+	}
+
+	return nil
+}
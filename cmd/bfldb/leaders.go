@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rtunazzz/bfldb/analysis"
+)
+
+// runLeaders runs the leaders command.
+func runLeaders(ctx context.Context, args []string) error {
+	var g globalFlags
+	fs := newFlagSet("leaders", commands["leaders"].usage, &g)
+	db := fs.String("db", "bfldb.db", "SQLite database written by 'watch --db'")
+	maxLag := fs.Duration("max-lag", time.Minute, "maximum delay of a follower's position after the leader's one")
+	alpha := fs.Float64("alpha", 0.01, "maximum adjusted p-value of relationships reported")
+	minMatches := fs.Int("min-matches", 3, "minimum number of matching positions of relationships reported")
+	correction := fs.String("correction", analysis.BenjaminiHochberg.String(), "correction of p-values for the number of pairs of traders tested, benjamini-hochberg, bonferroni or none")
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	var corr analysis.Correction
+	for _, c := range []analysis.Correction{analysis.BenjaminiHochberg, analysis.Bonferroni, analysis.NoCorrection} {
+		if c.String() == *correction {
+			corr = c
+		}
+	}
+	if corr == 0 {
+		return fmt.Errorf("leaders: unknown correction %q", *correction)
+	}
+
+	events, err := loadEvents(ctx, *db, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	r := analysis.DetectLeadLag(events,
+		analysis.WithMaxLag(*maxLag),
+		analysis.WithSignificance(*alpha),
+		analysis.WithMinMatches(*minMatches),
+		analysis.WithCorrection(corr),
+	)
+
+	if g.json {
+		return printJSON(r)
+	}
+
+	t := newTable(os.Stdout, "LEADER", "FOLLOWER", "MATCHES", "OPENS", "EXPECTED", "P-VALUE", "ADJUSTED", "MEDIAN LAG")
+	for _, ll := range r.Relationships {
+		t.row(ll.Leader, ll.Follower, fmt.Sprint(ll.Matches), fmt.Sprint(ll.Opens), fmt.Sprintf("%.2f", ll.Expected), fmt.Sprintf("%.2g", ll.PValue), fmt.Sprintf("%.2g", ll.Adjusted), ll.MedianLag.String())
+	}
+	if err := t.flush(); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	t = newTable(os.Stdout, "ORIGINATOR", "FOLLOWERS")
+	for _, o := range r.Originators {
+		t.row(o.UID, strings.Join(o.Followers, ", "))
+	}
+	return t.flush()
+}
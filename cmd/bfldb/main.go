@@ -0,0 +1,130 @@
+// Command bfldb is a command-line interface to Binance's Futures Leaderboard API.
+//
+// Usage:
+//
+//	bfldb <command> [flags] [arguments]
+//
+// Run `bfldb help` for a list of available commands.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+)
+
+// command is a single bfldb subcommand.
+type command struct {
+	usage string                                         // Arguments the command accepts
+	short string                                         // One line description
+	run   func(ctx context.Context, args []string) error // Runs the command with the arguments following its name
+}
+
+// commands are all of the available commands, keyed by their name.
+var commands map[string]command
+
+func init() {
+	// assigned in init to avoid an initialization cycle, since commands use their usage
+	commands = map[string]command{
+		"search": {
+			usage: "[flags] <nickname>",
+			short: "search leaderboard traders by nickname",
+			run:   runSearch,
+		},
+		"correlate": {
+			usage: "[flags] [uid...]",
+			short: "analyze correlation and crowding of traders recorded by watch --db",
+			run:   runCorrelate,
+		},
+		"export": {
+			usage: "[flags] [uid...]",
+			short: "export position changes or snapshots as CSV or Parquet",
+			run:   runExport,
+		},
+		"leaders": {
+			usage: "[flags] [uid...]",
+			short: "detect traders copying others from traders recorded by watch --db",
+			run:   runLeaders,
+		},
+		"profile": {
+			usage: "[flags] <uid>",
+			short: "show a trader's leaderboard profile",
+			run:   runProfile,
+		},
+		"positions": {
+			usage: "[flags] <uid>",
+			short: "show a trader's currently open positions",
+			run:   runPositions,
+		},
+		"serve": {
+			usage: "[flags] [uid...]",
+			short: "serve watched traders over REST and Server-Sent Events",
+			run:   runServe,
+		},
+		"tui": {
+			usage: "[flags] <uid...>",
+			short: "monitor traders' positions and events in a live terminal dashboard",
+			run:   runTUI,
+		},
+		"watch": {
+			usage: "[flags] <uid...>",
+			short: "stream position changes of one or more traders",
+			run:   runWatch,
+		},
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "--help" {
+		usage()
+		return
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "bfldb: unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := cmd.run(ctx, os.Args[2:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+
+		fmt.Fprintln(os.Stderr, "bfldb:", err)
+		os.Exit(1)
+	}
+}
+
+// usage prints the list of available commands.
+func usage() {
+	names := make([]string, 0, len(commands))
+	for n := range commands {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "Usage: bfldb <command> [flags] [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, n := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", n, commands[n].short)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run `bfldb <command> -h` for the flags of a command.")
+}
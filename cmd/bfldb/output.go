@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// timeLayout is the layout used for printing timestamps in tables.
+const timeLayout = "2006-01-02 15:04:05"
+
+// printJSON writes v as indented JSON to STDOUT.
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// table writes tab-aligned rows.
+type table struct {
+	tw *tabwriter.Writer
+}
+
+// newTable creates a new table writing to w, with the header passed in.
+func newTable(w io.Writer, header ...string) *table {
+	t := &table{tw: tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)}
+	t.row(header...)
+	return t
+}
+
+// row writes a single row.
+func (t *table) row(cols ...string) {
+	fmt.Fprintln(t.tw, strings.Join(cols, "\t"))
+}
+
+// flush writes the table out.
+func (t *table) flush() error {
+	return t.tw.Flush()
+}
+
+// ftoa formats a float without trailing zeroes.
+func ftoa(f float64) string {
+	return fmt.Sprintf("%g", f)
+}
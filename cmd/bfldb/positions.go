@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// runPositions runs the positions command.
+func runPositions(ctx context.Context, args []string) error {
+	var g globalFlags
+	fs := newFlagSet("positions", commands["positions"].usage, &g)
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+
+	u, err := g.user(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	res, err := u.GetOtherPosition(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get positions: %w", err)
+	}
+	if !res.Success {
+		return fmt.Errorf("failed to get positions, bad response message: %v", res.Message)
+	}
+
+	if g.json {
+		return printJSON(res.Data)
+	}
+
+	t := newTable(os.Stdout, "SYMBOL", "SIDE", "AMOUNT", "ENTRY", "MARK", "PNL", "ROE", "LEVERAGE", "UPDATED")
+	for _, p := range res.Data.OtherPositionRetList {
+		side, amt := "LONG", p.Amount
+		if amt < 0 {
+			side, amt = "SHORT", -amt
+		}
+
+		t.row(
+			p.Symbol,
+			side,
+			ftoa(amt),
+			ftoa(p.EntryPrice),
+			ftoa(p.MarkPrice),
+			strconv.FormatFloat(p.Pnl, 'f', 2, 64),
+			strconv.FormatFloat(p.Roe*100, 'f', 2, 64)+"%",
+			strconv.Itoa(p.Leverage)+"x",
+			time.UnixMilli(p.UpdateTimeStamp).Format(timeLayout),
+		)
+	}
+	return t.flush()
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// runProfile runs the profile command.
+func runProfile(ctx context.Context, args []string) error {
+	var g globalFlags
+	fs := newFlagSet("profile", commands["profile"].usage, &g)
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+
+	u, err := g.user(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	res, err := u.GetOtherLeaderboardBaseInfo(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get profile: %w", err)
+	}
+	if !res.Success {
+		return fmt.Errorf("failed to get profile, bad response message: %v", res.Message)
+	}
+
+	if g.json {
+		return printJSON(res.Data)
+	}
+
+	d := res.Data
+	t := newTable(os.Stdout, "FIELD", "VALUE")
+	t.row("UID", u.UID)
+	t.row("Nickname", d.NickName)
+	t.row("Followers", strconv.Itoa(d.FollowerCount))
+	t.row("Following", strconv.Itoa(d.FollowingCount))
+	t.row("Positions shared", strconv.FormatBool(d.PositionShared))
+	t.row("Delivery positions shared", strconv.FormatBool(d.DeliveryPositionShared))
+	t.row("Twitter", d.TwitterURL)
+	t.row("Introduction", d.Introduction)
+	return t.flush()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// runSearch runs the search command.
+func runSearch(ctx context.Context, args []string) error {
+	var g globalFlags
+	fs := newFlagSet("search", commands["search"].usage, &g)
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+
+	res, err := c.SearchNickname(ctx, fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to search nickname: %w", err)
+	}
+	if !res.Success {
+		return fmt.Errorf("failed to search nickname, bad response message: %v", res.Message)
+	}
+
+	if g.json {
+		return printJSON(res.Data)
+	}
+
+	t := newTable(os.Stdout, "UID", "NICKNAME", "FOLLOWERS")
+	for _, d := range res.Data {
+		t.row(d.EncryptedUID, d.Nickname, strconv.Itoa(d.FollowerCount))
+	}
+	return t.flush()
+}
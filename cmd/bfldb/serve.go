@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+	"github.com/rtunazzz/bfldb/metrics"
+	"github.com/rtunazzz/bfldb/server"
+)
+
+// runServe runs the serve command.
+func runServe(ctx context.Context, args []string) error {
+	var g globalFlags
+	fs := newFlagSet("serve", commands["serve"].usage, &g)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	withMetrics := fs.Bool("metrics", false, "expose Prometheus metrics on /metrics")
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	m := metrics.New()
+	var opts []bfldb.ClientOption
+	if *withMetrics {
+		opts = append(opts, bfldb.WithClientObserver(m))
+	}
+
+	c, err := g.client(opts...)
+	if err != nil {
+		return err
+	}
+
+	w := bfldb.NewWatcher(c, bfldb.WithCustomRefresh(g.interval))
+	for _, uid := range fs.Args() {
+		if err := w.Add(uid); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", uid, err)
+		}
+	}
+
+	s := server.New(w, c)
+
+	mux := http.NewServeMux()
+	mux.Handle("/", s)
+	if *withMetrics {
+		mux.Handle("/metrics", m)
+	}
+
+	hs := &http.Server{Addr: *addr, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+
+		sCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		hs.Shutdown(sCtx)
+	}()
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- s.Run(ctx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "bfldb: listening on %s\n", *addr)
+	if err := hs.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	if err := <-errC; err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,599 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rtunazzz/bfldb"
+	"golang.org/x/term"
+)
+
+// Escape sequences used for drawing the dashboard.
+const (
+	enterScreen = "\x1b[?1049h\x1b[?25l" // switches to the alternate screen and hides the cursor
+	exitScreen  = "\x1b[?25h\x1b[?1049l" // shows the cursor and switches back to the main screen
+	cursorHome  = "\x1b[H"
+	clearLine   = "\x1b[K" // clears the rest of the line
+	clearBelow  = "\x1b[J" // clears the rest of the screen
+
+	styleReset   = "\x1b[0m"
+	styleBold    = "\x1b[1m"
+	styleDim     = "\x1b[2m"
+	styleReverse = "\x1b[7m"
+	styleRed     = "\x1b[31m"
+	styleGreen   = "\x1b[32m"
+	styleYellow  = "\x1b[33m"
+	styleCyan    = "\x1b[36m"
+)
+
+// typeStyles are the styles of events in the feed, by the type of their position.
+var typeStyles = map[bfldb.PositionType]string{
+	bfldb.Opened:          styleGreen,
+	bfldb.AddedTo:         styleCyan,
+	bfldb.PartiallyClosed: styleYellow,
+	bfldb.Closed:          styleRed,
+	bfldb.Existing:        styleDim,
+}
+
+// key is a key pressed on the dashboard.
+type key int
+
+const (
+	keyUnknown  key = iota
+	keyUp           // arrow up or k
+	keyDown         // arrow down or j
+	keyPageUp       // page up or u
+	keyPageDown     // page down or d
+	keyEnter        // enter, arrow right or l
+	keyBack         // escape, backspace, arrow left or h
+	keyQuit         // q or ctrl+c
+)
+
+// parseKey parses a key from the bytes read from a terminal in raw mode.
+func parseKey(b []byte) key {
+	switch string(b) {
+	case "\x1b[A", "\x1bOA", "k":
+		return keyUp
+	case "\x1b[B", "\x1bOB", "j":
+		return keyDown
+	case "\x1b[5~", "u":
+		return keyPageUp
+	case "\x1b[6~", "d":
+		return keyPageDown
+	case "\r", "\n", "\x1b[C", "\x1bOC", "l":
+		return keyEnter
+	case "\x1b", "\x7f", "\b", "\x1b[D", "\x1bOD", "h":
+		return keyBack
+	case "q", "Q", "\x03":
+		return keyQuit
+	default:
+		return keyUnknown
+	}
+}
+
+// parseKeys parses all keys from the bytes read from a terminal in raw mode. A single read can return several
+// keys, e.g. when a key is held down.
+func parseKeys(b []byte) []key {
+	var keys []key
+	for len(b) > 0 {
+		n := keyLen(b)
+		keys = append(keys, parseKey(b[:n]))
+		b = b[n:]
+	}
+	return keys
+}
+
+// keyLen returns the length of the key b starts with.
+func keyLen(b []byte) int {
+	if b[0] != '\x1b' || len(b) == 1 {
+		_, n := utf8.DecodeRune(b)
+		return n
+	}
+
+	switch b[1] {
+	case '[':
+		// control sequence, ended by a byte from @ to ~
+		for i := 2; i < len(b); i++ {
+			if b[i] >= '@' && b[i] <= '~' {
+				return i + 1
+			}
+		}
+		return len(b)
+	case 'O':
+		if len(b) > 2 {
+			return 3
+		}
+		return 2
+	case '\x1b':
+		// escape on its own
+		return 1
+	default:
+		// alt with another key
+		return 2
+	}
+}
+
+// readKeys reads keys from r in a new goroutine. The channel is closed once reading fails.
+func readKeys(r io.Reader) <-chan key {
+	keys := make(chan key)
+
+	go func() {
+		defer close(keys)
+
+		buf := make([]byte, 32)
+		for {
+			n, err := r.Read(buf)
+			if err != nil {
+				return
+			}
+
+			for _, k := range parseKeys(buf[:n]) {
+				if k != keyUnknown {
+					keys <- k
+				}
+			}
+		}
+	}()
+
+	return keys
+}
+
+// runTUI runs the tui command.
+func runTUI(ctx context.Context, args []string) error {
+	var g globalFlags
+	fs := newFlagSet("tui", commands["tui"].usage, &g)
+	feed := fs.Int("feed", 500, "number of events kept in the event feed")
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+
+	in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(in) || !term.IsTerminal(out) {
+		return errors.New("tui: standard input and output must be a terminal")
+	}
+
+	users, err := g.users(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	d := newDashboard(*feed)
+	for _, u := range users {
+		d.traders = append(d.traders, &trader{user: u})
+	}
+
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return fmt.Errorf("failed to set up terminal: %w", err)
+	}
+	defer term.Restore(in, state)
+
+	fmt.Print(enterScreen)
+	defer fmt.Print(exitScreen)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for _, t := range d.traders {
+		go d.subscribe(ctx, t)
+	}
+	go d.fetchNicknames(ctx)
+
+	keys := readKeys(os.Stdin)
+
+	// redraw every second to keep the poll ages up to date
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		width, height, err := term.GetSize(out)
+		if err != nil {
+			width, height = 80, 24
+		}
+
+		if _, err := os.Stdout.Write(d.render(width, height)); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-d.changed:
+		case <-ticker.C:
+		case k, ok := <-keys:
+			if !ok || k == keyQuit {
+				return nil
+			}
+			d.handle(k)
+		}
+	}
+}
+
+// trader is a single trader shown on the dashboard.
+type trader struct {
+	user     *bfldb.User // the trader being watched
+	nickname string      // leaderboard nickname, empty until fetched
+	lastPoll time.Time   // time of the latest poll, zero if none finished yet
+	err      error       // error of the latest poll, nil if it succeeded
+	errors   int         // number of errors since watching started
+}
+
+// name returns trader's nickname, or their UID if it's not known.
+func (t *trader) name() string {
+	if t.nickname != "" {
+		return t.nickname
+	}
+	return t.user.UID
+}
+
+// feedEntry is a single position change shown in the event feed.
+type feedEntry struct {
+	uid      string         // encrypted UID of the trader
+	time     time.Time      // when the change was received
+	position bfldb.Position // the changed position
+}
+
+// dashboard is the state of the tui command.
+type dashboard struct {
+	mtx      sync.Mutex
+	traders  []*trader     // watched traders, in the order they were passed in
+	feed     []feedEntry   // latest position changes of all traders, the newest last
+	feedSize int           // maximum number of entries of the feed
+	selected int           // index of the selected trader
+	detail   bool          // whether or not the selected trader is drilled into
+	scroll   int           // number of feed entries scrolled back from the newest one
+	changed  chan struct{} // signalled whenever the dashboard should be redrawn
+}
+
+// newDashboard creates a new dashboard keeping up to feedSize events in its feed.
+func newDashboard(feedSize int) *dashboard {
+	return &dashboard{
+		feedSize: feedSize,
+		changed:  make(chan struct{}, 1),
+	}
+}
+
+// notify requests a redraw, without blocking if one is already requested.
+func (d *dashboard) notify() {
+	select {
+	case d.changed <- struct{}{}:
+	default:
+	}
+}
+
+// subscribe subscribes to trader's positions and updates the dashboard with their events until the context
+// is cancelled.
+func (d *dashboard) subscribe(ctx context.Context, t *trader) {
+	for e := range t.user.Subscribe(ctx) {
+		d.mtx.Lock()
+		switch e := e.(type) {
+		case bfldb.PositionChanged:
+			d.push(feedEntry{uid: e.UID, time: e.Time, position: e.Position})
+		case bfldb.Snapshot:
+			for _, p := range e.Positions {
+				d.push(feedEntry{uid: e.UID, time: e.Time, position: p})
+			}
+		case bfldb.Heartbeat:
+			t.lastPoll, t.err = e.Time, nil
+		case bfldb.ErrorEvent:
+			t.lastPoll, t.err = e.Time, e.Err
+			t.errors++
+		}
+		d.mtx.Unlock()
+
+		d.notify()
+	}
+}
+
+// fetchNicknames fetches nicknames of all traders one after another. Errors are ignored, since the nickname
+// is optional.
+func (d *dashboard) fetchNicknames(ctx context.Context) {
+	for _, t := range d.traders {
+		res, err := t.user.GetOtherLeaderboardBaseInfo(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil || !res.Success {
+			continue
+		}
+
+		d.mtx.Lock()
+		t.nickname = res.Data.NickName
+		d.mtx.Unlock()
+
+		d.notify()
+	}
+}
+
+// push adds an entry to the feed, dropping the oldest one if it's full. d.mtx must be held.
+func (d *dashboard) push(e feedEntry) {
+	// keep the entries shown in place while scrolled back
+	if d.scroll > 0 && (!d.detail || e.uid == d.traders[d.selected].user.UID) {
+		d.scroll++
+	}
+
+	d.feed = append(d.feed, e)
+	if n := len(d.feed) - d.feedSize; n > 0 {
+		d.feed = append(d.feed[:0:0], d.feed[n:]...)
+	}
+}
+
+// handle handles a key pressed.
+func (d *dashboard) handle(k key) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	switch k {
+	case keyUp:
+		if d.selected > 0 {
+			d.selected--
+			d.scrollReset()
+		}
+	case keyDown:
+		if d.selected < len(d.traders)-1 {
+			d.selected++
+			d.scrollReset()
+		}
+	case keyPageUp:
+		d.scroll += 10
+	case keyPageDown:
+		if d.scroll -= 10; d.scroll < 0 {
+			d.scroll = 0
+		}
+	case keyEnter:
+		d.detail = true
+		d.scroll = 0
+	case keyBack:
+		d.detail = false
+		d.scroll = 0
+	}
+}
+
+// scrollReset scrolls the feed back to the newest entry if it only shows events of the selected trader.
+func (d *dashboard) scrollReset() {
+	if d.detail {
+		d.scroll = 0
+	}
+}
+
+// line is a single line of the dashboard.
+type line struct {
+	text  string // plain text of the line
+	style string // escape sequence the whole line is styled with, if any
+}
+
+// render draws the whole dashboard into a frame of the size passed in.
+func (d *dashboard) render(width, height int) []byte {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	var lines []line
+	if d.detail {
+		lines = d.renderTrader(height)
+	} else {
+		lines = d.renderOverview(height)
+	}
+
+	var b bytes.Buffer
+	b.WriteString(cursorHome)
+	for i, l := range lines {
+		if i >= height {
+			break
+		}
+		if i > 0 {
+			// the terminal is in raw mode, so new lines don't return the cursor
+			b.WriteString("\r\n")
+		}
+
+		text := truncate(l.text, width)
+		if l.style != "" {
+			text = l.style + text + styleReset
+		}
+		b.WriteString(text + clearLine)
+	}
+	b.WriteString(clearBelow)
+
+	return b.Bytes()
+}
+
+// renderOverview renders the list of all traders and the event feed of all of them.
+func (d *dashboard) renderOverview(height int) []line {
+	var positions, erroring int
+	for _, t := range d.traders {
+		positions += len(t.user.Positions())
+		if t.err != nil {
+			erroring++
+		}
+	}
+
+	lines := []line{
+		{text: fmt.Sprintf("bfldb - %d traders, %d open positions, %d erroring  %s", len(d.traders), positions, erroring, time.Now().Format(timeLayout)), style: styleBold},
+		{},
+	}
+
+	// up to half of the screen for the traders, keeping the selected one visible
+	rows := (height - 6) / 2
+	if rows < 1 {
+		rows = 1
+	}
+	from := 0
+	if d.selected >= rows {
+		from = d.selected - rows + 1
+	}
+
+	tbl := [][]string{{"", "TRADER", "UID", "POSITIONS", "PNL", "LAST POLL", "ERRORS", "STATUS"}}
+	styles := []string{styleBold}
+	for i := from; i < len(d.traders) && i < from+rows; i++ {
+		t := d.traders[i]
+
+		ps := t.user.Positions()
+		var pnl float64
+		for _, p := range ps {
+			pnl += p.Pnl
+		}
+
+		cursor, style := " ", ""
+		if i == d.selected {
+			cursor, style = ">", styleReverse
+		}
+		if t.err != nil {
+			style += styleRed
+		}
+
+		tbl = append(tbl, []string{cursor, t.name(), t.user.UID, strconv.Itoa(len(ps)), fmt.Sprintf("%.2f", pnl), age(t.lastPoll), strconv.Itoa(t.errors), status(t)})
+		styles = append(styles, style)
+	}
+	lines = append(lines, columns(tbl, styles)...)
+	lines = append(lines, line{})
+
+	lines = append(lines, d.renderFeed(d.feed, height-len(lines)-1)...)
+
+	return append(lines, line{text: "up/down select  enter open trader  pgup/pgdn scroll events  q quit", style: styleDim})
+}
+
+// renderTrader renders the open positions and the event feed of the selected trader.
+func (d *dashboard) renderTrader(height int) []line {
+	t := d.traders[d.selected]
+
+	ps := t.user.Positions()
+	sort.Slice(ps, func(i, j int) bool {
+		if ps[i].Ticker != ps[j].Ticker {
+			return ps[i].Ticker < ps[j].Ticker
+		}
+		return ps[i].Direction < ps[j].Direction
+	})
+
+	st := line{text: "Status: " + status(t)}
+	if t.err != nil {
+		st.style = styleRed
+	}
+
+	lines := []line{
+		{text: fmt.Sprintf("%s (%s) - %d open positions, last poll %s ago, %d errors  %s", t.name(), t.user.UID, len(ps), age(t.lastPoll), t.errors, time.Now().Format(timeLayout)), style: styleBold},
+		st,
+		{},
+	}
+
+	tbl := [][]string{{"SYMBOL", "SIDE", "SIZE", "ENTRY", "MARK", "ROE", "PNL", "LEVERAGE"}}
+	styles := []string{styleBold}
+	for _, p := range ps {
+		tbl = append(tbl, []string{p.Ticker, p.Direction.String(), ftoa(p.Amount), ftoa(p.EntryPrice), ftoa(p.MarkPrice), fmt.Sprintf("%.2f%%", p.Roe*100), fmt.Sprintf("%.2f", p.Pnl), strconv.Itoa(p.Leverage) + "x"})
+		styles = append(styles, "")
+	}
+	lines = append(lines, columns(tbl, styles)...)
+	lines = append(lines, line{})
+
+	var feed []feedEntry
+	for _, e := range d.feed {
+		if e.uid == t.user.UID {
+			feed = append(feed, e)
+		}
+	}
+	lines = append(lines, d.renderFeed(feed, height-len(lines)-1)...)
+
+	return append(lines, line{text: "up/down previous/next trader  pgup/pgdn scroll events  esc back  q quit", style: styleDim})
+}
+
+// renderFeed renders the entries of the feed fitting into the height passed in, the newest last.
+func (d *dashboard) renderFeed(feed []feedEntry, height int) []line {
+	rows := height - 2
+	if rows < 1 {
+		return nil
+	}
+
+	// keep at least a page of entries while scrolled back
+	if last := len(feed) - rows; d.scroll > last {
+		d.scroll = last
+	}
+	if d.scroll < 0 {
+		d.scroll = 0
+	}
+
+	to := len(feed) - d.scroll
+	from := to - rows
+	if from < 0 {
+		from = 0
+	}
+
+	title := "EVENTS"
+	if d.scroll > 0 {
+		title += fmt.Sprintf(" (scrolled back by %d)", d.scroll)
+	}
+
+	tbl := [][]string{{"TIME", "TRADER", "TYPE", "SIDE", "SYMBOL", "AMOUNT", "ENTRY", "MARK", "LEVERAGE"}}
+	styles := []string{styleBold}
+	for _, e := range feed[from:to] {
+		p := e.position
+		tbl = append(tbl, []string{e.time.Format("15:04:05"), d.name(e.uid), p.Type.String(), p.Direction.String(), p.Ticker, ftoa(p.PrevAmount) + " -> " + ftoa(p.Amount), ftoa(p.EntryPrice), ftoa(p.MarkPrice), strconv.Itoa(p.Leverage) + "x"})
+		styles = append(styles, typeStyles[p.Type])
+	}
+
+	return append([]line{{text: title, style: styleBold}}, columns(tbl, styles)...)
+}
+
+// name returns the name of the trader with the UID passed in.
+func (d *dashboard) name(uid string) string {
+	for _, t := range d.traders {
+		if t.user.UID == uid {
+			return t.name()
+		}
+	}
+	return uid
+}
+
+// columns aligns the columns of the rows, styling every line with the style of its row.
+func columns(rows [][]string, styles []string) []line {
+	var b bytes.Buffer
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	for _, r := range rows {
+		fmt.Fprintln(tw, strings.Join(r, "\t"))
+	}
+	tw.Flush()
+
+	texts := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	lines := make([]line, len(texts))
+	for i, text := range texts {
+		lines[i] = line{text: text, style: styles[i]}
+	}
+
+	return lines
+}
+
+// truncate cuts the text to fit into the width passed in.
+func truncate(s string, width int) string {
+	if r := []rune(s); len(r) > width {
+		return string(r[:width])
+	}
+	return s
+}
+
+// age returns how long ago the time was, rounded to seconds, or "-" if it's zero.
+func age(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return time.Since(t).Truncate(time.Second).String()
+}
+
+// status describes the state of trader's latest poll.
+func status(t *trader) string {
+	switch {
+	case t.err != nil:
+		return t.err.Error()
+	case t.lastPoll.IsZero():
+		return "waiting"
+	default:
+		return "ok"
+	}
+}
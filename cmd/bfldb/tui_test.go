@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestDashboard creates a dashboard of traders A and B.
+func newTestDashboard(feedSize int) *dashboard {
+	c := bfldb.NewClient()
+
+	d := newDashboard(feedSize)
+	d.traders = []*trader{{user: c.NewUser("A")}, {user: c.NewUser("B")}}
+
+	return d
+}
+
+// entry creates a feed entry of the trader, received the number of seconds passed in after midnight.
+func entry(uid string, sec int) feedEntry {
+	return feedEntry{
+		uid:      uid,
+		time:     time.Date(2023, 1, 1, 0, 0, sec, 0, time.UTC),
+		position: bfldb.Position{Type: bfldb.Opened, Direction: bfldb.Long, Ticker: "BTCUSDT", Amount: 1},
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []key
+	}{
+		{in: "j", want: []key{keyDown}},
+		{in: "jj", want: []key{keyDown, keyDown}},
+		{in: "kq", want: []key{keyUp, keyQuit}},
+		{in: "\x1b[A\x1b[B", want: []key{keyUp, keyDown}},
+		{in: "\x1bOA\x1bOC", want: []key{keyUp, keyEnter}},
+		{in: "\x1b[5~\x1b[6~", want: []key{keyPageUp, keyPageDown}},
+		{in: "\x1b", want: []key{keyBack}},
+		{in: "\x1b\x1b[D", want: []key{keyBack, keyBack}},
+		{in: "\r\x7f", want: []key{keyEnter, keyBack}},
+		{in: "\x03", want: []key{keyQuit}},
+		{in: "x", want: []key{keyUnknown}},
+		{in: "\x1bj", want: []key{keyUnknown}},
+		{in: "éj", want: []key{keyUnknown, keyDown}},
+		{in: "\x1b[1;5Aj", want: []key{keyUnknown, keyDown}},
+		{in: "", want: nil},
+	}
+
+	for _, tt := range tests {
+		require.Equalf(t, tt.want, parseKeys([]byte(tt.in)), "%q", tt.in)
+	}
+}
+
+func TestDashboard_Scroll(t *testing.T) {
+	tests := []struct {
+		name     string
+		detail   bool
+		scroll   int
+		keys     []key
+		push     []string
+		want     int
+		selected int
+	}{
+		{name: "not scrolled", push: []string{"A", "B"}, want: 0},
+		{name: "scrolled back", scroll: 2, push: []string{"A", "B"}, want: 4},
+		{name: "scrolled back on a trader", detail: true, scroll: 2, push: []string{"A", "B", "A"}, want: 4},
+		{name: "page up", keys: []key{keyPageUp, keyPageUp}, want: 20},
+		{name: "page down", scroll: 15, keys: []key{keyPageDown, keyPageDown}, want: 0},
+		{name: "next trader", scroll: 5, keys: []key{keyDown}, want: 5, selected: 1},
+		{name: "next trader on a trader", detail: true, scroll: 5, keys: []key{keyDown}, want: 0, selected: 1},
+		{name: "last trader", detail: true, scroll: 5, keys: []key{keyUp}, want: 5},
+		{name: "open trader", scroll: 5, keys: []key{keyEnter}, want: 0},
+		{name: "back", detail: true, scroll: 5, keys: []key{keyBack}, want: 0},
+	}
+
+	for _, tt := range tests {
+		d := newTestDashboard(100)
+		d.detail, d.scroll = tt.detail, tt.scroll
+
+		for _, k := range tt.keys {
+			d.handle(k)
+		}
+		for i, uid := range tt.push {
+			d.push(entry(uid, i))
+		}
+
+		require.Equal(t, tt.want, d.scroll, tt.name)
+		require.Equal(t, tt.selected, d.selected, tt.name)
+	}
+}
+
+func TestDashboard_PushFull(t *testing.T) {
+	d := newTestDashboard(3)
+	for i := 0; i < 5; i++ {
+		d.push(entry("A", i))
+	}
+
+	require.Equal(t, []feedEntry{entry("A", 2), entry("A", 3), entry("A", 4)}, d.feed)
+}
+
+func TestDashboard_RenderFeed(t *testing.T) {
+	var feed []feedEntry
+	for i := 1; i <= 5; i++ {
+		feed = append(feed, entry("A", i))
+	}
+
+	tests := []struct {
+		height     int
+		scroll     int
+		wantTitle  string
+		wantTimes  []string
+		wantScroll int
+	}{
+		{height: 5, wantTitle: "EVENTS", wantTimes: []string{"00:00:03", "00:00:04", "00:00:05"}},
+		{height: 5, scroll: 1, wantTitle: "EVENTS (scrolled back by 1)", wantTimes: []string{"00:00:02", "00:00:03", "00:00:04"}, wantScroll: 1},
+		{height: 5, scroll: 10, wantTitle: "EVENTS (scrolled back by 2)", wantTimes: []string{"00:00:01", "00:00:02", "00:00:03"}, wantScroll: 2},
+		{height: 10, scroll: 3, wantTitle: "EVENTS", wantTimes: []string{"00:00:01", "00:00:02", "00:00:03", "00:00:04", "00:00:05"}},
+		{height: 2},
+	}
+
+	for _, tt := range tests {
+		name := fmt.Sprintf("height %d, scroll %d", tt.height, tt.scroll)
+
+		d := newTestDashboard(100)
+		d.scroll = tt.scroll
+
+		lines := d.renderFeed(feed, tt.height)
+		require.Equal(t, tt.wantScroll, d.scroll, name)
+		if tt.wantTitle == "" {
+			require.Empty(t, lines, name)
+			continue
+		}
+
+		require.Equal(t, line{text: tt.wantTitle, style: styleBold}, lines[0], name)
+		require.True(t, strings.HasPrefix(lines[1].text, "TIME"), name)
+
+		var times []string
+		for _, l := range lines[2:] {
+			require.Equal(t, styleGreen, l.style, name)
+			times = append(times, strings.Fields(l.text)[0])
+		}
+		require.Equal(t, tt.wantTimes, times, name)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{s: "hello", width: 10, want: "hello"},
+		{s: "hello", width: 5, want: "hello"},
+		{s: "hello", width: 3, want: "hel"},
+		{s: "héllo", width: 2, want: "hé"},
+		{s: "hello", width: 0, want: ""},
+		{s: "", width: 3, want: ""},
+	}
+
+	for _, tt := range tests {
+		require.Equalf(t, tt.want, truncate(tt.s, tt.width), "%q %d", tt.s, tt.width)
+	}
+}
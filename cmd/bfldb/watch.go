@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+	"github.com/rtunazzz/bfldb/export"
+	"github.com/rtunazzz/bfldb/storage"
+)
+
+// runWatch runs the watch command.
+func runWatch(ctx context.Context, args []string) error {
+	var g globalFlags
+	fs := newFlagSet("watch", commands["watch"].usage, &g)
+	snapshot := fs.Bool("snapshot", false, "print positions that are already open when watching starts")
+	dbPath := fs.String("db", "", "path to a SQLite database to record profiles, snapshots, position and sharing changes into")
+	sharingCheck := fs.Duration("sharing-check", time.Minute, "how often to check whether traders are sharing their positions when recording with --db, 0 disables it")
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+
+	var st *storage.Store
+	if *dbPath != "" {
+		var err error
+		if st, err = storage.Open(*dbPath); err != nil {
+			return err
+		}
+		defer st.Close()
+	}
+
+	var opts []bfldb.UserOption
+	if *snapshot {
+		opts = append(opts, bfldb.WithInitialSnapshot())
+	}
+	if st != nil && *sharingCheck > 0 {
+		opts = append(opts, bfldb.WithSharingCheck(*sharingCheck))
+	}
+
+	users, err := g.users(fs.Args(), opts...)
+	if err != nil {
+		return err
+	}
+
+	events := make(chan export.Record)
+	var wg sync.WaitGroup
+
+	for _, u := range users {
+		u := u
+
+		if st != nil {
+			recordProfile(ctx, st, u)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for e := range u.Subscribe(ctx) {
+				if st != nil {
+					if err := st.Save(ctx, e); err != nil {
+						fmt.Fprintf(os.Stderr, "bfldb: [%s] %v\n", u.UID, err)
+					}
+				}
+
+				switch e := e.(type) {
+				case bfldb.PositionChanged:
+					events <- export.NewRecord(u.UID, "", e.Time, e.Position)
+				case bfldb.Snapshot:
+					for _, p := range e.Positions {
+						events <- export.NewRecord(u.UID, "", e.Time, p)
+					}
+				case bfldb.ErrorEvent:
+					fmt.Fprintf(os.Stderr, "bfldb: [%s] %v\n", u.UID, e.Err)
+				}
+			}
+		}()
+	}
+
+	// close the channel once all of the subscriptions are done
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+
+	if g.json {
+		enc := json.NewEncoder(os.Stdout)
+		for e := range events {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	const rowFmt = "%-19s  %-32s  %-16s  %-5s  %-12s  %-24s  %-12s  %-12s  %s\n"
+	fmt.Printf(rowFmt, "TIME", "UID", "TYPE", "SIDE", "SYMBOL", "AMOUNT", "ENTRY", "MARK", "LEVERAGE")
+
+	for e := range events {
+		fmt.Printf(rowFmt,
+			e.Time.Format(timeLayout),
+			e.UID,
+			e.Type.String(),
+			e.Direction.String(),
+			e.Ticker,
+			ftoa(e.PrevAmount)+" -> "+ftoa(e.Amount),
+			ftoa(e.EntryPrice),
+			ftoa(e.MarkPrice),
+			strconv.Itoa(e.Leverage)+"x",
+		)
+	}
+
+	return nil
+}
+
+// recordProfile fetches user's profile and saves it into the store. Errors are only reported,
+// since the profile isn't needed for watching.
+func recordProfile(ctx context.Context, st *storage.Store, u *bfldb.User) {
+	res, err := u.GetOtherLeaderboardBaseInfo(ctx)
+	if err == nil && !res.Success {
+		err = fmt.Errorf("bad response message: %v", res.Message)
+	}
+	if err == nil {
+		err = st.SaveProfile(ctx, u.UID, time.Now(), res.Data)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bfldb: [%s] failed to record profile: %v\n", u.UID, err)
+	}
+}
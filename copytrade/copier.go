@@ -0,0 +1,447 @@
+package copytrade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/rtunazzz/bfldb"
+)
+
+var (
+	ErrNotCopied = errors.New("position of the trader is not copied")
+)
+
+// symbolQueueSize is the amount of position changes queued for a symbol by Run.
+const symbolQueueSize = 64
+
+// Executor executes orders on your own account, e.g. through an exchange API.
+type Executor interface {
+	// Execute executes the order. The order counts as filled in full if no error is returned.
+	Execute(ctx context.Context, o bfldb.Order) error
+}
+
+// Trader is a user whose positions are copied.
+type Trader struct {
+	UID        string    // Encrypted UID of the trader
+	Allocation float64   // Share of trader's amounts copied, e.g. 0.1 copies 10% of every position
+	Transform  Transform // Transforms position changes before they're copied, e.g. Inverse, nil copies them as they are
+}
+
+// Execution is the outcome of copying a single position change.
+type Execution struct {
+	UID      string         // Encrypted UID of the trader the position belongs to
+	Position bfldb.Position // Position change copied
+	Decision Decision       // Decision of the risk check, empty if no order was created
+	Err      error          // Error the order failed with, ErrNotCopied if there was nothing to copy or it was filtered out
+
+	ProtectErr error // Error updating the protective orders failed with, see WithProtector
+}
+
+// Executed returns whether or not an order was executed.
+func (e Execution) Executed() bool {
+	return e.Err == nil && e.Decision.Action != Reject
+}
+
+// Copier copies position changes of traders into orders of your own account.
+//
+// Orders are sized by the allocation of the trader, checked by a RiskManager and executed by an Executor.
+// The Copier keeps track of which share of every position of the account came from which trader, so when a trader
+// closes their position, only their share is closed.
+//
+// Orders of the same symbol are created and executed one at a time, while orders of different symbols
+// don't wait for each other.
+type Copier struct {
+	mtx      sync.Mutex                    // Synchronization for shares, symbols, executed and stopped
+	traders  map[string]Trader             // copied traders, mapped by their UID, not changed once created
+	shares   map[string]map[string]float64 // signed amounts copied by symbol and trader's UID, positive for long
+	symbols  map[string]*sync.Mutex        // held while an order of the symbol is created and executed
+	executed map[string]int                // number of orders executed by symbol
+	stopped  map[string]bool               // symbols closed by protective orders, left flat by the Reconciler
+
+	exec    Executor           // executor executing all orders
+	risk    *RiskManager       // risk manager checking all orders
+	protect *Protector         // protector of the positions, nil if not set
+	client  *bfldb.Client      // client used to create the watcher
+	opts    []bfldb.UserOption // options applied to every trader
+	watcher *bfldb.Watcher     // watcher subscribing to the positions of all traders
+	log     *log.Logger        // logger every execution is logged with
+	handler func(Execution)    // called with every execution, nil if not set
+}
+
+type CopierOption func(*Copier)
+
+// NewCopier creates a new Copier copying the traders passed in and executing orders with exec.
+//
+// Without WithRiskManager, all orders are allowed.
+func NewCopier(exec Executor, traders []Trader, opts ...CopierOption) *Copier {
+	c := Copier{
+		traders:  make(map[string]Trader, len(traders)),
+		shares:   make(map[string]map[string]float64),
+		symbols:  make(map[string]*sync.Mutex),
+		executed: make(map[string]int),
+		stopped:  make(map[string]bool),
+		exec:     exec,
+		risk:     NewRiskManager(),
+		log:      log.New(io.Discard, "", 0),
+	}
+
+	for _, t := range traders {
+		c.traders[t.UID] = t
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	c.watcher = bfldb.NewWatcher(c.client, c.opts...)
+	for uid := range c.traders {
+		c.watcher.Add(uid)
+	}
+
+	return &c
+}
+
+// Run subscribes to the positions of all traders and copies their changes until the context is cancelled.
+//
+// Position changes of different symbols are copied concurrently, so a slow order doesn't hold up the others,
+// while position changes of the same symbol are copied in order.
+//
+// Run should only be called once.
+func (c *Copier) Run(ctx context.Context) error {
+	w := c.watcher
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- w.Run(ctx)
+	}()
+
+	var wg sync.WaitGroup
+	queues := make(map[string]chan bfldb.WatchEvent)
+	defer func() {
+		for _, q := range queues {
+			close(q)
+		}
+		wg.Wait()
+	}()
+
+	for ev := range w.Events() {
+		if ev.Err != nil {
+			c.log.Printf("[%s] subscription error: %v\n", ev.UID, ev.Err)
+			continue
+		}
+
+		q, ok := queues[ev.Position.Ticker]
+		if !ok {
+			q = make(chan bfldb.WatchEvent, symbolQueueSize)
+			queues[ev.Position.Ticker] = q
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for ev := range q {
+					c.Copy(ctx, ev.UID, ev.Position)
+				}
+			}()
+		}
+
+		select {
+		case q <- ev:
+		case <-ctx.Done():
+		}
+	}
+
+	return <-errc
+}
+
+// Copy copies a single position change of the trader with the UID passed in.
+//
+// Opened and AddedTo positions open or add to the position of the account, sized by trader's allocation.
+// PartiallyClosed and Closed positions close the same share of what was copied from the trader.
+// Existing positions aren't copied, as they were opened before the trader was followed.
+// Position changes are transformed by trader's Transform first, and not copied if it filters them out.
+//
+// Copy can be called concurrently. The execution is logged and passed to the handler once the order is done.
+func (c *Copier) Copy(ctx context.Context, UID string, p bfldb.Position) Execution {
+	e := c.copy(ctx, UID, p)
+	c.report(e)
+	return e
+}
+
+// copy copies the position change, holding the lock of its symbol until the order is executed.
+func (c *Copier) copy(ctx context.Context, UID string, p bfldb.Position) Execution {
+	e := Execution{UID: UID, Position: p}
+
+	t, ok := c.traders[UID]
+	if ok && t.Transform != nil {
+		p, ok = t.Transform(p)
+	}
+	if !ok || t.Allocation <= 0 {
+		e.Err = ErrNotCopied
+		return e
+	}
+
+	unlock := c.lockSymbol(p.Ticker)
+	defer unlock()
+
+	c.mtx.Lock()
+	o, ok := c.order(t, p)
+	c.mtx.Unlock()
+	if !ok {
+		e.Err = ErrNotCopied
+		return e
+	}
+
+	e.Decision = c.risk.Check(o)
+	if e.Decision.Action == Reject {
+		return e
+	}
+
+	o = e.Decision.Order
+	if err := c.exec.Execute(ctx, o); err != nil {
+		e.Err = fmt.Errorf("failed to execute order: %w", err)
+		return e
+	}
+
+	c.risk.Filled(o)
+
+	c.mtx.Lock()
+	c.addShare(o.Ticker, UID, signedAmount(o.Direction, o.Amount))
+	c.executed[o.Ticker]++
+	c.mtx.Unlock()
+
+	e.ProtectErr = c.protected(ctx, o)
+
+	return e
+}
+
+// Protected records that the protective order passed in was triggered and filled at its trigger price,
+// closing the position of its symbol, e.g. once the exchange reports the fill.
+//
+// Shares of all traders in the symbol are cleared, so their closes aren't copied anymore, and the exposure
+// and realized PNL are reported to the risk manager. The Reconciler leaves the symbol flat until none of
+// the traders are in a position of it anymore.
+func (c *Copier) Protected(ctx context.Context, po ProtectiveOrder) error {
+	unlock := c.lockSymbol(po.Ticker)
+	defer unlock()
+
+	c.risk.Filled(bfldb.Order{Direction: po.Direction, Ticker: po.Ticker, Amount: po.Amount, ReduceOnly: true, Price: po.TriggerPrice})
+
+	c.mtx.Lock()
+	delete(c.shares, po.Ticker)
+	c.executed[po.Ticker]++
+	c.stopped[po.Ticker] = true
+	c.mtx.Unlock()
+
+	if c.protect == nil {
+		return nil
+	}
+
+	if err := c.protect.Triggered(ctx, po); err != nil {
+		return fmt.Errorf("failed to update protective orders: %w", err)
+	}
+
+	return nil
+}
+
+// lockSymbol locks the symbol, so no other orders of it are created or executed until the function returned
+// is called. c.mtx must not be held by the caller.
+func (c *Copier) lockSymbol(symbol string) func() {
+	c.mtx.Lock()
+	m, ok := c.symbols[symbol]
+	if !ok {
+		m = new(sync.Mutex)
+		c.symbols[symbol] = m
+	}
+	c.mtx.Unlock()
+
+	m.Lock()
+	return m.Unlock
+}
+
+// order creates the order copying the transformed position change of the trader. c.mtx has to be held by the caller.
+func (c *Copier) order(t Trader, p bfldb.Position) (bfldb.Order, bool) {
+	o := p.ToOrder()
+
+	switch p.Type {
+	case bfldb.Opened, bfldb.AddedTo:
+		o.Amount *= t.Allocation
+
+	case bfldb.PartiallyClosed, bfldb.Closed:
+		share := c.shares[p.Ticker][t.UID]
+		if share == 0 {
+			return bfldb.Order{}, false
+		}
+
+		// close the same part of our share as the trader closed of their position
+		part := 1.0
+		if p.Type == bfldb.PartiallyClosed && p.PrevAmount > 0 {
+			part = (p.PrevAmount - p.Amount) / p.PrevAmount
+		}
+
+		o.Amount = math.Abs(share) * part
+		o.Direction = bfldb.Long
+		if share > 0 {
+			o.Direction = bfldb.Short
+		}
+
+		// shares of other traders might be in the opposite direction, in which case closing
+		// this share opens a new position
+		net := c.net(p.Ticker)
+		o.ReduceOnly = net*share > 0 && math.Abs(net) >= o.Amount
+
+	default:
+		return bfldb.Order{}, false
+	}
+
+	return o, o.Amount > 0
+}
+
+// addShare adds the signed amount to the share of the trader. c.mtx has to be held by the caller.
+func (c *Copier) addShare(symbol, UID string, amount float64) {
+	shares, ok := c.shares[symbol]
+	if !ok {
+		shares = make(map[string]float64)
+		c.shares[symbol] = shares
+	}
+
+	share := shares[UID] + amount
+	if math.Abs(share) < 1e-12 {
+		delete(shares, UID)
+	} else {
+		shares[UID] = share
+	}
+
+	if len(shares) == 0 {
+		delete(c.shares, symbol)
+	}
+}
+
+// net returns the signed amount of the symbol copied from all traders. c.mtx has to be held by the caller.
+func (c *Copier) net(symbol string) float64 {
+	var net float64
+	for _, share := range c.shares[symbol] {
+		net += share
+	}
+	return net
+}
+
+// protected updates the protective orders with the executed order, if there's a protector.
+func (c *Copier) protected(ctx context.Context, o bfldb.Order) error {
+	if c.protect == nil {
+		return nil
+	}
+
+	if err := c.protect.Filled(ctx, o); err != nil {
+		return fmt.Errorf("failed to update protective orders: %w", err)
+	}
+
+	return nil
+}
+
+// report logs the execution and passes it to the handler. No locks may be held by the caller.
+func (c *Copier) report(e Execution) {
+	p := e.Position
+	switch {
+	case e.Err != nil:
+		c.log.Printf("[%s] %s %s %s: %v\n", e.UID, p.Type, p.Direction, p.Ticker, e.Err)
+	default:
+		o := e.Decision.Order
+		c.log.Printf("[%s] %s %s %s: %s %s %g %s\n", e.UID, p.Type, p.Direction, p.Ticker, e.Decision.Action, o.Direction, o.Amount, o.Ticker)
+	}
+
+	if e.ProtectErr != nil {
+		c.log.Printf("[%s] %s %s %s: %v\n", e.UID, p.Type, p.Direction, p.Ticker, e.ProtectErr)
+	}
+
+	if c.handler != nil {
+		c.handler(e)
+	}
+}
+
+// Shares returns the signed amounts of the symbol copied from each trader, mapped by their UID.
+// Amounts are positive for long and negative for short.
+func (c *Copier) Shares(symbol string) map[string]float64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	shares := make(map[string]float64, len(c.shares[symbol]))
+	for uid, share := range c.shares[symbol] {
+		shares[uid] = share
+	}
+
+	return shares
+}
+
+// Net returns the signed amount of the symbol copied from all traders, positive for long and negative for short.
+func (c *Copier) Net(symbol string) float64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.net(symbol)
+}
+
+// UIDs returns UIDs of all copied traders, sorted.
+func (c *Copier) UIDs() []string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	uids := make([]string, 0, len(c.traders))
+	for uid := range c.traders {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+
+	return uids
+}
+
+// WithRiskManager checks all orders with the risk manager passed in before they're executed.
+func WithRiskManager(rm *RiskManager) CopierOption {
+	return func(c *Copier) {
+		c.risk = rm
+	}
+}
+
+// WithProtector keeps protective orders attached to the positions of the account with the protector passed in,
+// updating them after every order executed. Triggered protective orders have to be reported with Protected.
+func WithProtector(pr *Protector) CopierOption {
+	return func(c *Copier) {
+		c.protect = pr
+	}
+}
+
+// WithClient creates traders with the client passed in. If not set, a new Client with the default configuration is used.
+func WithClient(cl *bfldb.Client) CopierOption {
+	return func(c *Copier) {
+		c.client = cl
+	}
+}
+
+// WithUserOptions applies the options passed in to every trader, e.g. to change how often positions are polled.
+func WithUserOptions(opts ...bfldb.UserOption) CopierOption {
+	return func(c *Copier) {
+		c.opts = append(c.opts, opts...)
+	}
+}
+
+// WithCopierLogger sets the logger every execution is logged with. Executions aren't logged by default.
+func WithCopierLogger(l *log.Logger) CopierOption {
+	return func(c *Copier) {
+		c.log = l
+	}
+}
+
+// WithExecutionHandler calls fn with every execution, including rejected and failed ones.
+//
+// fn is called once the order is done, without holding any locks, so it may call methods of the Copier.
+// Executions of different symbols are passed to fn concurrently.
+func WithExecutionHandler(fn func(Execution)) CopierOption {
+	return func(c *Copier) {
+		c.handler = fn
+	}
+}
@@ -0,0 +1,162 @@
+package copytrade
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/rtunazzz/bfldb"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeExecutor records all orders executed, failing them if err is set.
+type fakeExecutor struct {
+	mtx    sync.Mutex
+	orders []bfldb.Order
+	err    error
+}
+
+func (e *fakeExecutor) Execute(ctx context.Context, o bfldb.Order) error {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if e.err != nil {
+		return e.err
+	}
+	e.orders = append(e.orders, o)
+	return nil
+}
+
+// Positions implements Account, with the positions resulting from all orders executed.
+func (e *fakeExecutor) Positions(ctx context.Context) ([]bfldb.Position, error) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	net := make(map[string]float64)
+	for _, o := range e.orders {
+		net[o.Ticker] += signedAmount(o.Direction, o.Amount)
+	}
+
+	var ps []bfldb.Position
+	for s, amount := range net {
+		p := bfldb.Position{Ticker: s, Direction: bfldb.Long, Amount: amount}
+		if amount < 0 {
+			p.Direction, p.Amount = bfldb.Short, -amount
+		}
+		ps = append(ps, p)
+	}
+
+	return ps, nil
+}
+
+// blockingExecutor blocks orders of the symbol until release is closed.
+type blockingExecutor struct {
+	fakeExecutor
+	symbol  string
+	release chan struct{}
+}
+
+func (e *blockingExecutor) Execute(ctx context.Context, o bfldb.Order) error {
+	if o.Ticker == e.symbol {
+		<-e.release
+	}
+	return e.fakeExecutor.Execute(ctx, o)
+}
+
+func TestCopier_Copy(t *testing.T) {
+	ctx := context.Background()
+	exec := &fakeExecutor{}
+	c := NewCopier(exec, []Trader{{UID: "A", Allocation: 0.5}, {UID: "B", Allocation: 0.1}})
+
+	pos := func(typ bfldb.PositionType, dir bfldb.TradeDirection, prev, amount float64) bfldb.Position {
+		return bfldb.Position{Type: typ, Direction: dir, Ticker: "BTCUSDT", PrevAmount: prev, Amount: amount, MarkPrice: 20000, Leverage: 10}
+	}
+
+	require.True(t, c.Copy(ctx, "A", pos(bfldb.Opened, bfldb.Long, 0, 2)).Executed())
+	require.True(t, c.Copy(ctx, "B", pos(bfldb.Opened, bfldb.Long, 0, 10)).Executed())
+	require.Equal(t, map[string]float64{"A": 1, "B": 1}, c.Shares("BTCUSDT"))
+
+	// A closes half of their position, so half of A's share is closed
+	require.True(t, c.Copy(ctx, "A", pos(bfldb.PartiallyClosed, bfldb.Long, 2, 1)).Executed())
+	require.Equal(t, bfldb.Order{Direction: bfldb.Short, Ticker: "BTCUSDT", Amount: 0.5, ReduceOnly: true, Leverage: 10, Price: 20000}, exec.orders[2])
+
+	// A closes the rest, B's share is left untouched
+	require.True(t, c.Copy(ctx, "A", pos(bfldb.Closed, bfldb.Long, 1, 0)).Executed())
+	require.Equal(t, 0.5, exec.orders[3].Amount)
+	require.Equal(t, map[string]float64{"B": 1}, c.Shares("BTCUSDT"))
+	require.Equal(t, 1.0, c.Net("BTCUSDT"))
+
+	// nothing left to close
+	require.ErrorIs(t, c.Copy(ctx, "A", pos(bfldb.Closed, bfldb.Long, 1, 0)).Err, ErrNotCopied)
+	require.ErrorIs(t, c.Copy(ctx, "C", pos(bfldb.Opened, bfldb.Long, 0, 1)).Err, ErrNotCopied)
+	require.ErrorIs(t, c.Copy(ctx, "A", pos(bfldb.Existing, bfldb.Long, 0, 1)).Err, ErrNotCopied)
+	require.Len(t, exec.orders, 4)
+}
+
+func TestCopier_OppositeShares(t *testing.T) {
+	ctx := context.Background()
+	exec := &fakeExecutor{}
+	c := NewCopier(exec, []Trader{{UID: "A", Allocation: 1}, {UID: "B", Allocation: 1}})
+
+	c.Copy(ctx, "A", bfldb.Position{Type: bfldb.Opened, Direction: bfldb.Long, Ticker: "ETHUSDT", Amount: 1, MarkPrice: 1000})
+	c.Copy(ctx, "B", bfldb.Position{Type: bfldb.Opened, Direction: bfldb.Short, Ticker: "ETHUSDT", Amount: 1, MarkPrice: 1000})
+	require.Equal(t, 0.0, c.Net("ETHUSDT"))
+
+	// the account is flat, so closing A's share opens a short position
+	e := c.Copy(ctx, "A", bfldb.Position{Type: bfldb.Closed, Direction: bfldb.Long, Ticker: "ETHUSDT", PrevAmount: 1, MarkPrice: 1000})
+	require.True(t, e.Executed())
+	require.Equal(t, bfldb.Short, e.Decision.Order.Direction)
+	require.False(t, e.Decision.Order.ReduceOnly)
+	require.Equal(t, -1.0, c.Net("ETHUSDT"))
+}
+
+func TestCopier_RiskAndErrors(t *testing.T) {
+	ctx := context.Background()
+	exec := &fakeExecutor{}
+
+	var executions []Execution
+	c := NewCopier(exec, []Trader{{UID: "A", Allocation: 1}},
+		WithRiskManager(NewRiskManager(WithMaxNotional("", 10000))),
+		WithExecutionHandler(func(e Execution) { executions = append(executions, e) }),
+	)
+
+	// the order is lowered by the risk manager, so only the executed amount is attributed to the trader
+	e := c.Copy(ctx, "A", bfldb.Position{Type: bfldb.Opened, Direction: bfldb.Long, Ticker: "BTCUSDT", Amount: 1, MarkPrice: 20000})
+	require.Equal(t, Modify, e.Decision.Action)
+	require.Equal(t, 0.5, c.Net("BTCUSDT"))
+
+	e = c.Copy(ctx, "A", bfldb.Position{Type: bfldb.AddedTo, Direction: bfldb.Long, Ticker: "BTCUSDT", PrevAmount: 1, Amount: 2, MarkPrice: 20000})
+	require.Equal(t, Reject, e.Decision.Action)
+	require.False(t, e.Executed())
+
+	exec.err = errors.New("insufficient margin")
+	e = c.Copy(ctx, "A", bfldb.Position{Type: bfldb.Closed, Direction: bfldb.Long, Ticker: "BTCUSDT", PrevAmount: 2, MarkPrice: 20000})
+	require.ErrorIs(t, e.Err, exec.err)
+	require.Equal(t, 0.5, c.Net("BTCUSDT"))
+
+	require.Len(t, executions, 3)
+}
+
+func TestCopier_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	exec := &blockingExecutor{symbol: "BTCUSDT", release: make(chan struct{})}
+
+	var c *Copier
+	nets := make(chan float64, 2)
+	c = NewCopier(exec, []Trader{{UID: "A", Allocation: 1}}, WithExecutionHandler(func(e Execution) {
+		// the handler can call back into the copier
+		nets <- c.Net(e.Position.Ticker)
+	}))
+
+	go c.Copy(ctx, "A", bfldb.Position{Type: bfldb.Opened, Direction: bfldb.Long, Ticker: "BTCUSDT", Amount: 1, MarkPrice: 20000})
+
+	// orders of other symbols don't wait for the blocked one
+	e := c.Copy(ctx, "A", bfldb.Position{Type: bfldb.Opened, Direction: bfldb.Short, Ticker: "ETHUSDT", Amount: 2, MarkPrice: 1000})
+	require.True(t, e.Executed())
+	require.Equal(t, -2.0, <-nets)
+
+	close(exec.release)
+	require.Equal(t, 1.0, <-nets)
+	require.Equal(t, map[string]float64{"A": 1}, c.Shares("BTCUSDT"))
+}
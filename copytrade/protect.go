@@ -0,0 +1,357 @@
+package copytrade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+)
+
+var (
+	ErrNotEnoughKlines = errors.New("not enough klines")
+)
+
+// ProtectionKind is a kind of a protective order.
+type ProtectionKind int
+
+const (
+	StopLoss   ProtectionKind = iota + 1 // Closes the position once the price moves against it
+	TakeProfit                           // Closes the position once the price moves in its favor
+)
+
+func (k ProtectionKind) String() string {
+	switch k {
+	default:
+		return ""
+	case StopLoss:
+		return "stop loss"
+	case TakeProfit:
+		return "take profit"
+	}
+}
+
+// ProtectiveOrder is a stop loss or take profit order closing a position of the account once its trigger price
+// is reached.
+type ProtectiveOrder struct {
+	ID           string               // ID of the order, as returned by the ProtectiveExecutor
+	Kind         ProtectionKind       // Kind of the order
+	Direction    bfldb.TradeDirection // Direction of the order, the opposite one of the position
+	Ticker       string               // Ticker of the position (e.g. BTCUSDT)
+	Amount       float64              // Amount of the position
+	TriggerPrice float64              // Price the order triggers at
+}
+
+// ProtectiveExecutor places and cancels protective orders, e.g. through an exchange API.
+type ProtectiveExecutor interface {
+	// Place places the protective order and returns its ID.
+	Place(ctx context.Context, po ProtectiveOrder) (string, error)
+
+	// Cancel cancels a protective order placed before.
+	Cancel(ctx context.Context, po ProtectiveOrder) error
+}
+
+// Rule returns how far from the entry price a protective order of the position triggers.
+// The position passed in is the whole position of the account, with the average entry price.
+type Rule func(ctx context.Context, p bfldb.Position) (float64, error)
+
+// PriceMove triggers once the price moved by the fraction of the entry price passed in, e.g. 0.02 for 2%.
+func PriceMove(f float64) Rule {
+	return func(ctx context.Context, p bfldb.Position) (float64, error) {
+		return p.EntryPrice * f, nil
+	}
+}
+
+// ROE triggers once the ROE (the PNL relative to the margin) of the position reaches the fraction passed in,
+// e.g. 0.5 for 50%. Positions without leverage are treated as 1x.
+func ROE(roe float64) Rule {
+	return func(ctx context.Context, p bfldb.Position) (float64, error) {
+		lev := p.Leverage
+		if lev < 1 {
+			lev = 1
+		}
+		return p.EntryPrice * roe / float64(lev), nil
+	}
+}
+
+// Kline is a single candlestick of a symbol.
+type Kline struct {
+	Time  time.Time // Open time
+	Open  float64   // Open price
+	High  float64   // Highest price
+	Low   float64   // Lowest price
+	Close float64   // Close price
+}
+
+// KlineSource provides klines of symbols, e.g. from a local database.
+type KlineSource interface {
+	// Klines returns the latest klines of the symbol, from the oldest one.
+	Klines(ctx context.Context, symbol string) ([]Kline, error)
+}
+
+// ATR triggers once the price moved by the multiple of the average true range of the latest period klines.
+func ATR(src KlineSource, period int, multiplier float64) Rule {
+	return func(ctx context.Context, p bfldb.Position) (float64, error) {
+		ks, err := src.Klines(ctx, p.Ticker)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get klines: %w", err)
+		}
+
+		atr, err := averageTrueRange(ks, period)
+		if err != nil {
+			return 0, err
+		}
+
+		return atr * multiplier, nil
+	}
+}
+
+// averageTrueRange returns the average true range of the latest period klines.
+// The kline before them is needed as well, for its close price.
+func averageTrueRange(ks []Kline, period int) (float64, error) {
+	if period < 1 || len(ks) < period+1 {
+		return 0, ErrNotEnoughKlines
+	}
+
+	var sum float64
+	for i := len(ks) - period; i < len(ks); i++ {
+		k, prev := ks[i], ks[i-1].Close
+		sum += math.Max(k.High-k.Low, math.Max(math.Abs(k.High-prev), math.Abs(k.Low-prev)))
+	}
+
+	return sum / float64(period), nil
+}
+
+// Protector keeps stop loss and take profit orders attached to the positions of the account.
+//
+// Whenever a position changes, its protective orders are replaced by ones matching its new size and average
+// entry price, and once it's closed, they're cancelled. Once a protective order is triggered, it has to be
+// reported with Copier.Protected, or Triggered when the Protector is used on its own.
+type Protector struct {
+	mtx       sync.Mutex                   // Synchronization for positions and orders
+	positions map[string]bfldb.Position    // positions of the account with signed amounts, mapped by the symbol
+	orders    map[string][]ProtectiveOrder // protective orders placed, mapped by the symbol
+
+	exec       ProtectiveExecutor // executor placing and cancelling protective orders
+	stopLoss   Rule               // rule of stop loss orders, nil if they aren't placed
+	takeProfit Rule               // rule of take profit orders, nil if they aren't placed
+}
+
+type ProtectorOption func(*Protector)
+
+// NewProtector creates a new Protector placing orders with exec. Use WithStopLoss and WithTakeProfit
+// to set which orders are placed.
+func NewProtector(exec ProtectiveExecutor, opts ...ProtectorOption) *Protector {
+	pr := Protector{
+		positions: make(map[string]bfldb.Position),
+		orders:    make(map[string][]ProtectiveOrder),
+		exec:      exec,
+	}
+
+	for _, opt := range opts {
+		opt(&pr)
+	}
+
+	return &pr
+}
+
+// Filled updates the position of the order's symbol with the executed order and replaces its protective orders.
+// The price of the order is used as its fill price.
+//
+// If a rule fails, protective orders of its kind are cancelled rather than left in place for the previous amount,
+// and the error is returned along with any error replacing the orders.
+func (pr *Protector) Filled(ctx context.Context, o bfldb.Order) error {
+	pr.mtx.Lock()
+	defer pr.mtx.Unlock()
+
+	p := pr.positions[o.Ticker]
+	p.Ticker = o.Ticker
+
+	amount := signedAmount(o.Direction, o.Amount)
+	switch {
+	case p.Amount*amount >= 0:
+		// opened or added to, so the entry price is averaged
+		if total := math.Abs(p.Amount) + o.Amount; total > 0 {
+			p.EntryPrice = (p.EntryPrice*math.Abs(p.Amount) + o.Price*o.Amount) / total
+		}
+	case math.Abs(amount) > math.Abs(p.Amount):
+		// flipped to the other direction
+		p.EntryPrice = o.Price
+	}
+
+	p.Amount += amount
+	if o.Leverage != 0 {
+		p.Leverage = o.Leverage
+	}
+
+	if math.Abs(p.Amount) < 1e-12 {
+		delete(pr.positions, o.Ticker)
+		return pr.replace(ctx, o.Ticker, nil)
+	}
+	pr.positions[o.Ticker] = p
+
+	// protective orders work with an unsigned amount
+	p.Direction = bfldb.Long
+	if p.Amount < 0 {
+		p.Direction = bfldb.Short
+	}
+	p.Amount = math.Abs(p.Amount)
+
+	// orders whose rule fails are still replaced, so no order is left in place for the previous amount
+	var (
+		want []ProtectiveOrder
+		err  error
+	)
+	for _, r := range []struct {
+		kind ProtectionKind
+		rule Rule
+	}{{StopLoss, pr.stopLoss}, {TakeProfit, pr.takeProfit}} {
+		if r.rule == nil {
+			continue
+		}
+
+		po, perr := protectiveOrder(ctx, r.kind, r.rule, p)
+		if perr != nil {
+			if err == nil {
+				err = fmt.Errorf("failed to determine %s: %w", r.kind, perr)
+			}
+			continue
+		}
+		want = append(want, po)
+	}
+
+	if rerr := pr.replace(ctx, o.Ticker, want); rerr != nil {
+		if err == nil {
+			return rerr
+		}
+		return fmt.Errorf("%w, %v", err, rerr)
+	}
+
+	return err
+}
+
+// protectiveOrder creates a protective order of the kind for the position, with an unsigned amount.
+func protectiveOrder(ctx context.Context, kind ProtectionKind, rule Rule, p bfldb.Position) (ProtectiveOrder, error) {
+	dist, err := rule(ctx, p)
+	if err != nil {
+		return ProtectiveOrder{}, err
+	}
+
+	// a stop loss of a long position triggers below the entry price, a take profit above it
+	sign := 1.0
+	if (kind == StopLoss) == (p.Direction == bfldb.Long) {
+		sign = -1
+	}
+
+	po := ProtectiveOrder{
+		Kind:         kind,
+		Direction:    bfldb.Short,
+		Ticker:       p.Ticker,
+		Amount:       p.Amount,
+		TriggerPrice: p.EntryPrice + sign*math.Abs(dist),
+	}
+	if p.Direction == bfldb.Short {
+		po.Direction = bfldb.Long
+	}
+
+	return po, nil
+}
+
+// replace replaces the protective orders of the symbol with the ones passed in, keeping orders that
+// didn't change. pr.mtx has to be held by the caller.
+func (pr *Protector) replace(ctx context.Context, symbol string, want []ProtectiveOrder) error {
+	var (
+		placed []ProtectiveOrder
+		err    error
+	)
+
+	for _, old := range pr.orders[symbol] {
+		keep := false
+		for i, po := range want {
+			po.ID = old.ID
+			if po == old {
+				keep = true
+				want = append(want[:i], want[i+1:]...)
+				break
+			}
+		}
+
+		if keep {
+			placed = append(placed, old)
+			continue
+		}
+
+		if cerr := pr.exec.Cancel(ctx, old); cerr != nil {
+			// still in place, so keep track of it
+			placed = append(placed, old)
+			if err == nil {
+				err = fmt.Errorf("failed to cancel %s: %w", old.Kind, cerr)
+			}
+		}
+	}
+
+	for _, po := range want {
+		id, perr := pr.exec.Place(ctx, po)
+		if perr != nil {
+			if err == nil {
+				err = fmt.Errorf("failed to place %s: %w", po.Kind, perr)
+			}
+			continue
+		}
+
+		po.ID = id
+		placed = append(placed, po)
+	}
+
+	if len(placed) == 0 {
+		delete(pr.orders, symbol)
+	} else {
+		pr.orders[symbol] = placed
+	}
+
+	return err
+}
+
+// Triggered records that the protective order was triggered and filled, closing the position of its symbol.
+// The other protective orders of the symbol are cancelled.
+func (pr *Protector) Triggered(ctx context.Context, po ProtectiveOrder) error {
+	pr.mtx.Lock()
+	defer pr.mtx.Unlock()
+
+	// the triggered order is gone already, so it isn't cancelled
+	var left []ProtectiveOrder
+	for _, old := range pr.orders[po.Ticker] {
+		if old.ID != po.ID {
+			left = append(left, old)
+		}
+	}
+	pr.orders[po.Ticker] = left
+
+	delete(pr.positions, po.Ticker)
+	return pr.replace(ctx, po.Ticker, nil)
+}
+
+// Orders returns the protective orders placed for the symbol.
+func (pr *Protector) Orders(symbol string) []ProtectiveOrder {
+	pr.mtx.Lock()
+	defer pr.mtx.Unlock()
+
+	return append([]ProtectiveOrder(nil), pr.orders[symbol]...)
+}
+
+// WithStopLoss places stop loss orders triggering as determined by the rule passed in.
+func WithStopLoss(r Rule) ProtectorOption {
+	return func(pr *Protector) {
+		pr.stopLoss = r
+	}
+}
+
+// WithTakeProfit places take profit orders triggering as determined by the rule passed in.
+func WithTakeProfit(r Rule) ProtectorOption {
+	return func(pr *Protector) {
+		pr.takeProfit = r
+	}
+}
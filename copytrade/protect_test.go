@@ -0,0 +1,190 @@
+package copytrade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/rtunazzz/bfldb"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProtectiveExecutor keeps track of all protective orders placed and not cancelled yet.
+type fakeProtectiveExecutor struct {
+	orders map[string]ProtectiveOrder
+	placed int
+	err    error
+}
+
+func (e *fakeProtectiveExecutor) Place(ctx context.Context, po ProtectiveOrder) (string, error) {
+	if e.err != nil {
+		return "", e.err
+	}
+
+	e.placed++
+	po.ID = fmt.Sprint(e.placed)
+	e.orders[po.ID] = po
+
+	return po.ID, nil
+}
+
+func (e *fakeProtectiveExecutor) Cancel(ctx context.Context, po ProtectiveOrder) error {
+	if _, ok := e.orders[po.ID]; !ok {
+		return errors.New("unknown order")
+	}
+	delete(e.orders, po.ID)
+	return nil
+}
+
+// klines is a KlineSource returning the same klines for all symbols.
+type klines []Kline
+
+func (ks klines) Klines(ctx context.Context, symbol string) ([]Kline, error) {
+	return ks, nil
+}
+
+func TestProtector(t *testing.T) {
+	ctx := context.Background()
+	exec := &fakeProtectiveExecutor{orders: make(map[string]ProtectiveOrder)}
+	pr := NewProtector(exec, WithStopLoss(PriceMove(0.1)), WithTakeProfit(ROE(1)))
+
+	require.NoError(t, pr.Filled(ctx, bfldb.Order{Direction: bfldb.Long, Ticker: "BTCUSDT", Amount: 1, Price: 100, Leverage: 10}))
+	require.Equal(t, []ProtectiveOrder{
+		{ID: "1", Kind: StopLoss, Direction: bfldb.Short, Ticker: "BTCUSDT", Amount: 1, TriggerPrice: 90},
+		{ID: "2", Kind: TakeProfit, Direction: bfldb.Short, Ticker: "BTCUSDT", Amount: 1, TriggerPrice: 110},
+	}, pr.Orders("BTCUSDT"))
+
+	// added to, so both orders are replaced with ones using the new size and average entry price
+	require.NoError(t, pr.Filled(ctx, bfldb.Order{Direction: bfldb.Long, Ticker: "BTCUSDT", Amount: 1, Price: 200, Leverage: 10}))
+	require.Equal(t, []ProtectiveOrder{
+		{ID: "3", Kind: StopLoss, Direction: bfldb.Short, Ticker: "BTCUSDT", Amount: 2, TriggerPrice: 135},
+		{ID: "4", Kind: TakeProfit, Direction: bfldb.Short, Ticker: "BTCUSDT", Amount: 2, TriggerPrice: 165},
+	}, pr.Orders("BTCUSDT"))
+	require.Len(t, exec.orders, 2)
+
+	// flipped to a short position
+	require.NoError(t, pr.Filled(ctx, bfldb.Order{Direction: bfldb.Short, Ticker: "BTCUSDT", Amount: 3, Price: 100, Leverage: 10}))
+	require.Equal(t, []ProtectiveOrder{
+		{ID: "5", Kind: StopLoss, Direction: bfldb.Long, Ticker: "BTCUSDT", Amount: 1, TriggerPrice: 110},
+		{ID: "6", Kind: TakeProfit, Direction: bfldb.Long, Ticker: "BTCUSDT", Amount: 1, TriggerPrice: 90},
+	}, pr.Orders("BTCUSDT"))
+
+	// closed, so both orders are cancelled
+	require.NoError(t, pr.Filled(ctx, bfldb.Order{Direction: bfldb.Long, Ticker: "BTCUSDT", Amount: 1, ReduceOnly: true, Price: 80}))
+	require.Empty(t, pr.Orders("BTCUSDT"))
+	require.Empty(t, exec.orders)
+
+	exec.err = errors.New("exchange unavailable")
+	require.ErrorIs(t, pr.Filled(ctx, bfldb.Order{Direction: bfldb.Long, Ticker: "ETHUSDT", Amount: 1, Price: 100}), exec.err)
+	require.Empty(t, pr.Orders("ETHUSDT"))
+}
+
+// failingKlines is a KlineSource returning the klines until err is set.
+type failingKlines struct {
+	ks  klines
+	err error
+}
+
+func (f *failingKlines) Klines(ctx context.Context, symbol string) ([]Kline, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.ks, nil
+}
+
+func TestProtector_RuleFails(t *testing.T) {
+	ctx := context.Background()
+	exec := &fakeProtectiveExecutor{orders: make(map[string]ProtectiveOrder)}
+	src := &failingKlines{ks: klines{{Close: 100}, {High: 110, Low: 90, Close: 100}}}
+	pr := NewProtector(exec, WithStopLoss(ATR(src, 1, 1)), WithTakeProfit(PriceMove(0.1)))
+
+	require.NoError(t, pr.Filled(ctx, bfldb.Order{Direction: bfldb.Long, Ticker: "BTCUSDT", Amount: 2, Price: 100}))
+	require.Len(t, exec.orders, 2)
+
+	// half of the position is closed, but the stop loss can't be determined anymore
+	src.err = errors.New("klines unavailable")
+	err := pr.Filled(ctx, bfldb.Order{Direction: bfldb.Short, Ticker: "BTCUSDT", Amount: 1, ReduceOnly: true, Price: 100})
+	require.ErrorIs(t, err, src.err)
+
+	// the stop loss for the previous amount is cancelled, the take profit is resized
+	require.Equal(t, []ProtectiveOrder{
+		{ID: "3", Kind: TakeProfit, Direction: bfldb.Short, Ticker: "BTCUSDT", Amount: 1, TriggerPrice: 110},
+	}, pr.Orders("BTCUSDT"))
+	require.Len(t, exec.orders, 1)
+}
+
+func TestATR(t *testing.T) {
+	ctx := context.Background()
+	ks := klines{
+		{Close: 100},
+		{High: 110, Low: 95, Close: 105},  // 15
+		{High: 106, Low: 104, Close: 104}, // 2
+		{High: 103, Low: 101, Close: 102}, // 3, from the previous close
+	}
+	p := bfldb.Position{Ticker: "BTCUSDT", EntryPrice: 100}
+
+	d, err := ATR(ks, 3, 2)(ctx, p)
+	require.NoError(t, err)
+	require.InDelta(t, 40.0/3, d, 1e-9)
+
+	d, err = ATR(ks, 2, 1)(ctx, p)
+	require.NoError(t, err)
+	require.InDelta(t, 2.5, d, 1e-9)
+
+	_, err = ATR(ks, 4, 1)(ctx, p)
+	require.ErrorIs(t, err, ErrNotEnoughKlines)
+}
+
+func TestCopier_Protector(t *testing.T) {
+	ctx := context.Background()
+	pexec := &fakeProtectiveExecutor{orders: make(map[string]ProtectiveOrder)}
+	c := NewCopier(&fakeExecutor{}, []Trader{{UID: "A", Allocation: 1}}, WithProtector(NewProtector(pexec, WithStopLoss(PriceMove(0.05)))))
+
+	p := bfldb.Position{Type: bfldb.Opened, Direction: bfldb.Short, Ticker: "ETHUSDT", Amount: 2, MarkPrice: 1000}
+	require.NoError(t, c.Copy(ctx, "A", p).ProtectErr)
+	require.Equal(t, []ProtectiveOrder{{ID: "1", Kind: StopLoss, Direction: bfldb.Long, Ticker: "ETHUSDT", Amount: 2, TriggerPrice: 1050}}, c.protect.Orders("ETHUSDT"))
+
+	p.Type, p.PrevAmount, p.Amount = bfldb.PartiallyClosed, 2, 1
+	require.NoError(t, c.Copy(ctx, "A", p).ProtectErr)
+	require.Equal(t, 1.0, pexec.orders["2"].Amount)
+
+	p.Type, p.PrevAmount, p.Amount = bfldb.Closed, 1, 0
+	require.NoError(t, c.Copy(ctx, "A", p).ProtectErr)
+	require.Empty(t, pexec.orders)
+}
+
+func TestCopier_Protected(t *testing.T) {
+	ctx := context.Background()
+	pexec := &fakeProtectiveExecutor{orders: make(map[string]ProtectiveOrder)}
+	rm := NewRiskManager(WithDailyLossLimit(500))
+	c := NewCopier(&fakeExecutor{}, []Trader{{UID: "A", Allocation: 1}},
+		WithRiskManager(rm),
+		WithProtector(NewProtector(pexec, WithStopLoss(PriceMove(0.05)), WithTakeProfit(PriceMove(0.1)))),
+	)
+
+	p := bfldb.Position{Type: bfldb.Opened, Direction: bfldb.Long, Ticker: "BTCUSDT", Amount: 1, MarkPrice: 20000}
+	require.NoError(t, c.Copy(ctx, "A", p).ProtectErr)
+
+	orders := c.protect.Orders("BTCUSDT")
+	require.Len(t, orders, 2)
+	sl := orders[0]
+	require.Equal(t, StopLoss, sl.Kind)
+
+	// the exchange filled the stop loss
+	delete(pexec.orders, sl.ID)
+	require.NoError(t, c.Protected(ctx, sl))
+
+	require.Empty(t, pexec.orders, "take profit wasn't cancelled")
+	require.Empty(t, c.protect.Orders("BTCUSDT"))
+	require.Empty(t, c.Shares("BTCUSDT"))
+	require.Zero(t, rm.Exposure("BTCUSDT"))
+
+	// the trader's close has nothing left to close
+	p.Type, p.PrevAmount, p.Amount = bfldb.Closed, 1, 0
+	require.ErrorIs(t, c.Copy(ctx, "A", p).Err, ErrNotCopied)
+
+	// the loss of the stop loss counts towards the daily loss limit
+	p.Type, p.PrevAmount, p.Amount = bfldb.Opened, 0, 1
+	require.Equal(t, Reject, c.Copy(ctx, "A", p).Decision.Action)
+}
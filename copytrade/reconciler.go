@@ -0,0 +1,398 @@
+package copytrade
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+)
+
+// Account is your own account, reporting its open positions, e.g. through an exchange API.
+type Account interface {
+	// Positions returns all open positions of the account. Only the direction, ticker, amount, prices
+	// and leverage of positions are used.
+	Positions(ctx context.Context) ([]bfldb.Position, error)
+}
+
+// Divergence is a difference between a position of the account and the position it should have,
+// according to the current positions of the traders copied.
+type Divergence struct {
+	Symbol    string      // Symbol of the position
+	Target    float64     // Signed amount the account should have, positive for long and negative for short
+	Actual    float64     // Signed amount the account has, positive for long and negative for short
+	Confirmed bool        // Whether or not the previous reconciliation found the same divergence
+	Order     bfldb.Order // Order correcting the divergence
+	Decision  Decision    // Decision of the risk check of the order, empty if it wasn't executed
+	Corrected bool        // Whether or not the order was executed
+	Err       error       // Error the order or updating the protective orders failed with
+}
+
+// Diff returns the signed amount the account is missing, positive if it should buy and negative if it should sell.
+func (d Divergence) Diff() float64 {
+	return d.Target - d.Actual
+}
+
+// Reconciler periodically compares the positions of the account with the positions of the traders copied
+// by a Copier, scaled by their allocation, and corrects any divergences, e.g. after an order failed or when
+// traders were already in a position once copying started.
+//
+// A divergence is only corrected once two reconciliations in a row find it, so position changes that are
+// still being copied aren't corrected as well.
+//
+// While positions of some traders weren't fetched yet (e.g. right after a restart, or if fetching them keeps failing),
+// symbols those traders have a share of, as well as symbols none of the other traders are in, are skipped, as the
+// account might hold positions copied from them. Such traders are logged on every reconciliation.
+//
+// Symbols closed by protective orders (see Copier.Protected) are left flat on purpose, so they're skipped
+// until none of the traders are in a position of them anymore.
+//
+// Transforms of traders are applied to their positions as Existing ones. Transforms filtering position changes
+// (e.g. OpenOnly or MinSize) make the account diverge on purpose, so traders copied with them should only be
+// reconciled in the dry run mode.
+type Reconciler struct {
+	copier    *Copier            // copier whose traders and executor are used
+	account   Account            // account the positions are compared with
+	interval  time.Duration      // how often positions are reconciled
+	tolerance float64            // divergence tolerated, relative to the larger of the target and actual amount
+	dryRun    bool               // whether or not divergences are only reported
+	pending   map[string]float64 // diffs found by the previous reconciliation, mapped by the symbol
+	log       *log.Logger        // logger every divergence is logged with
+	handler   func(Divergence)   // called with every divergence, nil if not set
+}
+
+type ReconcilerOption func(*Reconciler)
+
+// NewReconciler creates a new Reconciler correcting the positions of the account with the executor of the copier.
+//
+// By default, positions are reconciled every minute and divergences of up to 5% are tolerated.
+func NewReconciler(c *Copier, acc Account, opts ...ReconcilerOption) *Reconciler {
+	r := Reconciler{
+		copier:    c,
+		account:   acc,
+		interval:  time.Minute,
+		tolerance: 0.05,
+		pending:   make(map[string]float64),
+		log:       log.New(io.Discard, "", 0),
+	}
+
+	for _, opt := range opts {
+		opt(&r)
+	}
+
+	return &r
+}
+
+// Run reconciles positions periodically until the context is cancelled.
+// Positions are only compared once copier's Run subscribed to the traders.
+func (r *Reconciler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, err := r.Reconcile(ctx); err != nil {
+				r.log.Printf("failed to reconcile positions: %v\n", err)
+			}
+		}
+	}
+}
+
+// Reconcile compares the positions once and returns all divergences found, sorted by the symbol.
+//
+// Confirmed divergences are corrected, unless the reconciler is in the dry run mode.
+func (r *Reconciler) Reconcile(ctx context.Context) ([]Divergence, error) {
+	c := r.copier
+
+	c.mtx.Lock()
+	targets, shares, unsynced := r.targets()
+	executed := make(map[string]int, len(c.executed))
+	for s, n := range c.executed {
+		executed[s] = n
+	}
+	stopped := make(map[string]bool, len(c.stopped))
+	for s := range c.stopped {
+		if _, open := targets[s]; open || len(unsynced) > 0 {
+			stopped[s] = true
+		} else {
+			// none of the traders are in a position of the symbol anymore
+			delete(c.stopped, s)
+		}
+	}
+
+	// symbols the account might hold positions of copied from traders whose positions aren't known
+	skipped := make(map[string]bool)
+	for _, uid := range unsynced {
+		for s, ss := range c.shares {
+			if _, ok := ss[uid]; ok {
+				skipped[s] = true
+			}
+		}
+	}
+	c.mtx.Unlock()
+
+	if len(unsynced) > 0 {
+		r.log.Printf("positions of %s weren't fetched yet, skipping their symbols\n", strings.Join(unsynced, ", "))
+	}
+
+	ps, err := r.account.Positions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account positions: %w", err)
+	}
+
+	actual := make(map[string]bfldb.Position, len(ps))
+	for _, p := range ps {
+		a := actual[p.Ticker]
+		a.Ticker = p.Ticker
+		a.Amount += signedAmount(p.Direction, p.Amount)
+		if p.MarkPrice != 0 {
+			a.MarkPrice = p.MarkPrice
+		}
+		if p.Leverage != 0 {
+			a.Leverage = p.Leverage
+		}
+		actual[p.Ticker] = a
+	}
+
+	symbols := make([]string, 0, len(targets)+len(actual))
+	for s := range targets {
+		symbols = append(symbols, s)
+	}
+	for s := range actual {
+		if _, ok := targets[s]; !ok {
+			symbols = append(symbols, s)
+		}
+	}
+	sort.Strings(symbols)
+
+	pending := make(map[string]float64)
+	var divs []Divergence
+
+	for _, s := range symbols {
+		t, open := targets[s]
+		if stopped[s] || skipped[s] || (!open && len(unsynced) > 0) {
+			continue
+		}
+
+		a := actual[s]
+		d := Divergence{Symbol: s, Target: t.Amount, Actual: a.Amount}
+
+		diff := d.Diff()
+		if math.Abs(diff) <= r.tolerance*math.Max(math.Abs(d.Target), math.Abs(d.Actual)) || math.Abs(diff) < 1e-12 {
+			continue
+		}
+
+		prev, ok := r.pending[s]
+		d.Confirmed = ok && prev*diff > 0
+		pending[s] = diff
+
+		d.Order = correction(d, t, a)
+
+		if d.Confirmed && !r.dryRun {
+			// an order of the symbol copied in the meantime might've caused the divergence, so it has to be confirmed again
+			d.Confirmed = r.correct(ctx, &d, shares[s], executed[s])
+			if !d.Confirmed || d.Corrected {
+				delete(pending, s)
+			}
+		}
+
+		r.report(d)
+		divs = append(divs, d)
+	}
+
+	r.pending = pending
+
+	return divs, nil
+}
+
+// targets returns the positions the account should have, along with the amounts of every trader, mapped by the
+// symbol, and UIDs of the traders whose positions weren't fetched yet, sorted. Amounts are signed.
+// c.mtx has to be held by the caller.
+func (r *Reconciler) targets() (map[string]bfldb.Position, map[string]map[string]float64, []string) {
+	c := r.copier
+
+	targets := make(map[string]bfldb.Position)
+	shares := make(map[string]map[string]float64)
+	var unsynced []string
+
+	for uid, t := range c.traders {
+		u, ok := c.watcher.User(uid)
+		if !ok || !u.Synced() {
+			unsynced = append(unsynced, uid)
+			continue
+		}
+
+		for _, p := range u.Positions() {
+			if t.Transform != nil {
+				p.Type = bfldb.Existing
+				if p, ok = t.Transform(p); !ok {
+					continue
+				}
+			}
+
+			amount := signedAmount(p.Direction, p.Amount) * t.Allocation
+
+			tp := targets[p.Ticker]
+			tp.Ticker = p.Ticker
+			tp.Amount += amount
+			tp.MarkPrice = p.MarkPrice
+			if tp.MarkPrice == 0 {
+				tp.MarkPrice = p.EntryPrice
+			}
+			if p.Leverage > tp.Leverage {
+				tp.Leverage = p.Leverage
+			}
+			targets[p.Ticker] = tp
+
+			if shares[p.Ticker] == nil {
+				shares[p.Ticker] = make(map[string]float64)
+			}
+			shares[p.Ticker][uid] += amount
+		}
+	}
+
+	sort.Strings(unsynced)
+
+	return targets, shares, unsynced
+}
+
+// correction returns the order correcting the divergence. Target and actual positions carry signed amounts.
+func correction(d Divergence, target, actual bfldb.Position) bfldb.Order {
+	diff := d.Diff()
+
+	o := bfldb.Order{
+		Direction: bfldb.Long,
+		Ticker:    d.Symbol,
+		Amount:    math.Abs(diff),
+		Leverage:  actual.Leverage,
+		Price:     target.MarkPrice,
+	}
+	if diff < 0 {
+		o.Direction = bfldb.Short
+	}
+	if o.Leverage == 0 {
+		o.Leverage = target.Leverage
+	}
+	if o.Price == 0 {
+		o.Price = actual.MarkPrice
+	}
+
+	// the position only gets smaller, without flipping to the other direction
+	o.ReduceOnly = d.Target*d.Actual >= 0 && math.Abs(d.Target) < math.Abs(d.Actual)
+
+	return o
+}
+
+// correct checks and executes the order of the divergence, holding the lock of its symbol. Once it's executed,
+// shares of the traders are set to their current amounts, scaled to the amount the account has.
+//
+// It returns false without doing anything if the number of orders executed for the symbol isn't the one passed
+// in anymore, as the divergence might've been caused by an order that was still being copied.
+func (r *Reconciler) correct(ctx context.Context, d *Divergence, shares map[string]float64, executed int) bool {
+	c := r.copier
+
+	unlock := c.lockSymbol(d.Symbol)
+	defer unlock()
+
+	c.mtx.Lock()
+	n := c.executed[d.Symbol]
+	c.mtx.Unlock()
+	if n != executed {
+		return false
+	}
+
+	d.Decision = c.risk.Check(d.Order)
+	if d.Decision.Action == Reject {
+		return true
+	}
+
+	o := d.Decision.Order
+	if err := c.exec.Execute(ctx, o); err != nil {
+		d.Err = fmt.Errorf("failed to execute order: %w", err)
+		return true
+	}
+
+	c.risk.Filled(o)
+	d.Corrected = true
+
+	scale := 0.0
+	if d.Target != 0 {
+		scale = (d.Actual + signedAmount(o.Direction, o.Amount)) / d.Target
+	}
+
+	c.mtx.Lock()
+	delete(c.shares, d.Symbol)
+	for uid, share := range shares {
+		c.addShare(d.Symbol, uid, share*scale)
+	}
+	c.executed[d.Symbol]++
+	c.mtx.Unlock()
+
+	if err := c.protected(ctx, o); err != nil {
+		d.Err = err
+	}
+
+	return true
+}
+
+// report logs the divergence and passes it to the handler.
+func (r *Reconciler) report(d Divergence) {
+	switch {
+	case d.Err != nil:
+		r.log.Printf("%s diverged (target %g, actual %g): %v\n", d.Symbol, d.Target, d.Actual, d.Err)
+	case d.Corrected:
+		r.log.Printf("%s diverged (target %g, actual %g): corrected with %s %g\n", d.Symbol, d.Target, d.Actual, d.Decision.Order.Direction, d.Decision.Order.Amount)
+	case d.Decision.Action == Reject:
+		r.log.Printf("%s diverged (target %g, actual %g): correction rejected: %s\n", d.Symbol, d.Target, d.Actual, d.Decision.Reason)
+	default:
+		r.log.Printf("%s diverged (target %g, actual %g), confirmed: %t\n", d.Symbol, d.Target, d.Actual, d.Confirmed)
+	}
+
+	if r.handler != nil {
+		r.handler(d)
+	}
+}
+
+// WithReconcileInterval sets how often positions are reconciled.
+func WithReconcileInterval(d time.Duration) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.interval = d
+	}
+}
+
+// WithTolerance sets the divergence tolerated, relative to the larger of the amount the account should have
+// and the amount it has, e.g. 0.1 tolerates positions differing by up to 10%.
+func WithTolerance(t float64) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.tolerance = t
+	}
+}
+
+// WithDryRun only reports divergences, without correcting them.
+func WithDryRun() ReconcilerOption {
+	return func(r *Reconciler) {
+		r.dryRun = true
+	}
+}
+
+// WithReconcilerLogger sets the logger every divergence is logged with. Divergences aren't logged by default.
+func WithReconcilerLogger(l *log.Logger) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.log = l
+	}
+}
+
+// WithDivergenceHandler calls fn with every divergence found, e.g. to send alerts.
+func WithDivergenceHandler(fn func(Divergence)) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.handler = fn
+	}
+}
@@ -0,0 +1,215 @@
+package copytrade
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+	"github.com/stretchr/testify/require"
+)
+
+// runCopier runs a copier of trader A, who's in a 2 BTCUSDT long position, until the test ends.
+func runCopier(t *testing.T, exec Executor, opts ...CopierOption) *Copier {
+	return runCopierOf(t, []Trader{{UID: "A", Allocation: 0.5}}, exec, opts...)
+}
+
+// runCopierOf runs a copier of the traders until the test ends. All of them are in a 2 BTCUSDT long position,
+// except for trader B, whose positions can't be fetched.
+func runCopierOf(t *testing.T, traders []Trader, exec Executor, opts ...CopierOption) *Copier {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if body, _ := io.ReadAll(r.Body); bytes.Contains(body, []byte(`"B"`)) {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"success":true,"data":{"otherPositionRetList":[{"symbol":"BTCUSDT","amount":2,"markPrice":20000,"leverage":10}]}}`))
+	}))
+	t.Cleanup(api.Close)
+
+	opts = append(opts,
+		WithClient(bfldb.NewClient(bfldb.WithClientAPIBase(api.URL))),
+		WithUserOptions(bfldb.WithCustomRefresh(time.Millisecond)),
+	)
+	c := NewCopier(exec, traders, opts...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	require.Eventually(t, func() bool {
+		for _, tr := range traders {
+			if u, ok := c.watcher.User(tr.UID); tr.UID != "B" && (!ok || !u.Synced()) {
+				return false
+			}
+		}
+		return true
+	}, time.Second, time.Millisecond)
+
+	return c
+}
+
+func TestReconciler_Reconcile(t *testing.T) {
+	ctx := context.Background()
+
+	// a position that isn't copied from any trader
+	exec := &fakeExecutor{orders: []bfldb.Order{{Direction: bfldb.Short, Ticker: "ETHUSDT", Amount: 3}}}
+	c := runCopier(t, exec)
+
+	var handled []Divergence
+	r := NewReconciler(c, exec, WithDivergenceHandler(func(d Divergence) { handled = append(handled, d) }))
+
+	// divergences aren't corrected right away
+	divs, err := r.Reconcile(ctx)
+	require.NoError(t, err)
+	require.Len(t, divs, 2)
+	require.Equal(t, Divergence{
+		Symbol: "BTCUSDT",
+		Target: 1,
+		Order:  bfldb.Order{Direction: bfldb.Long, Ticker: "BTCUSDT", Amount: 1, Leverage: 10, Price: 20000},
+	}, divs[0])
+	require.Equal(t, Divergence{
+		Symbol: "ETHUSDT",
+		Actual: -3,
+		Order:  bfldb.Order{Direction: bfldb.Long, Ticker: "ETHUSDT", Amount: 3, ReduceOnly: true},
+	}, divs[1])
+	require.Len(t, exec.orders, 1)
+
+	divs, err = r.Reconcile(ctx)
+	require.NoError(t, err)
+	require.Len(t, divs, 2)
+	for _, d := range divs {
+		require.True(t, d.Confirmed)
+		require.True(t, d.Corrected)
+		require.NoError(t, d.Err)
+	}
+	require.Len(t, exec.orders, 3)
+	require.Equal(t, map[string]float64{"A": 1}, c.Shares("BTCUSDT"))
+
+	divs, err = r.Reconcile(ctx)
+	require.NoError(t, err)
+	require.Empty(t, divs)
+	require.Len(t, handled, 4)
+}
+
+func TestReconciler_DryRun(t *testing.T) {
+	ctx := context.Background()
+
+	// within the tolerance
+	exec := &fakeExecutor{orders: []bfldb.Order{{Direction: bfldb.Long, Ticker: "BTCUSDT", Amount: 0.99}}}
+	c := runCopier(t, exec)
+
+	divs, err := NewReconciler(c, exec).Reconcile(ctx)
+	require.NoError(t, err)
+	require.Empty(t, divs)
+
+	exec.orders[0].Amount = 0.5
+	r := NewReconciler(c, exec, WithDryRun())
+	for i := 0; i < 3; i++ {
+		divs, err = r.Reconcile(ctx)
+		require.NoError(t, err)
+		require.Len(t, divs, 1)
+		require.Equal(t, i > 0, divs[0].Confirmed)
+		require.False(t, divs[0].Corrected)
+		require.Equal(t, 0.5, divs[0].Diff())
+	}
+	require.Len(t, exec.orders, 1)
+}
+
+// accountFunc implements Account with a function.
+type accountFunc func(ctx context.Context) ([]bfldb.Position, error)
+
+func (fn accountFunc) Positions(ctx context.Context) ([]bfldb.Position, error) {
+	return fn(ctx)
+}
+
+func TestReconciler_CopiedMeanwhile(t *testing.T) {
+	ctx := context.Background()
+	exec := &fakeExecutor{}
+	c := runCopier(t, exec)
+
+	var copying bool
+	r := NewReconciler(c, accountFunc(func(ctx context.Context) ([]bfldb.Position, error) {
+		ps, err := exec.Positions(ctx)
+		if copying {
+			// the position is copied once the positions of the account were fetched
+			c.Copy(ctx, "A", bfldb.Position{Type: bfldb.Opened, Direction: bfldb.Long, Ticker: "BTCUSDT", Amount: 2, MarkPrice: 20000})
+		}
+		return ps, err
+	}))
+
+	_, err := r.Reconcile(ctx)
+	require.NoError(t, err)
+
+	copying = true
+	divs, err := r.Reconcile(ctx)
+	require.NoError(t, err)
+	require.Len(t, divs, 1)
+	require.False(t, divs[0].Confirmed)
+	require.False(t, divs[0].Corrected)
+	require.Len(t, exec.orders, 1)
+
+	copying = false
+	divs, err = r.Reconcile(ctx)
+	require.NoError(t, err)
+	require.Empty(t, divs)
+}
+
+func TestReconciler_NotSynced(t *testing.T) {
+	ctx := context.Background()
+
+	// ETHUSDT was copied from B, whose positions can't be fetched, and SOLUSDT might've been as well
+	exec := &fakeExecutor{orders: []bfldb.Order{
+		{Direction: bfldb.Long, Ticker: "ETHUSDT", Amount: 1},
+		{Direction: bfldb.Short, Ticker: "SOLUSDT", Amount: 3},
+	}}
+
+	var logs bytes.Buffer
+	c := runCopierOf(t, []Trader{{UID: "A", Allocation: 0.5}, {UID: "B", Allocation: 1}}, exec)
+	c.mtx.Lock()
+	c.addShare("ETHUSDT", "B", 1)
+	c.mtx.Unlock()
+
+	// A's symbols are still reconciled
+	r := NewReconciler(c, exec, WithReconcilerLogger(log.New(&logs, "", 0)))
+	for i := 0; i < 2; i++ {
+		divs, err := r.Reconcile(ctx)
+		require.NoError(t, err)
+		require.Len(t, divs, 1)
+		require.Equal(t, "BTCUSDT", divs[0].Symbol)
+	}
+	require.Len(t, exec.orders, 3)
+	require.Equal(t, bfldb.Order{Direction: bfldb.Long, Ticker: "BTCUSDT", Amount: 1, Leverage: 10, Price: 20000}, exec.orders[2])
+	require.Contains(t, logs.String(), "positions of B weren't fetched yet")
+}
+
+func TestReconciler_Protected(t *testing.T) {
+	ctx := context.Background()
+	exec := &fakeExecutor{orders: []bfldb.Order{{Direction: bfldb.Long, Ticker: "BTCUSDT", Amount: 1}}}
+	c := runCopier(t, exec)
+
+	// the position copied from A was closed by a stop loss
+	sl := ProtectiveOrder{Kind: StopLoss, Direction: bfldb.Short, Ticker: "BTCUSDT", Amount: 1, TriggerPrice: 19000}
+	exec.orders = append(exec.orders, bfldb.Order{Direction: sl.Direction, Ticker: sl.Ticker, Amount: sl.Amount})
+	require.NoError(t, c.Protected(ctx, sl))
+
+	// A is still in the position, but it isn't opened again
+	r := NewReconciler(c, exec)
+	for i := 0; i < 2; i++ {
+		divs, err := r.Reconcile(ctx)
+		require.NoError(t, err)
+		require.Empty(t, divs)
+	}
+	require.Len(t, exec.orders, 2)
+}
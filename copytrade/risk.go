@@ -0,0 +1,417 @@
+// Package copytrade turns position changes of bfldb users into orders of your own account.
+//
+// A Copier copies position changes of traders, sized by their allocation, into orders executed by an Executor.
+// Position changes can be transformed before they're copied, e.g. to fade a trader with Inverse.
+// A RiskManager checks every order against configurable rules before it's executed.
+// A Reconciler corrects positions of the account that diverged from the traders copied.
+// A Protector keeps stop loss and take profit orders attached to the positions of the account.
+package copytrade
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+)
+
+// Action is the outcome of a risk check of an order.
+type Action int
+
+const (
+	Allow  Action = iota + 1 // The order can be executed as is
+	Modify                   // The order can be executed once modified, e.g. with a smaller amount
+	Reject                   // The order must not be executed
+)
+
+func (a Action) String() string {
+	switch a {
+	default:
+		return ""
+	case Allow:
+		return "allow"
+	case Modify:
+		return "modify"
+	case Reject:
+		return "reject"
+	}
+}
+
+// Decision is the outcome of a risk check of an order.
+type Decision struct {
+	Action   Action      // What should happen to the order
+	Order    bfldb.Order // Order to execute, the modified one if the action is Modify
+	Original bfldb.Order // Order that was checked
+	Reason   string      // Why the order was modified or rejected, empty if it was allowed
+}
+
+// RiskManager checks orders against a set of rules before they're executed.
+//
+// It keeps track of the exposure of the account and of the realized PNL from the orders reported as filled
+// (see Filled). Reduce only orders only lower the risk, so they're exempt from all rules, though they still
+// count towards the order rate.
+type RiskManager struct {
+	mtx sync.Mutex // Synchronization for all fields
+
+	maxNotional  map[string]float64 // maximum notional by symbol, "" being the default for all symbols
+	maxExposure  float64            // maximum notional across all symbols, 0 means unlimited
+	maxLeverage  int                // maximum leverage, 0 means unlimited
+	allowed      map[string]bool    // symbols that can be traded, empty means all
+	denied       map[string]bool    // symbols that can't be traded
+	maxOrders    int                // maximum orders per minute, 0 means unlimited
+	dailyLossCap float64            // maximum realized loss per day (UTC), 0 means unlimited
+	killed       string             // reason the kill switch was engaged for, empty if it's not engaged
+	recentOrders []time.Time        // times of orders allowed within the last minute
+	net          map[string]float64 // net amount by symbol, positive for long and negative for short
+	prices       map[string]float64 // latest price by symbol
+	entries      map[string]float64 // average entry price of the net amount by symbol, missing if it's not known
+	dailyPnl     float64            // realized PNL of the current day
+	day          time.Time          // current day (UTC)
+	log          *log.Logger        // logger every decision is logged with
+	now          func() time.Time   // current time, replaceable for testing
+}
+
+type RiskOption func(*RiskManager)
+
+// NewRiskManager creates a new RiskManager. Without any options, all orders are allowed.
+func NewRiskManager(opts ...RiskOption) *RiskManager {
+	rm := RiskManager{
+		maxNotional: make(map[string]float64),
+		allowed:     make(map[string]bool),
+		denied:      make(map[string]bool),
+		net:         make(map[string]float64),
+		prices:      make(map[string]float64),
+		entries:     make(map[string]float64),
+		log:         log.New(io.Discard, "", 0),
+		now:         time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(&rm)
+	}
+
+	return &rm
+}
+
+// Check checks the order against all rules and logs the decision.
+//
+// Allowed and modified orders count towards the order rate, so Check should only be called
+// for orders that are about to be executed.
+func (rm *RiskManager) Check(o bfldb.Order) Decision {
+	rm.mtx.Lock()
+	defer rm.mtx.Unlock()
+
+	d := rm.check(o)
+	if d.Action != Reject {
+		rm.recentOrders = append(rm.recentOrders, rm.now())
+	}
+
+	if d.Reason == "" {
+		rm.log.Printf("%s %s %g %s @ %g\n", d.Action, o.Direction, o.Amount, o.Ticker, o.Price)
+	} else {
+		rm.log.Printf("%s %s %g %s @ %g: %s\n", d.Action, o.Direction, o.Amount, o.Ticker, o.Price, d.Reason)
+	}
+
+	return d
+}
+
+// check checks the order against all rules. rm.mtx has to be held by the caller.
+func (rm *RiskManager) check(o bfldb.Order) Decision {
+	allow := Decision{Action: Allow, Order: o, Original: o}
+	reject := func(format string, args ...any) Decision {
+		return Decision{Action: Reject, Order: o, Original: o, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	now := rm.now()
+
+	// drop orders older than a minute
+	recent := rm.recentOrders[:0]
+	for _, t := range rm.recentOrders {
+		if now.Sub(t) < time.Minute {
+			recent = append(recent, t)
+		}
+	}
+	rm.recentOrders = recent
+
+	if o.ReduceOnly {
+		return allow
+	}
+
+	if rm.maxOrders > 0 && len(rm.recentOrders) >= rm.maxOrders {
+		return reject("order rate of %d per minute exceeded", rm.maxOrders)
+	}
+
+	if rm.killed != "" {
+		return reject("kill switch engaged: %s", rm.killed)
+	}
+
+	rm.rollDay(now)
+	if rm.dailyLossCap > 0 && -rm.dailyPnl >= rm.dailyLossCap {
+		return reject("daily loss limit of %g reached", rm.dailyLossCap)
+	}
+
+	if rm.denied[o.Ticker] || (len(rm.allowed) > 0 && !rm.allowed[o.Ticker]) {
+		return reject("symbol %s is not allowed", o.Ticker)
+	}
+
+	d := allow
+	if rm.maxLeverage > 0 && o.Leverage > rm.maxLeverage {
+		d.Action = Modify
+		d.Order.Leverage = rm.maxLeverage
+		d.Reason = fmt.Sprintf("leverage lowered from %d to the maximum of %d", o.Leverage, rm.maxLeverage)
+	}
+
+	limit := rm.notionalLimit(o.Ticker)
+	if math.IsInf(limit, 1) {
+		return d
+	}
+
+	if o.Price <= 0 {
+		return reject("unknown price, notional can't be checked")
+	}
+
+	// amount of the order that fits into the limit
+	net := rm.net[o.Ticker]
+	fits := limit / o.Price
+	if signedAmount(o.Direction, 1)*net >= 0 {
+		fits -= math.Abs(net)
+	} else {
+		fits += math.Abs(net)
+	}
+
+	if fits >= o.Amount {
+		return d
+	}
+
+	if fits <= 0 {
+		return reject("notional limit of %g reached for %s", limit, o.Ticker)
+	}
+
+	d.Action = Modify
+	d.Order.Amount = fits
+	if d.Reason != "" {
+		d.Reason += ", "
+	}
+	d.Reason += fmt.Sprintf("amount lowered from %g to %g to fit the notional limit", o.Amount, fits)
+
+	return d
+}
+
+// notionalLimit returns the maximum notional of the symbol, considering both the per symbol and the total limit.
+// Returns +Inf if there's no limit. rm.mtx has to be held by the caller.
+func (rm *RiskManager) notionalLimit(symbol string) float64 {
+	limit := math.Inf(1)
+
+	if n, ok := rm.maxNotional[symbol]; ok {
+		limit = n
+	} else if n, ok := rm.maxNotional[""]; ok {
+		limit = n
+	}
+
+	if rm.maxExposure > 0 {
+		// exposure of all other symbols
+		var other float64
+		for s, net := range rm.net {
+			if s != symbol {
+				other += math.Abs(net) * rm.prices[s]
+			}
+		}
+
+		limit = math.Min(limit, rm.maxExposure-other)
+	}
+
+	return limit
+}
+
+// rollDay resets the daily PNL once a new day starts. rm.mtx has to be held by the caller.
+func (rm *RiskManager) rollDay(now time.Time) {
+	day := now.UTC().Truncate(24 * time.Hour)
+	if !day.Equal(rm.day) {
+		rm.day = day
+		rm.dailyPnl = 0
+	}
+}
+
+// Filled updates the exposure of the account with an executed order.
+//
+// Orders reducing the exposure realize PNL, estimated from their price and the average price of the orders
+// that opened the exposure, which is added to the PNL of the current day.
+func (rm *RiskManager) Filled(o bfldb.Order) {
+	rm.mtx.Lock()
+	defer rm.mtx.Unlock()
+
+	prev := rm.net[o.Ticker]
+	delta := signedAmount(o.Direction, o.Amount)
+	net := prev + delta
+
+	entry, known := rm.entries[o.Ticker]
+	switch {
+	case o.Price <= 0:
+		// the entry price can't be kept track of anymore
+		if prev*delta >= 0 || math.Abs(delta) > math.Abs(prev) {
+			delete(rm.entries, o.Ticker)
+		}
+
+	case prev*delta >= 0:
+		// opened or added to, average the entry price
+		if prev == 0 || known {
+			rm.entries[o.Ticker] = (entry*math.Abs(prev) + o.Price*math.Abs(delta)) / math.Abs(net)
+		}
+
+	default:
+		// reduced, closed or flipped
+		if known {
+			pnl := (o.Price - entry) * math.Min(math.Abs(prev), math.Abs(delta))
+			if prev < 0 {
+				pnl = -pnl
+			}
+
+			rm.rollDay(rm.now())
+			rm.dailyPnl += pnl
+		}
+
+		if math.Abs(delta) > math.Abs(prev) {
+			rm.entries[o.Ticker] = o.Price
+		}
+	}
+
+	if math.Abs(net) < 1e-12 {
+		delete(rm.net, o.Ticker)
+		delete(rm.entries, o.Ticker)
+	} else {
+		rm.net[o.Ticker] = net
+	}
+
+	if o.Price > 0 {
+		rm.prices[o.Ticker] = o.Price
+	}
+}
+
+// RecordPnL adds realized PNL (negative for a loss) to the PNL of the current day, which the daily loss limit
+// is checked against. PNL of orders reported with Filled is already added, so it's meant for PNL realized
+// otherwise, e.g. fees or funding. Positions closed by protective orders are reported with Copier.Protected.
+func (rm *RiskManager) RecordPnL(pnl float64) {
+	rm.mtx.Lock()
+	defer rm.mtx.Unlock()
+
+	rm.rollDay(rm.now())
+	rm.dailyPnl += pnl
+}
+
+// Exposure returns the current notional of the symbol, or of all symbols if symbol is empty.
+func (rm *RiskManager) Exposure(symbol string) float64 {
+	rm.mtx.Lock()
+	defer rm.mtx.Unlock()
+
+	var total float64
+	for s, net := range rm.net {
+		if symbol == "" || s == symbol {
+			total += math.Abs(net) * rm.prices[s]
+		}
+	}
+
+	return total
+}
+
+// Kill engages the kill switch, rejecting all orders except reduce only ones until Resume is called.
+func (rm *RiskManager) Kill(reason string) {
+	rm.mtx.Lock()
+	defer rm.mtx.Unlock()
+
+	if reason == "" {
+		reason = "no reason given"
+	}
+	rm.killed = reason
+	rm.log.Printf("kill switch engaged: %s\n", reason)
+}
+
+// Resume disengages the kill switch.
+func (rm *RiskManager) Resume() {
+	rm.mtx.Lock()
+	defer rm.mtx.Unlock()
+
+	rm.killed = ""
+	rm.log.Println("kill switch disengaged")
+}
+
+// Killed returns whether or not the kill switch is engaged.
+func (rm *RiskManager) Killed() bool {
+	rm.mtx.Lock()
+	defer rm.mtx.Unlock()
+
+	return rm.killed != ""
+}
+
+// signedAmount returns the amount, negative if the direction is short.
+func signedAmount(d bfldb.TradeDirection, amount float64) float64 {
+	if d == bfldb.Short {
+		return -amount
+	}
+	return amount
+}
+
+// WithMaxNotional sets the maximum notional (amount times price) of a symbol.
+// An empty symbol sets the default for all symbols without their own limit.
+func WithMaxNotional(symbol string, notional float64) RiskOption {
+	return func(rm *RiskManager) {
+		rm.maxNotional[symbol] = notional
+	}
+}
+
+// WithMaxExposure sets the maximum notional across all symbols.
+func WithMaxExposure(notional float64) RiskOption {
+	return func(rm *RiskManager) {
+		rm.maxExposure = notional
+	}
+}
+
+// WithMaxLeverage sets the maximum leverage, orders with a higher one are modified to use the maximum.
+func WithMaxLeverage(n int) RiskOption {
+	return func(rm *RiskManager) {
+		rm.maxLeverage = n
+	}
+}
+
+// WithAllowedSymbols only allows trading the symbols passed in.
+func WithAllowedSymbols(symbols ...string) RiskOption {
+	return func(rm *RiskManager) {
+		for _, s := range symbols {
+			rm.allowed[s] = true
+		}
+	}
+}
+
+// WithDeniedSymbols denies trading the symbols passed in.
+func WithDeniedSymbols(symbols ...string) RiskOption {
+	return func(rm *RiskManager) {
+		for _, s := range symbols {
+			rm.denied[s] = true
+		}
+	}
+}
+
+// WithMaxOrdersPerMinute sets the maximum number of orders allowed within any minute. Reduce only orders are
+// always allowed, but they count towards the limit.
+func WithMaxOrdersPerMinute(n int) RiskOption {
+	return func(rm *RiskManager) {
+		rm.maxOrders = n
+	}
+}
+
+// WithDailyLossLimit sets the maximum realized loss per day (UTC), as estimated from the orders reported with
+// Filled and recorded with RecordPnL. Once it's reached, only reduce only orders are allowed until the next day.
+func WithDailyLossLimit(loss float64) RiskOption {
+	return func(rm *RiskManager) {
+		rm.dailyLossCap = loss
+	}
+}
+
+// WithRiskLogger sets the logger every decision is logged with. Decisions aren't logged by default.
+func WithRiskLogger(l *log.Logger) RiskOption {
+	return func(rm *RiskManager) {
+		rm.log = l
+	}
+}
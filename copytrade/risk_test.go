@@ -0,0 +1,141 @@
+package copytrade
+
+import (
+	"bytes"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiskManager_Check(t *testing.T) {
+	long := func(symbol string, amount, price float64) bfldb.Order {
+		return bfldb.Order{Direction: bfldb.Long, Ticker: symbol, Amount: amount, Price: price, Leverage: 10}
+	}
+
+	tests := []struct {
+		name   string
+		opts   []RiskOption
+		filled []bfldb.Order
+		order  bfldb.Order
+		action Action
+		amount float64
+	}{
+		{name: "no rules", order: long("BTCUSDT", 1, 20000), action: Allow, amount: 1},
+		{name: "denied symbol", opts: []RiskOption{WithDeniedSymbols("BTCUSDT")}, order: long("BTCUSDT", 1, 20000), action: Reject},
+		{name: "not allowed symbol", opts: []RiskOption{WithAllowedSymbols("ETHUSDT")}, order: long("BTCUSDT", 1, 20000), action: Reject},
+		{name: "leverage", opts: []RiskOption{WithMaxLeverage(5)}, order: long("BTCUSDT", 1, 20000), action: Modify, amount: 1},
+		{name: "notional fits", opts: []RiskOption{WithMaxNotional("BTCUSDT", 20000)}, order: long("BTCUSDT", 1, 20000), action: Allow, amount: 1},
+		{name: "notional lowered", opts: []RiskOption{WithMaxNotional("", 10000)}, order: long("BTCUSDT", 1, 20000), action: Modify, amount: 0.5},
+		{name: "notional reached", opts: []RiskOption{WithMaxNotional("", 10000)}, filled: []bfldb.Order{long("BTCUSDT", 0.5, 20000)}, order: long("BTCUSDT", 1, 20000), action: Reject},
+		{
+			name:   "opposite order can flip up to the limit",
+			opts:   []RiskOption{WithMaxNotional("", 10000)},
+			filled: []bfldb.Order{long("BTCUSDT", 0.5, 20000)},
+			order:  bfldb.Order{Direction: bfldb.Short, Ticker: "BTCUSDT", Amount: 2, Price: 20000},
+			action: Modify,
+			amount: 1,
+		},
+		{name: "exposure", opts: []RiskOption{WithMaxExposure(15000)}, filled: []bfldb.Order{long("ETHUSDT", 5, 1000)}, order: long("BTCUSDT", 1, 20000), action: Modify, amount: 0.5},
+		{name: "unknown price", opts: []RiskOption{WithMaxExposure(15000)}, order: long("BTCUSDT", 1, 0), action: Reject},
+		{name: "reduce only", opts: []RiskOption{WithDeniedSymbols("BTCUSDT")}, order: bfldb.Order{Ticker: "BTCUSDT", Amount: 1, ReduceOnly: true}, action: Allow, amount: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm := NewRiskManager(tt.opts...)
+			for _, o := range tt.filled {
+				rm.Filled(o)
+			}
+
+			d := rm.Check(tt.order)
+			require.Equal(t, tt.action, d.Action, d.Reason)
+			require.Equal(t, tt.order, d.Original)
+
+			if tt.action != Allow {
+				require.NotEmpty(t, d.Reason)
+			}
+			if tt.action != Reject {
+				require.InDelta(t, tt.amount, d.Order.Amount, 1e-9)
+			}
+		})
+	}
+}
+
+func TestRiskManager_Limits(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Date(2023, 1, 1, 23, 0, 0, 0, time.UTC)
+
+	rm := NewRiskManager(WithMaxOrdersPerMinute(2), WithDailyLossLimit(100), WithRiskLogger(log.New(&buf, "", 0)))
+	rm.now = func() time.Time { return now }
+
+	o := bfldb.Order{Direction: bfldb.Long, Ticker: "BTCUSDT", Amount: 1}
+	require.Equal(t, Allow, rm.Check(o).Action)
+	require.Equal(t, Allow, rm.Check(o).Action)
+	require.Equal(t, Reject, rm.Check(o).Action)
+
+	// closing positions is never rate limited
+	require.Equal(t, Allow, rm.Check(bfldb.Order{Ticker: "BTCUSDT", Amount: 1, ReduceOnly: true}).Action)
+
+	now = now.Add(time.Minute)
+	require.Equal(t, Allow, rm.Check(o).Action)
+
+	rm.RecordPnL(-60)
+	rm.RecordPnL(-40)
+	require.Equal(t, Reject, rm.Check(o).Action)
+	require.Equal(t, Allow, rm.Check(bfldb.Order{Ticker: "BTCUSDT", Amount: 1, ReduceOnly: true}).Action)
+
+	// the limit resets the next day
+	now = now.Add(time.Hour)
+	require.Equal(t, Allow, rm.Check(o).Action)
+
+	now = now.Add(time.Minute)
+	rm.Kill("manual")
+	require.True(t, rm.Killed())
+	require.Equal(t, Reject, rm.Check(o).Action)
+	rm.Resume()
+	require.Equal(t, Allow, rm.Check(o).Action)
+
+	require.Contains(t, buf.String(), "reject LONG 1 BTCUSDT @ 0: kill switch engaged: manual")
+}
+
+func TestRiskManager_Exposure(t *testing.T) {
+	rm := NewRiskManager()
+	rm.Filled(bfldb.Order{Direction: bfldb.Long, Ticker: "BTCUSDT", Amount: 1, Price: 20000})
+	rm.Filled(bfldb.Order{Direction: bfldb.Short, Ticker: "ETHUSDT", Amount: 2, Price: 1000})
+	require.Equal(t, 22000.0, rm.Exposure(""))
+
+	rm.Filled(bfldb.Order{Direction: bfldb.Short, Ticker: "BTCUSDT", Amount: 1, ReduceOnly: true})
+	require.Equal(t, 2000.0, rm.Exposure(""))
+	require.Equal(t, 0.0, rm.Exposure("BTCUSDT"))
+}
+
+func TestRiskManager_RealizedPnL(t *testing.T) {
+	rm := NewRiskManager(WithDailyLossLimit(100))
+
+	// long 2 at an average of 1050, half closed at 1000 and the rest at 1020
+	rm.Filled(bfldb.Order{Direction: bfldb.Long, Ticker: "ETHUSDT", Amount: 1, Price: 1000})
+	rm.Filled(bfldb.Order{Direction: bfldb.Long, Ticker: "ETHUSDT", Amount: 1, Price: 1100})
+	rm.Filled(bfldb.Order{Direction: bfldb.Short, Ticker: "ETHUSDT", Amount: 1, Price: 1000, ReduceOnly: true})
+	require.InDelta(t, -50.0, rm.dailyPnl, 1e-9)
+
+	// flipped to a short of 1 at 1020
+	rm.Filled(bfldb.Order{Direction: bfldb.Short, Ticker: "ETHUSDT", Amount: 2, Price: 1020})
+	require.InDelta(t, -80.0, rm.dailyPnl, 1e-9)
+
+	o := bfldb.Order{Direction: bfldb.Long, Ticker: "BTCUSDT", Amount: 1}
+	require.Equal(t, Allow, rm.Check(o).Action)
+
+	// the short closed at 1050
+	rm.Filled(bfldb.Order{Direction: bfldb.Long, Ticker: "ETHUSDT", Amount: 1, Price: 1050, ReduceOnly: true})
+	require.InDelta(t, -110.0, rm.dailyPnl, 1e-9)
+	require.Equal(t, Reject, rm.Check(o).Action)
+	require.Zero(t, rm.Exposure("ETHUSDT"))
+
+	// exposure opened at an unknown price doesn't realize any PNL
+	rm.Filled(bfldb.Order{Direction: bfldb.Long, Ticker: "SOLUSDT", Amount: 1})
+	rm.Filled(bfldb.Order{Direction: bfldb.Short, Ticker: "SOLUSDT", Amount: 1, Price: 10, ReduceOnly: true})
+	require.InDelta(t, -110.0, rm.dailyPnl, 1e-9)
+}
@@ -0,0 +1,104 @@
+package copytrade
+
+import (
+	"math"
+
+	"github.com/rtunazzz/bfldb"
+)
+
+// Transform transforms a position change of a trader before it's copied.
+// It returns false if the position change shouldn't be copied at all.
+type Transform func(p bfldb.Position) (bfldb.Position, bool)
+
+// Pipeline combines transforms into a single one, applying them in the order passed in.
+func Pipeline(ts ...Transform) Transform {
+	return func(p bfldb.Position) (bfldb.Position, bool) {
+		for _, t := range ts {
+			var ok bool
+			if p, ok = t(p); !ok {
+				return p, false
+			}
+		}
+		return p, true
+	}
+}
+
+// Inverse copies positions in the opposite direction, fading the trader.
+func Inverse() Transform {
+	return func(p bfldb.Position) (bfldb.Position, bool) {
+		if p.Direction == bfldb.Long {
+			p.Direction = bfldb.Short
+		} else {
+			p.Direction = bfldb.Long
+		}
+
+		p.Pnl, p.Roe = -p.Pnl, -p.Roe
+
+		return p, true
+	}
+}
+
+// OpenOnly ignores positions being added to, so only the initial size of positions is copied.
+func OpenOnly() Transform {
+	return func(p bfldb.Position) (bfldb.Position, bool) {
+		return p, p.Type != bfldb.AddedTo
+	}
+}
+
+// CloseOnly ignores positions being opened or added to, so only closes of positions that were already copied
+// are followed, e.g. to wind down copying a trader.
+func CloseOnly() Transform {
+	return func(p bfldb.Position) (bfldb.Position, bool) {
+		return p, p.Type != bfldb.Opened && p.Type != bfldb.AddedTo
+	}
+}
+
+// Remap copies positions of one symbol to another one, e.g. 1000PEPEUSDT to PEPEUSDT.
+//
+// Amounts are multiplied by the multiplier and prices are divided by it, e.g. by 1000 when remapping 1000PEPEUSDT
+// to PEPEUSDT. Positions of other symbols are left as they are.
+func Remap(from, to string, multiplier float64) Transform {
+	return func(p bfldb.Position) (bfldb.Position, bool) {
+		if p.Ticker != from {
+			return p, true
+		}
+
+		p.Ticker = to
+		p.Amount *= multiplier
+		p.PrevAmount *= multiplier
+		p.EntryPrice /= multiplier
+		p.MarkPrice /= multiplier
+
+		return p, true
+	}
+}
+
+// MinSize ignores positions being opened or added to by less than the amount passed in,
+// before the allocation of the trader is applied.
+// Closes are always followed, so positions that were copied are never left open.
+func MinSize(amount float64) Transform {
+	return func(p bfldb.Position) (bfldb.Position, bool) {
+		if p.Type != bfldb.Opened && p.Type != bfldb.AddedTo {
+			return p, true
+		}
+		return p, math.Abs(p.Amount-p.PrevAmount) >= amount
+	}
+}
+
+// MinNotional ignores positions being opened or added to by less than the notional (amount times price)
+// passed in, before the allocation of the trader is applied.
+// Closes are always followed, so positions that were copied are never left open.
+func MinNotional(notional float64) Transform {
+	return func(p bfldb.Position) (bfldb.Position, bool) {
+		if p.Type != bfldb.Opened && p.Type != bfldb.AddedTo {
+			return p, true
+		}
+
+		price := p.MarkPrice
+		if price == 0 {
+			price = p.EntryPrice
+		}
+
+		return p, math.Abs(p.Amount-p.PrevAmount)*price >= notional
+	}
+}
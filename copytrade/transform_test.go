@@ -0,0 +1,89 @@
+package copytrade
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rtunazzz/bfldb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransforms(t *testing.T) {
+	opened := bfldb.Position{Type: bfldb.Opened, Direction: bfldb.Long, Ticker: "1000PEPEUSDT", Amount: 2, EntryPrice: 0.002, MarkPrice: 0.004, Pnl: 4, Roe: 1}
+	added := opened
+	added.Type, added.PrevAmount, added.Amount = bfldb.AddedTo, 2, 3
+	closed := opened
+	closed.Type, closed.PrevAmount, closed.Amount = bfldb.Closed, 2, 0
+
+	tests := []struct {
+		name string
+		t    Transform
+		in   bfldb.Position
+		out  bfldb.Position
+		ok   bool
+	}{
+		{
+			name: "inverse",
+			t:    Inverse(),
+			in:   opened,
+			out:  bfldb.Position{Type: bfldb.Opened, Direction: bfldb.Short, Ticker: "1000PEPEUSDT", Amount: 2, EntryPrice: 0.002, MarkPrice: 0.004, Pnl: -4, Roe: -1},
+			ok:   true,
+		},
+		{name: "open only opened", t: OpenOnly(), in: opened, out: opened, ok: true},
+		{name: "open only added", t: OpenOnly(), in: added},
+		{name: "close only opened", t: CloseOnly(), in: opened},
+		{name: "close only closed", t: CloseOnly(), in: closed, out: closed, ok: true},
+		{
+			name: "remap",
+			t:    Remap("1000PEPEUSDT", "PEPEUSDT", 1000),
+			in:   added,
+			out:  bfldb.Position{Type: bfldb.AddedTo, Direction: bfldb.Long, Ticker: "PEPEUSDT", PrevAmount: 2000, Amount: 3000, EntryPrice: 0.000002, MarkPrice: 0.000004, Pnl: 4, Roe: 1},
+			ok:   true,
+		},
+		{name: "remap other symbol", t: Remap("BTCUSDT", "BTCBUSD", 1), in: opened, out: opened, ok: true},
+		{name: "min size", t: MinSize(2), in: opened, out: opened, ok: true},
+		{name: "min size too small", t: MinSize(2), in: added},
+		{name: "min size close", t: MinSize(10), in: closed, out: closed, ok: true},
+		{name: "min notional", t: MinNotional(0.01), in: added},
+		{
+			name: "pipeline",
+			t:    Pipeline(Inverse(), Remap("1000PEPEUSDT", "PEPEUSDT", 1000), MinSize(1000)),
+			in:   closed,
+			out:  bfldb.Position{Type: bfldb.Closed, Direction: bfldb.Short, Ticker: "PEPEUSDT", PrevAmount: 2000, EntryPrice: 0.000002, MarkPrice: 0.000004, Pnl: -4, Roe: -1},
+			ok:   true,
+		},
+		{name: "pipeline filtered", t: Pipeline(Inverse(), OpenOnly()), in: added},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, ok := tt.t(tt.in)
+			require.Equal(t, tt.ok, ok)
+			if ok {
+				require.InDeltaMapValues(t, map[string]float64{"entry": tt.out.EntryPrice, "mark": tt.out.MarkPrice}, map[string]float64{"entry": out.EntryPrice, "mark": out.MarkPrice}, 1e-12)
+				out.EntryPrice, out.MarkPrice = tt.out.EntryPrice, tt.out.MarkPrice
+				require.Equal(t, tt.out, out)
+			}
+		})
+	}
+}
+
+func TestCopier_Transform(t *testing.T) {
+	ctx := context.Background()
+	exec := &fakeExecutor{}
+	c := NewCopier(exec, []Trader{{UID: "A", Allocation: 1, Transform: Pipeline(Inverse(), Remap("1000PEPEUSDT", "PEPEUSDT", 1000), OpenOnly())}})
+
+	p := bfldb.Position{Type: bfldb.Opened, Direction: bfldb.Long, Ticker: "1000PEPEUSDT", Amount: 2, MarkPrice: 0.004}
+	require.True(t, c.Copy(ctx, "A", p).Executed())
+
+	p.Type, p.PrevAmount, p.Amount = bfldb.AddedTo, 2, 4
+	require.ErrorIs(t, c.Copy(ctx, "A", p).Err, ErrNotCopied)
+	require.Equal(t, -2000.0, c.Net("PEPEUSDT"))
+
+	// half of the trader's position is closed, so half of the copied one is closed too
+	p.Type, p.PrevAmount, p.Amount = bfldb.PartiallyClosed, 4, 2
+	e := c.Copy(ctx, "A", p)
+	require.True(t, e.Executed())
+	require.Equal(t, bfldb.Order{Direction: bfldb.Long, Ticker: "PEPEUSDT", Amount: 1000, ReduceOnly: true, Price: 0.000004}, e.Decision.Order)
+	require.Equal(t, -1000.0, c.Net("PEPEUSDT"))
+}
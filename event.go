@@ -0,0 +1,92 @@
+package bfldb
+
+import (
+	"context"
+	"time"
+)
+
+// Event is a single event of a subscription created by Subscribe.
+// It's one of PositionChanged, ErrorEvent, Snapshot, SharingChanged or Heartbeat, or one of the profile changes
+// sent by WatchProfile (e.g. NicknameChanged).
+type Event interface {
+	// Meta returns metadata common to all events.
+	Meta() EventMeta
+
+	isEvent()
+}
+
+// EventMeta is metadata common to all events.
+type EventMeta struct {
+	UID  string    `json:"uid"`  // Encrypted UID of the user the event belongs to
+	Seq  uint64    `json:"seq"`  // Sequence number of the event within its subscription, starting at 1
+	Time time.Time `json:"time"` // Time the event occured at
+}
+
+// Meta implements Event.
+func (m EventMeta) Meta() EventMeta { return m }
+
+func (EventMeta) isEvent() {}
+
+// PositionChanged is sent whenever one of user's positions changes.
+type PositionChanged struct {
+	EventMeta
+	Position Position `json:"position"` // The changed position
+}
+
+// ErrorEvent is sent whenever an error occurs during the subscription. The subscription carries on afterwards.
+type ErrorEvent struct {
+	EventMeta
+	Err error `json:"-"` // The error that occured
+}
+
+// Snapshot is sent with all of user's currently open positions whenever positions had to be resynchronized,
+// e.g. after user started sharing their positions again, or as the very first event of a subscription,
+// see WithInitialSnapshot. Positions of a snapshot are not new, so their type is Existing.
+type Snapshot struct {
+	EventMeta
+	Initial   bool       `json:"initial"`   // Whether or not it's the initial snapshot of the subscription
+	Positions []Position `json:"positions"` // All currently open positions, sorted by ticker
+}
+
+// SharingChanged is sent whenever user starts or stops sharing their positions, see WithSharingCheck.
+type SharingChanged struct {
+	EventMeta
+	Shared bool `json:"shared"` // Whether or not user is sharing their positions now
+}
+
+// Heartbeat is sent after every successful poll of user's positions.
+type Heartbeat struct {
+	EventMeta
+}
+
+var (
+	_ Event = PositionChanged{}
+	_ Event = ErrorEvent{}
+	_ Event = Snapshot{}
+	_ Event = SharingChanged{}
+	_ Event = Heartbeat{}
+)
+
+// Subscribe subscribes to user's positions in a new goroutine, sending all events through a single channel.
+//
+// Positions are polled in the same way as with SubscribePositions. The channel is closed as soon as the context
+// is cancelled.
+func (u *User) Subscribe(ctx context.Context) <-chan Event {
+	c := make(chan Event)
+
+	go func() {
+		defer close(c)
+
+		s := u.newSubscription(func(ctx context.Context, e Event) bool {
+			select {
+			case c <- e:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+		s.run(ctx)
+	}()
+
+	return c
+}
@@ -0,0 +1,159 @@
+package bfldb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// eventKind returns the type name of the event, for comparing sequences of events.
+func eventKind(e Event) string {
+	switch e := e.(type) {
+	case PositionChanged:
+		return "position " + e.Position.Type.String() + " " + e.Position.Ticker
+	case ErrorEvent:
+		return "error"
+	case Snapshot:
+		tickers := make([]string, 0, len(e.Positions))
+		for _, p := range e.Positions {
+			tickers = append(tickers, p.Ticker)
+		}
+		return "snapshot " + strings.Join(tickers, ",")
+	case SharingChanged:
+		if e.Shared {
+			return "sharing on"
+		}
+		return "sharing off"
+	case Heartbeat:
+		return "heartbeat"
+	}
+	return ""
+}
+
+// collect receives n events from the channel.
+func collect(t *testing.T, c <-chan Event, n int) []Event {
+	t.Helper()
+
+	es := make([]Event, 0, n)
+	for len(es) < n {
+		select {
+		case e := <-c:
+			es = append(es, e)
+		case <-time.After(time.Second):
+			t.Fatalf("expected %d events, got %d", n, len(es))
+		}
+	}
+
+	return es
+}
+
+func TestSubscribe(t *testing.T) {
+	var calls int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			w.Write([]byte(`{"success":true,"data":{"otherPositionRetList":[]}}`))
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte(`{"success":true,"data":{"otherPositionRetList":[{"symbol":"BTCUSDT","amount":1}]}}`))
+		}
+	}))
+	defer api.Close()
+
+	u := NewUser("A", WithAPIBase(api.URL), WithCustomRefresh(time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	c := u.Subscribe(ctx)
+
+	es := collect(t, c, 5)
+	kinds := make([]string, 0, len(es))
+	for i, e := range es {
+		require.Equal(t, "A", e.Meta().UID)
+		require.Equal(t, uint64(i+1), e.Meta().Seq)
+		require.False(t, e.Meta().Time.IsZero())
+		kinds = append(kinds, eventKind(e))
+	}
+	require.Equal(t, []string{"heartbeat", "error", "position opened BTCUSDT", "heartbeat", "heartbeat"}, kinds)
+
+	var bse BadStatusError
+	require.ErrorAs(t, es[1].(ErrorEvent).Err, &bse)
+	require.Equal(t, http.StatusTooManyRequests, bse.StatusCode)
+
+	// the channel gets closed after the context is cancelled
+	cancel()
+	for range c {
+	}
+}
+
+func TestSubscribe_Sharing(t *testing.T) {
+	var infoCalls int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/getOtherLeaderboardBaseInfo") {
+			if atomic.AddInt32(&infoCalls, 1) == 1 {
+				w.Write([]byte(`{"success":true,"data":{"positionShared":false}}`))
+				return
+			}
+			w.Write([]byte(`{"success":true,"data":{"positionShared":true}}`))
+			return
+		}
+		w.Write([]byte(`{"success":true,"data":{"otherPositionRetList":[{"symbol":"BTCUSDT","amount":1}]}}`))
+	}))
+	defer api.Close()
+
+	u := NewUser("A", WithAPIBase(api.URL), WithCustomRefresh(time.Millisecond), WithSharingCheck(time.Nanosecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	kinds := make([]string, 0, 5)
+	for _, e := range collect(t, u.Subscribe(ctx), 5) {
+		kinds = append(kinds, eventKind(e))
+	}
+
+	// positions visible after sharing turns back on are not reported as opened
+	require.Equal(t, []string{"sharing off", "heartbeat", "sharing on", "snapshot BTCUSDT", "heartbeat"}, kinds)
+}
+
+func TestSubscribe_InitialSnapshot(t *testing.T) {
+	api := newSequenceAPI(t,
+		`[{"symbol":"ETHUSDT","amount":-2,"leverage":5},{"symbol":"BTCUSDT","amount":1,"leverage":10}]`,
+		`[{"symbol":"ETHUSDT","amount":-2,"leverage":5},{"symbol":"BTCUSDT","amount":2,"leverage":10}]`,
+	)
+
+	u := NewUser("A", WithAPIBase(api.URL), WithCustomRefresh(time.Millisecond), WithInitialSnapshot())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	es := collect(t, u.Subscribe(ctx), 3)
+
+	snap, ok := es[0].(Snapshot)
+	require.True(t, ok, "expected the first event to be a snapshot, got %T", es[0])
+	require.True(t, snap.Initial)
+	require.Equal(t, []Position{
+		{Type: Existing, Direction: Long, Ticker: "BTCUSDT", Amount: 1, Leverage: 10},
+		{Type: Existing, Direction: Short, Ticker: "ETHUSDT", Amount: 2, Leverage: 5},
+	}, snap.Positions)
+
+	require.Equal(t, "heartbeat", eventKind(es[1]))
+	require.Equal(t, "position added to BTCUSDT", eventKind(es[2]))
+}
+
+func TestSubscribePositions_InitialSnapshot(t *testing.T) {
+	api := newSequenceAPI(t,
+		`[{"symbol":"BTCUSDT","amount":1}]`,
+		`[]`,
+	)
+
+	u := NewUser("A", WithAPIBase(api.URL), WithCustomRefresh(time.Millisecond), WithInitialSnapshot())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cp, _ := u.SubscribePositions(ctx)
+	require.Equal(t, Existing, (<-cp).Type)
+	require.Equal(t, Closed, (<-cp).Type)
+}
@@ -11,16 +11,15 @@ func main() {
 	// You can find this UID (encryptedUid) in the end of a leaderboard profile URL. For example:
 	// https://www.binance.com/en/futures-activity/leaderboard/user?encryptedUid=47E6D002EBB1173967A6561F72B9395C
 	u := bfldb.NewUser("47E6D002EBB1173967A6561F72B9395C")
-	cp, ce := u.SubscribePositions(context.Background())
 
-	for {
-		select {
-		case position := <-cp:
+	// the channel is closed once the context is cancelled
+	for e := range u.Subscribe(context.Background()) {
+		switch e := e.(type) {
+		case bfldb.PositionChanged:
 			// Handle the new position as you need... Send a notification, copytrade...
-			fmt.Printf("new position: %+v\n", position)
-		case err := <-ce:
-			fmt.Println("error has occured:", err)
-			break
+			fmt.Printf("new position: %+v\n", e.Position)
+		case bfldb.ErrorEvent:
+			fmt.Println("error has occured:", e.Err)
 		}
 	}
 }
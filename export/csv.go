@@ -0,0 +1,65 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// CSVWriter writes records as CSV, starting with a header of the column names.
+// Times are formatted as RFC 3339 in UTC.
+type CSVWriter struct {
+	w      *csv.Writer // underlying CSV writer
+	header bool        // whether or not the header was written already
+}
+
+// NewCSVWriter creates a new CSVWriter writing to w.
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+// Write implements Writer.
+func (cw *CSVWriter) Write(r Record) error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+
+	row := make([]string, 0, len(columns))
+	for _, c := range columns {
+		switch v := c.value(r).(type) {
+		case time.Time:
+			row = append(row, v.UTC().Format(time.RFC3339Nano))
+		case string:
+			row = append(row, v)
+		case float64:
+			row = append(row, strconv.FormatFloat(v, 'f', -1, 64))
+		case int:
+			row = append(row, strconv.Itoa(v))
+		}
+	}
+
+	return cw.w.Write(row)
+}
+
+// Close implements Writer.
+func (cw *CSVWriter) Close() error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// writeHeader writes the header, unless it was written already.
+func (cw *CSVWriter) writeHeader() error {
+	if cw.header {
+		return nil
+	}
+
+	cw.header = true
+	return cw.w.Write(Columns())
+}
+
+var _ Writer = (*CSVWriter)(nil)
@@ -0,0 +1,150 @@
+// Package export writes position changes and snapshots of bfldb users as CSV or Parquet,
+// with a stable column schema, see Columns.
+//
+// Usage:
+//
+//	w := export.NewCSVWriter(os.Stdout)
+//	defer w.Close()
+//
+//	for e := range u.Subscribe(ctx) {
+//		if pc, ok := e.(bfldb.PositionChanged); ok {
+//			w.Write(export.NewRecord(pc.UID, "", pc.Time, pc.Position))
+//		}
+//	}
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+)
+
+// Record is a single exported position of a user. It's also the format of the JSON lines
+// written by `bfldb watch --json`, which can be read back with DecodeJSONL.
+type Record struct {
+	Time       time.Time            `json:"time"`               // When the position changed or the snapshot was taken
+	UID        string               `json:"uid"`                // Encrypted UID of the user
+	Nickname   string               `json:"nickname,omitempty"` // Nickname of the user, if known
+	Type       bfldb.PositionType   `json:"type"`               // Position type, Existing for positions of snapshots
+	Direction  bfldb.TradeDirection `json:"direction"`          // LONG / SHORT
+	Ticker     string               `json:"ticker"`             // Ticker of the position (e.g. BTCUSDT)
+	Amount     float64              `json:"amount"`             // Current amount
+	PrevAmount float64              `json:"prevAmount"`         // Previous amount
+	EntryPrice float64              `json:"entryPrice"`         // Entry price
+	MarkPrice  float64              `json:"markPrice"`          // Mark price
+	Leverage   int                  `json:"leverage"`           // Leverage
+	Pnl        float64              `json:"pnl"`                // PNL
+	Roe        float64              `json:"roe"`                // ROE
+}
+
+// NewRecord creates a new Record from a position of the user, changed at t.
+func NewRecord(UID, nickname string, t time.Time, p bfldb.Position) Record {
+	return Record{
+		Time:       t,
+		UID:        UID,
+		Nickname:   nickname,
+		Type:       p.Type,
+		Direction:  p.Direction,
+		Ticker:     p.Ticker,
+		Amount:     p.Amount,
+		PrevAmount: p.PrevAmount,
+		EntryPrice: p.EntryPrice,
+		MarkPrice:  p.MarkPrice,
+		Leverage:   p.Leverage,
+		Pnl:        p.Pnl,
+		Roe:        p.Roe,
+	}
+}
+
+// SnapshotRecords creates records from all positions of a snapshot of the user, taken at t.
+func SnapshotRecords(UID, nickname string, t time.Time, data bfldb.UserPositionData) []Record {
+	ps := data.Positions()
+
+	rs := make([]Record, 0, len(ps))
+	for _, p := range ps {
+		rs = append(rs, NewRecord(UID, nickname, t, p))
+	}
+
+	return rs
+}
+
+// Writer writes records in a specific format.
+type Writer interface {
+	// Write writes a single record.
+	Write(r Record) error
+
+	// Close flushes all records written. It doesn't close the underlying writer.
+	Close() error
+}
+
+// DecodeJSONL decodes records from JSON lines, such as the ones written by `bfldb watch --json`,
+// calling fn with every one of them. It stops at the first error returned by fn.
+func DecodeJSONL(r io.Reader, fn func(Record) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return fmt.Errorf("failed to decode line %d: %w", line, err)
+		}
+
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+
+	return sc.Err()
+}
+
+// kind is the kind of values of a column.
+type kind int
+
+const (
+	kindTime kind = iota + 1
+	kindString
+	kindFloat
+	kindInt
+)
+
+// column is a single column of the export schema.
+type column struct {
+	name  string           // name of the column
+	kind  kind             // kind of its values
+	value func(Record) any // returns the value of the record, of a type matching the kind
+}
+
+// columns is the export schema. Columns must never be reordered or removed, only appended.
+var columns = []column{
+	{name: "time", kind: kindTime, value: func(r Record) any { return r.Time }},
+	{name: "uid", kind: kindString, value: func(r Record) any { return r.UID }},
+	{name: "nickname", kind: kindString, value: func(r Record) any { return r.Nickname }},
+	{name: "symbol", kind: kindString, value: func(r Record) any { return r.Ticker }},
+	{name: "side", kind: kindString, value: func(r Record) any { return r.Direction.String() }},
+	{name: "type", kind: kindString, value: func(r Record) any { return r.Type.String() }},
+	{name: "amount", kind: kindFloat, value: func(r Record) any { return r.Amount }},
+	{name: "prev_amount", kind: kindFloat, value: func(r Record) any { return r.PrevAmount }},
+	{name: "entry_price", kind: kindFloat, value: func(r Record) any { return r.EntryPrice }},
+	{name: "mark_price", kind: kindFloat, value: func(r Record) any { return r.MarkPrice }},
+	{name: "leverage", kind: kindInt, value: func(r Record) any { return r.Leverage }},
+	{name: "pnl", kind: kindFloat, value: func(r Record) any { return r.Pnl }},
+	{name: "roe", kind: kindFloat, value: func(r Record) any { return r.Roe }},
+}
+
+// Columns returns the names of the exported columns, in order.
+func Columns() []string {
+	names := make([]string, 0, len(columns))
+	for _, c := range columns {
+		names = append(names, c.name)
+	}
+
+	return names
+}
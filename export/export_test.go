@@ -0,0 +1,77 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+	"github.com/stretchr/testify/require"
+)
+
+var testRecords = []Record{
+	NewRecord("A", "Alpha", time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), bfldb.Position{Type: bfldb.Opened, Direction: bfldb.Long, Ticker: "SOLUSDT", Amount: 10.5, EntryPrice: 21.37, MarkPrice: 21.4, Leverage: 5, Pnl: 0.3, Roe: 0.01}),
+	NewRecord("A", "", time.Date(2023, 1, 2, 4, 0, 0, 0, time.UTC), bfldb.Position{Type: bfldb.PartiallyClosed, Direction: bfldb.Short, Ticker: "BTCUSDT", Amount: 1, PrevAmount: 2, Leverage: 20}),
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCSVWriter(&buf)
+	for _, r := range testRecords {
+		require.NoError(t, w.Write(r))
+	}
+	require.NoError(t, w.Close())
+
+	require.Equal(t, `time,uid,nickname,symbol,side,type,amount,prev_amount,entry_price,mark_price,leverage,pnl,roe
+2023-01-02T03:04:05Z,A,Alpha,SOLUSDT,LONG,opened,10.5,0,21.37,21.4,5,0.3,0.01
+2023-01-02T04:00:00Z,A,,BTCUSDT,SHORT,partially closed,1,2,0,0,20,0,0
+`, buf.String())
+
+	// empty exports still have a header
+	buf.Reset()
+	require.NoError(t, NewCSVWriter(&buf).Close())
+	require.Equal(t, "time,uid,nickname,symbol,side,type,amount,prev_amount,entry_price,mark_price,leverage,pnl,roe\n", buf.String())
+}
+
+func TestParquetWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewParquetWriter(&buf)
+	for _, r := range testRecords {
+		require.NoError(t, w.Write(r))
+	}
+	require.NoError(t, w.Close())
+	require.ErrorIs(t, w.Close(), ErrWriterClosed)
+	require.ErrorIs(t, w.Write(testRecords[0]), ErrWriterClosed)
+
+	b := buf.Bytes()
+	require.Equal(t, parquetMagic, b[:4])
+	require.Equal(t, parquetMagic, b[len(b)-4:])
+
+	footer := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	require.Less(t, footer, len(b)-12)
+	require.Contains(t, string(b[len(b)-8-footer:]), "entry_price")
+
+	// the first column chunk starts right after the magic bytes and holds the PLAIN encoded timestamps
+	require.Contains(t, string(b[4:len(b)-8-footer]), string(binary.LittleEndian.AppendUint64(nil, uint64(testRecords[0].Time.UnixMicro()))))
+}
+
+func TestDecodeJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range testRecords {
+		require.NoError(t, enc.Encode(r))
+	}
+	buf.WriteString("\n")
+
+	var got []Record
+	require.NoError(t, DecodeJSONL(&buf, func(r Record) error {
+		got = append(got, r)
+		return nil
+	}))
+	require.Equal(t, testRecords, got)
+
+	err := DecodeJSONL(bytes.NewBufferString(`{"type":"unknown"}`), func(Record) error { return nil })
+	require.ErrorContains(t, err, "line 1")
+}
@@ -0,0 +1,362 @@
+package export
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+var (
+	ErrWriterClosed = errors.New("writer is closed")
+)
+
+// parquetRowGroupSize is the amount of records buffered before they're written out as a row group.
+const parquetRowGroupSize = 64 * 1024
+
+// parquetMagic starts and ends every Parquet file.
+var parquetMagic = []byte("PAR1")
+
+// Parquet physical types, converted types, encodings and other constants of the format, see
+// https://github.com/apache/parquet-format/blob/master/src/main/thrift/parquet.thrift.
+const (
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetUTF8            = 0
+	parquetTimestampMicros = 10
+
+	parquetRequired      = 0
+	parquetPlain         = 0
+	parquetRLE           = 3
+	parquetUncompressed  = 0
+	parquetDataPage      = 0
+	parquetFormatVersion = 1
+)
+
+// parquetChunk is the metadata of a single column chunk written.
+type parquetChunk struct {
+	offset int64 // offset of its data page
+	size   int64 // size of the page header and data
+	values int64 // number of values
+}
+
+// ParquetWriter writes records as an uncompressed Parquet file, with every column being required and
+// PLAIN encoded. Times are stored as UTC timestamps with microsecond precision.
+//
+// Records are buffered and written out in row groups, the file isn't valid until the writer is closed.
+type ParquetWriter struct {
+	w      io.Writer        // underlying writer
+	offset int64            // amount of bytes written so far
+	rows   []Record         // records buffered for the next row group
+	groups [][]parquetChunk // column chunks of the row groups written, by row group
+	nrows  []int64          // number of rows of the row groups written
+	closed bool             // whether or not the writer was closed
+}
+
+// NewParquetWriter creates a new ParquetWriter writing to w.
+func NewParquetWriter(w io.Writer) *ParquetWriter {
+	return &ParquetWriter{w: w}
+}
+
+// Write implements Writer.
+func (pw *ParquetWriter) Write(r Record) error {
+	if pw.closed {
+		return ErrWriterClosed
+	}
+
+	pw.rows = append(pw.rows, r)
+	if len(pw.rows) >= parquetRowGroupSize {
+		return pw.flush()
+	}
+
+	return nil
+}
+
+// Close implements Writer, writing out the remaining records and the file footer.
+func (pw *ParquetWriter) Close() error {
+	if pw.closed {
+		return ErrWriterClosed
+	}
+
+	if err := pw.flush(); err != nil {
+		return err
+	}
+	pw.closed = true
+
+	if err := pw.writeMagic(); err != nil {
+		return err
+	}
+
+	footer := pw.footer()
+
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(footer)))
+
+	for _, b := range [][]byte{footer, size[:], parquetMagic} {
+		if err := pw.write(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// write writes b to the underlying writer, keeping track of the offset.
+func (pw *ParquetWriter) write(b []byte) error {
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+	return err
+}
+
+// writeMagic writes the magic bytes starting the file, unless they were written already.
+func (pw *ParquetWriter) writeMagic() error {
+	if pw.offset > 0 {
+		return nil
+	}
+
+	return pw.write(parquetMagic)
+}
+
+// flush writes the buffered records out as a row group, with a single data page per column.
+func (pw *ParquetWriter) flush() error {
+	if len(pw.rows) == 0 {
+		return nil
+	}
+
+	if err := pw.writeMagic(); err != nil {
+		return err
+	}
+
+	chunks := make([]parquetChunk, 0, len(columns))
+	for _, c := range columns {
+		var data []byte
+		for _, r := range pw.rows {
+			data = appendPlain(data, c.kind, c.value(r))
+		}
+
+		var t thriftWriter
+		t.structBegin()
+		t.i32(1, parquetDataPage)
+		t.i32(2, int32(len(data)))
+		t.i32(3, int32(len(data)))
+		t.structField(5)
+		t.i32(1, int32(len(pw.rows)))
+		t.i32(2, parquetPlain)
+		t.i32(3, parquetRLE)
+		t.i32(4, parquetRLE)
+		t.structEnd()
+		t.structEnd()
+
+		chunk := parquetChunk{offset: pw.offset, size: int64(len(t.buf) + len(data)), values: int64(len(pw.rows))}
+		if err := pw.write(t.buf); err != nil {
+			return err
+		}
+		if err := pw.write(data); err != nil {
+			return err
+		}
+
+		chunks = append(chunks, chunk)
+	}
+
+	pw.groups = append(pw.groups, chunks)
+	pw.nrows = append(pw.nrows, int64(len(pw.rows)))
+	pw.rows = pw.rows[:0]
+
+	return nil
+}
+
+// footer encodes the file metadata.
+func (pw *ParquetWriter) footer() []byte {
+	var total int64
+	for _, n := range pw.nrows {
+		total += n
+	}
+
+	var t thriftWriter
+	t.structBegin()
+	t.i32(1, parquetFormatVersion)
+
+	// schema, starting with the root
+	t.list(2, thriftStruct, len(columns)+1)
+	t.structBegin()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(columns)))
+	t.structEnd()
+	for _, c := range columns {
+		typ, converted := parquetType(c.kind)
+
+		t.structBegin()
+		t.i32(1, typ)
+		t.i32(3, parquetRequired)
+		t.binary(4, c.name)
+		if converted >= 0 {
+			t.i32(6, converted)
+		}
+		t.structEnd()
+	}
+
+	t.i64(3, total)
+
+	t.list(4, thriftStruct, len(pw.groups))
+	for i, chunks := range pw.groups {
+		var size int64
+		for _, ch := range chunks {
+			size += ch.size
+		}
+
+		t.structBegin()
+		t.list(1, thriftStruct, len(chunks))
+		for j, ch := range chunks {
+			typ, _ := parquetType(columns[j].kind)
+
+			t.structBegin()
+			t.i64(2, ch.offset)
+			t.structField(3)
+			t.i32(1, typ)
+			t.list(2, thriftI32, 2)
+			t.listI32(parquetPlain)
+			t.listI32(parquetRLE)
+			t.list(3, thriftBinary, 1)
+			t.listBinary(columns[j].name)
+			t.i32(4, parquetUncompressed)
+			t.i64(5, ch.values)
+			t.i64(6, ch.size)
+			t.i64(7, ch.size)
+			t.i64(9, ch.offset)
+			t.structEnd()
+			t.structEnd()
+		}
+		t.i64(2, size)
+		t.i64(3, pw.nrows[i])
+		t.structEnd()
+	}
+
+	t.binary(6, "bfldb")
+	t.structEnd()
+
+	return t.buf
+}
+
+// parquetType returns the physical and converted type of a column kind, converted type is -1 if there's none.
+func parquetType(k kind) (int32, int32) {
+	switch k {
+	case kindTime:
+		return parquetInt64, parquetTimestampMicros
+	case kindString:
+		return parquetByteArray, parquetUTF8
+	case kindInt:
+		return parquetInt32, -1
+	default:
+		return parquetDouble, -1
+	}
+}
+
+// appendPlain appends a PLAIN encoded value of the kind passed in.
+func appendPlain(b []byte, k kind, v any) []byte {
+	switch k {
+	case kindTime:
+		t := v.(time.Time)
+		return binary.LittleEndian.AppendUint64(b, uint64(t.UnixMicro()))
+	case kindString:
+		s := v.(string)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(s)))
+		return append(b, s...)
+	case kindInt:
+		return binary.LittleEndian.AppendUint32(b, uint32(int32(v.(int))))
+	default:
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(v.(float64)))
+	}
+}
+
+var _ Writer = (*ParquetWriter)(nil)
+
+// Thrift compact protocol types.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs with the Thrift compact protocol, which Parquet's metadata is encoded with.
+type thriftWriter struct {
+	buf    []byte  // encoded data
+	last   int16   // ID of the last field of the current struct
+	parent []int16 // IDs of the last fields of the parent structs
+}
+
+// structBegin begins a struct, either the top level one or an element of a list.
+func (t *thriftWriter) structBegin() {
+	t.parent = append(t.parent, t.last)
+	t.last = 0
+}
+
+// structField begins a struct as a field of the current struct.
+func (t *thriftWriter) structField(id int16) {
+	t.field(id, thriftStruct)
+	t.structBegin()
+}
+
+// structEnd ends the current struct.
+func (t *thriftWriter) structEnd() {
+	t.buf = append(t.buf, 0)
+	t.last = t.parent[len(t.parent)-1]
+	t.parent = t.parent[:len(t.parent)-1]
+}
+
+// field writes a field header.
+func (t *thriftWriter) field(id int16, typ byte) {
+	if d := id - t.last; d > 0 && d <= 15 {
+		t.buf = append(t.buf, byte(d)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.buf = binary.AppendVarint(t.buf, int64(id))
+	}
+	t.last = id
+}
+
+// i32 writes an i32 field.
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.listI32(v)
+}
+
+// i64 writes an i64 field.
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.buf = binary.AppendVarint(t.buf, v)
+}
+
+// binary writes a binary (string) field.
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.listBinary(s)
+}
+
+// list writes the header of a list field of n elements of the type passed in.
+// It has to be followed by exactly n elements.
+func (t *thriftWriter) list(id int16, typ byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf = append(t.buf, byte(n)<<4|typ)
+	} else {
+		t.buf = append(t.buf, 0xf0|typ)
+		t.buf = binary.AppendUvarint(t.buf, uint64(n))
+	}
+}
+
+// listI32 writes an i32 list element.
+func (t *thriftWriter) listI32(v int32) {
+	t.buf = binary.AppendVarint(t.buf, int64(v))
+}
+
+// listBinary writes a binary (string) list element.
+func (t *thriftWriter) listBinary(s string) {
+	t.buf = binary.AppendUvarint(t.buf, uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
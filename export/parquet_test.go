@@ -0,0 +1,263 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Thrift compact protocol types, as defined by the protocol rather than by thriftWriter.
+const (
+	compactI32    = 5
+	compactI64    = 6
+	compactBinary = 8
+	compactList   = 9
+	compactStruct = 12
+)
+
+// thriftField is a single decoded field of a Thrift struct.
+type thriftField struct {
+	typ   byte // compact protocol type of the field
+	value any  // int64, float64, string, []any or thriftFields
+}
+
+// thriftFields is a decoded Thrift struct, its fields mapped by their ID.
+type thriftFields map[int16]thriftField
+
+// thriftReader decodes the Thrift compact protocol, independently of thriftWriter.
+type thriftReader struct {
+	t   *testing.T
+	buf []byte
+}
+
+func (r *thriftReader) byte() byte {
+	require.NotEmpty(r.t, r.buf, "unexpected end of thrift data")
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.buf)
+	require.Positive(r.t, n, "invalid varint")
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) readStruct() thriftFields {
+	s := make(thriftFields)
+
+	var last int16
+	for {
+		h := r.byte()
+		if h == 0 {
+			return s
+		}
+
+		typ := h & 0x0f
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		last = id
+
+		s[id] = thriftField{typ: typ, value: r.readValue(typ)}
+	}
+}
+
+func (r *thriftReader) readValue(typ byte) any {
+	switch typ {
+	case 1, 2: // booleans are encoded in the type
+		return int64(2 - typ)
+	case 3:
+		return int64(int8(r.byte()))
+	case 4, 5, 6:
+		return r.zigzag()
+	case 7:
+		require.GreaterOrEqual(r.t, len(r.buf), 8)
+		v := math.Float64frombits(binary.LittleEndian.Uint64(r.buf))
+		r.buf = r.buf[8:]
+		return v
+	case 8:
+		n := int(r.uvarint())
+		require.GreaterOrEqual(r.t, len(r.buf), n)
+		s := string(r.buf[:n])
+		r.buf = r.buf[n:]
+		return s
+	case 9, 10:
+		h := r.byte()
+		n := int(h >> 4)
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+
+		vs := make([]any, n)
+		for i := range vs {
+			vs[i] = r.readValue(h & 0x0f)
+		}
+		return vs
+	case 12:
+		return r.readStruct()
+	default:
+		r.t.Fatalf("unsupported thrift type %d", typ)
+		return nil
+	}
+}
+
+// get returns the value of the field with the ID passed in, making sure it's of the type passed in.
+func (s thriftFields) get(t *testing.T, id int16, typ byte) any {
+	t.Helper()
+
+	f, ok := s[id]
+	require.Truef(t, ok, "missing field %d", id)
+	require.Equalf(t, typ, f.typ, "type of field %d", id)
+
+	return f.value
+}
+
+func TestParquetWriter_Decode(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewParquetWriter(&buf)
+	for _, r := range testRecords {
+		require.NoError(t, w.Write(r))
+	}
+	require.NoError(t, w.Close())
+
+	b := buf.Bytes()
+	size := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	footerStart := len(b) - 8 - size
+
+	r := &thriftReader{t: t, buf: b[footerStart : len(b)-8]}
+	meta := r.readStruct()
+	require.Empty(t, r.buf, "trailing bytes after the footer")
+
+	// physical types, converted types and values of the columns, see parquet.thrift
+	const (
+		int32Type, int64Type, doubleType, byteArrayType = 1, 2, 5, 6
+		utf8, timestampMicros, none                     = 0, 10, -1
+	)
+	want := []struct {
+		name      string
+		typ       int64
+		converted int64
+		values    []any
+	}{
+		{"time", int64Type, timestampMicros, []any{testRecords[0].Time.UnixMicro(), testRecords[1].Time.UnixMicro()}},
+		{"uid", byteArrayType, utf8, []any{"A", "A"}},
+		{"nickname", byteArrayType, utf8, []any{"Alpha", ""}},
+		{"symbol", byteArrayType, utf8, []any{"SOLUSDT", "BTCUSDT"}},
+		{"side", byteArrayType, utf8, []any{"LONG", "SHORT"}},
+		{"type", byteArrayType, utf8, []any{"opened", "partially closed"}},
+		{"amount", doubleType, none, []any{10.5, 1.0}},
+		{"prev_amount", doubleType, none, []any{0.0, 2.0}},
+		{"entry_price", doubleType, none, []any{21.37, 0.0}},
+		{"mark_price", doubleType, none, []any{21.4, 0.0}},
+		{"leverage", int32Type, none, []any{int64(5), int64(20)}},
+		{"pnl", doubleType, none, []any{0.3, 0.0}},
+		{"roe", doubleType, none, []any{0.01, 0.0}},
+	}
+
+	// FileMetaData
+	require.Equal(t, int64(1), meta.get(t, 1, compactI32))
+	require.Equal(t, int64(len(testRecords)), meta.get(t, 3, compactI64))
+	require.Equal(t, "bfldb", meta.get(t, 6, compactBinary))
+
+	schema := meta.get(t, 2, compactList).([]any)
+	require.Len(t, schema, len(want)+1)
+
+	root := schema[0].(thriftFields)
+	require.Equal(t, "schema", root.get(t, 4, compactBinary))
+	require.Equal(t, int64(len(want)), root.get(t, 5, compactI32))
+
+	for i, c := range want {
+		el := schema[i+1].(thriftFields)
+		require.Equal(t, c.typ, el.get(t, 1, compactI32), c.name)
+		require.Equal(t, int64(0), el.get(t, 3, compactI32), c.name) // REQUIRED
+		require.Equal(t, c.name, el.get(t, 4, compactBinary))
+
+		if c.converted == none {
+			require.NotContains(t, el, int16(6), c.name)
+		} else {
+			require.Equal(t, c.converted, el.get(t, 6, compactI32), c.name)
+		}
+	}
+
+	groups := meta.get(t, 4, compactList).([]any)
+	require.Len(t, groups, 1)
+
+	group := groups[0].(thriftFields)
+	require.Equal(t, int64(len(testRecords)), group.get(t, 3, compactI64))
+
+	chunks := group.get(t, 1, compactList).([]any)
+	require.Len(t, chunks, len(want))
+
+	// column chunks follow each other right after the leading magic bytes, up to the footer
+	offset := int64(4)
+	for i, c := range want {
+		chunk := chunks[i].(thriftFields)
+		require.Equal(t, offset, chunk.get(t, 2, compactI64), c.name)
+
+		// ColumnMetaData
+		cm := chunk.get(t, 3, compactStruct).(thriftFields)
+		require.Equal(t, c.typ, cm.get(t, 1, compactI32), c.name)
+		require.Equal(t, []any{int64(0), int64(3)}, cm.get(t, 2, compactList), c.name) // PLAIN, RLE
+		require.Equal(t, []any{c.name}, cm.get(t, 3, compactList))
+		require.Equal(t, int64(0), cm.get(t, 4, compactI32), c.name) // UNCOMPRESSED
+		require.Equal(t, int64(len(testRecords)), cm.get(t, 5, compactI64), c.name)
+		require.Equal(t, offset, cm.get(t, 9, compactI64), c.name)
+
+		chunkSize := cm.get(t, 7, compactI64).(int64)
+		require.Equal(t, chunkSize, cm.get(t, 6, compactI64), c.name)
+
+		// PageHeader
+		r := &thriftReader{t: t, buf: b[offset : offset+chunkSize]}
+		page := r.readStruct()
+		require.Equal(t, int64(0), page.get(t, 1, compactI32), c.name) // DATA_PAGE
+
+		pageSize := page.get(t, 3, compactI32).(int64)
+		require.Equal(t, pageSize, page.get(t, 2, compactI32), c.name)
+		require.Equal(t, int64(len(r.buf)), pageSize, c.name)
+
+		// DataPageHeader
+		dp := page.get(t, 5, compactStruct).(thriftFields)
+		require.Equal(t, int64(len(testRecords)), dp.get(t, 1, compactI32), c.name)
+		require.Equal(t, int64(0), dp.get(t, 2, compactI32), c.name) // PLAIN
+		require.Equal(t, int64(3), dp.get(t, 3, compactI32), c.name) // RLE
+		require.Equal(t, int64(3), dp.get(t, 4, compactI32), c.name) // RLE
+
+		// PLAIN encoded values, required columns have no levels
+		data := r.buf
+		var values []any
+		for len(data) > 0 {
+			switch c.typ {
+			case int32Type:
+				values = append(values, int64(int32(binary.LittleEndian.Uint32(data))))
+				data = data[4:]
+			case int64Type:
+				values = append(values, int64(binary.LittleEndian.Uint64(data)))
+				data = data[8:]
+			case doubleType:
+				values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(data)))
+				data = data[8:]
+			case byteArrayType:
+				n := binary.LittleEndian.Uint32(data)
+				values = append(values, string(data[4:4+n]))
+				data = data[4+n:]
+			}
+		}
+		require.Equal(t, c.values, values, c.name)
+
+		offset += chunkSize
+	}
+
+	require.Equal(t, int64(footerStart), offset)
+	require.Equal(t, offset-4, group.get(t, 2, compactI64))
+}
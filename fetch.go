@@ -1,12 +1,15 @@
 package bfldb
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 )
 
 const (
@@ -31,7 +34,7 @@ var defaultHeaders = map[string]string{
 }
 
 // LdbAPIRes represents a response from Binance's Futures LDB API.
-type LdbAPIRes[T UserPositionData | UserBaseInfo | []NicknameDetails] struct {
+type LdbAPIRes[T UserPositionData | UserBaseInfo | UserPerformance | []NicknameDetails] struct {
 	Success       bool        `json:"success"`       // Whether or not the request was successful
 	Code          string      `json:"code"`          // Error code, "000000" means success
 	Message       string      `json:"message"`       // Error message
@@ -48,6 +51,18 @@ type UserPositionData struct {
 	UpdateTime           []int         `json:"updateTime"`           // Time array in the format of [YEAR, MONTH, DAY, HOUR, MINUTE, SECOND, ... ]
 }
 
+// Positions parses the raw positions, which are all marked as Existing, since they're just a snapshot.
+func (d UserPositionData) Positions() []Position {
+	ps := make([]Position, 0, len(d.OtherPositionRetList))
+	for _, rp := range d.OtherPositionRetList {
+		p := newPosition(rp)
+		p.Type = Existing
+		ps = append(ps, p)
+	}
+
+	return ps
+}
+
 // rawPosition represent details of an individual position returned.
 type rawPosition struct {
 	Symbol          string  `json:"symbol"`          // Position symbol
@@ -63,6 +78,12 @@ type rawPosition struct {
 	Leverage        int     `json:"leverage"`        // leverage used
 }
 
+// OtherPositionRequest is the request body of /getOtherPosition.
+type OtherPositionRequest struct {
+	EncryptedUID string `json:"encryptedUid"` // Encrypted UID of the user
+	TradeType    string `json:"tradeType"`    // Trade type (e.g. PERPETUAL)
+}
+
 // GetOtherPosition gets all currently open positions for an user.
 func GetOtherPosition(ctx context.Context, UUID string) (LdbAPIRes[UserPositionData], error) {
 	return NewUser(UUID).GetOtherPosition(ctx)
@@ -71,7 +92,7 @@ func GetOtherPosition(ctx context.Context, UUID string) (LdbAPIRes[UserPositionD
 // GetOtherPosition gets all currently open positions for an user.
 func (u *User) GetOtherPosition(ctx context.Context) (LdbAPIRes[UserPositionData], error) {
 	var res LdbAPIRes[UserPositionData]
-	return res, doPost(ctx, u.client, u.APIBase()+"/v1/public/future/leaderboard", "/getOtherPosition", u.Headers(), strings.NewReader(fmt.Sprintf("{\"encryptedUid\":\"%s\",\"tradeType\":\"PERPETUAL\"}", u.UID)), &res)
+	return res, doPost(ctx, u.request("/v1/public/future/leaderboard", "/getOtherPosition", OtherPositionRequest{EncryptedUID: u.UID, TradeType: "PERPETUAL"}), &res)
 }
 
 // ************************************************** /getOtherLeaderboardBaseInfo **************************************************
@@ -91,6 +112,11 @@ type UserBaseInfo struct {
 	OpenID                 interface{} `json:"openId"`                 // ???
 }
 
+// BaseInfoRequest is the request body of /getOtherLeaderboardBaseInfo.
+type BaseInfoRequest struct {
+	EncryptedUID string `json:"encryptedUid"` // Encrypted UID of the user
+}
+
 // GetOtherLeaderboardBaseInfo gets information for the uuid passed in.
 func GetOtherLeaderboardBaseInfo(ctx context.Context, UUID string) (LdbAPIRes[UserBaseInfo], error) {
 	return NewUser(UUID).GetOtherLeaderboardBaseInfo(ctx)
@@ -99,7 +125,51 @@ func GetOtherLeaderboardBaseInfo(ctx context.Context, UUID string) (LdbAPIRes[Us
 // GetOtherLeaderboardBaseInfo gets information about an user.
 func (u *User) GetOtherLeaderboardBaseInfo(ctx context.Context) (LdbAPIRes[UserBaseInfo], error) {
 	var res LdbAPIRes[UserBaseInfo]
-	return res, doPost(ctx, u.client, u.APIBase()+"/v2/public/future/leaderboard", "/getOtherLeaderboardBaseInfo", u.Headers(), strings.NewReader(fmt.Sprintf("{\"encryptedUid\":\"%s\"}", u.UID)), &res)
+	return res, doPost(ctx, u.request("/v2/public/future/leaderboard", "/getOtherLeaderboardBaseInfo", BaseInfoRequest{EncryptedUID: u.UID}), &res)
+}
+
+// ************************************************** /getOtherPerformance **************************************************
+
+// UserPerformance represents user's performance on the leaderboard.
+type UserPerformance struct {
+	PerformanceRetList []PerformanceStat `json:"performanceRetList"` // Statistics of all periods
+	LastTradeTime      int64             `json:"lastTradeTime"`      // Timestamp of the latest trade
+}
+
+// PerformanceStat is a single statistic of user's performance.
+type PerformanceStat struct {
+	PeriodType     string  `json:"periodType"`     // Period (e.g. DAILY, WEEKLY, MONTHLY, ALL)
+	StatisticsType string  `json:"statisticsType"` // Statistic (e.g. ROI, PNL)
+	Value          float64 `json:"value"`          // Value of the statistic, ROI being a fraction
+	Rank           int     `json:"rank"`           // Leaderboard rank for the statistic, 0 if not ranked
+}
+
+// Stat returns the statistic of the period, e.g. Stat("MONTHLY", "ROI").
+func (p UserPerformance) Stat(period, statistic string) (PerformanceStat, bool) {
+	for _, s := range p.PerformanceRetList {
+		if s.PeriodType == period && s.StatisticsType == statistic {
+			return s, true
+		}
+	}
+
+	return PerformanceStat{}, false
+}
+
+// PerformanceRequest is the request body of /getOtherPerformance.
+type PerformanceRequest struct {
+	EncryptedUID string `json:"encryptedUid"` // Encrypted UID of the user
+	TradeType    string `json:"tradeType"`    // Trade type (e.g. PERPETUAL)
+}
+
+// GetOtherPerformance gets performance of the uuid passed in.
+func GetOtherPerformance(ctx context.Context, UUID string) (LdbAPIRes[UserPerformance], error) {
+	return NewUser(UUID).GetOtherPerformance(ctx)
+}
+
+// GetOtherPerformance gets user's performance on the leaderboard.
+func (u *User) GetOtherPerformance(ctx context.Context) (LdbAPIRes[UserPerformance], error) {
+	var res LdbAPIRes[UserPerformance]
+	return res, doPost(ctx, u.request("/v2/public/future/leaderboard", "/getOtherPerformance", PerformanceRequest{EncryptedUID: u.UID, TradeType: "PERPETUAL"}), &res)
 }
 
 // ************************************************** /searchNickname **************************************************
@@ -111,23 +181,92 @@ type NicknameDetails struct {
 	UserPhotoURL  string `json:"userPhotoUrl"`
 }
 
+// SearchNicknameRequest is the request body of /searchNickname.
+type SearchNicknameRequest struct {
+	Nickname string `json:"nickname"` // Nickname searched for
+}
+
 // SearchNickname searches for a nickname.
 func SearchNickname(ctx context.Context, nickname string) (LdbAPIRes[[]NicknameDetails], error) {
-	var res LdbAPIRes[[]NicknameDetails]
-	return res, doPost(ctx, http.DefaultClient, defaultApiBase+"/v1/public/future/leaderboard", "/searchNickname", defaultHeaders, strings.NewReader(fmt.Sprintf("{\"nickname\":\"%s\"}", nickname)), &res)
+	return NewClient().SearchNickname(ctx, nickname)
 }
 
 // ************************************************** Unexported **************************************************
 
-// doPost POSTs the data passed in to the path on Binance's leaderboard API.
-func doPost(ctx context.Context, c *http.Client, endpoint, path string, headers map[string]string, data io.Reader, resPtr any) error {
-	if !strings.HasPrefix(path, "/") {
-		path = "/" + path
+// request is a single request to Binance's leaderboard API.
+type request struct {
+	client      *http.Client      // http client
+	observer    Observer          // observer notified about the request
+	tracer      Tracer            // tracer starting a span around the request
+	middlewares []Middleware      // middlewares wrapping the request
+	UID         string            // UID of the user the request is made for, if any
+	endpoint    string            // endpoint (e.g. https://www.binance.com/bapi/futures/v1/public/future/leaderboard)
+	path        string            // path on the endpoint (e.g. /getOtherPosition)
+	headers     map[string]string // headers
+	body        any               // typed request body
+}
+
+// doPost POSTs the request passed in to Binance's leaderboard API through request's middlewares
+// and decodes the response into resPtr.
+func doPost(ctx context.Context, r request, resPtr any) error {
+	if !strings.HasPrefix(r.path, "/") {
+		r.path = "/" + r.path
+	}
+
+	c := &Call{
+		Endpoint: strings.TrimPrefix(r.path, "/"),
+		URL:      r.endpoint + r.path,
+		UID:      r.UID,
+		Headers:  r.headers,
+		Request:  r.body,
+		Response: resPtr,
+	}
+
+	return chain(r.send, r.middlewares)(ctx, c)
+}
+
+// send sends the call to the API, traces it and reports it to the observer.
+func (r request) send(ctx context.Context, c *Call) error {
+	ctx, span := r.tracer.Start(ctx, SpanRequest)
+	span.SetAttribute(AttrEndpoint, c.Endpoint)
+	if c.UID != "" {
+		span.SetAttribute(AttrUID, c.UID)
 	}
+
+	start := time.Now()
+
+	data, err := json.Marshal(c.Request)
+	if err == nil {
+		err = post(ctx, r.client, c.URL, c.Headers, bytes.NewReader(data), c.Response)
+	} else {
+		err = fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	var statusCode int
+	var bse BadStatusError
+	switch {
+	case err == nil:
+		statusCode = http.StatusOK
+	case errors.As(err, &bse):
+		statusCode = bse.StatusCode
+	}
+
+	r.observer.ObserveRequest(c.Endpoint, statusCode, time.Since(start), err)
+
+	if statusCode != 0 {
+		span.SetAttribute(AttrStatusCode, statusCode)
+	}
+	span.End(err)
+
+	return err
+}
+
+// post POSTs the data passed in to the URL and decodes the response into resPtr.
+func post(ctx context.Context, c *http.Client, url string, headers map[string]string, data io.Reader, resPtr any) error {
 	req, err := http.NewRequestWithContext(
 		ctx,
 		"POST",
-		endpoint+path,
+		url,
 		data,
 	)
 	if err != nil {
@@ -0,0 +1,264 @@
+package bfldb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// tickerQueueSize is the amount of positions queued for a ticker when handlers run concurrently.
+const tickerQueueSize = 64
+
+// PositionHandler handles a single position change.
+type PositionHandler func(ctx context.Context, p Position) error
+
+// SnapshotHandler handles a snapshot of all currently open positions, see WithInitialSnapshot.
+type SnapshotHandler func(ctx context.Context, s Snapshot) error
+
+// ErrorHandler handles errors of a subscription run by User.Run, errors returned by position handlers
+// (HandlerError) and panics recovered from them (PanicError).
+//
+// With WithConcurrentHandlers, it can be called concurrently with position handlers and with itself.
+type ErrorHandler func(ctx context.Context, err error)
+
+// PositionFilter decides whether or not a position should be handled by a handler.
+type PositionFilter func(p Position) bool
+
+// HandlerError is an error returned by a position handler.
+type HandlerError struct {
+	Position Position // Position the handler failed to handle
+	Err      error    // Error returned
+}
+
+func (e HandlerError) Error() string {
+	return fmt.Sprintf("failed to handle %s %s position: %v", e.Position.Type, e.Position.Ticker, e.Err)
+}
+
+func (e HandlerError) Unwrap() error {
+	return e.Err
+}
+
+// PanicError is a panic recovered from a position handler.
+type PanicError struct {
+	Position Position // Position the handler panicked on
+	Value    any      // Value the handler panicked with
+	Stack    []byte   // Stack trace of the panic
+}
+
+func (e PanicError) Error() string {
+	return fmt.Sprintf("handler panicked on %s %s position: %v", e.Position.Type, e.Position.Ticker, e.Value)
+}
+
+var (
+	_ error = HandlerError{}
+	_ error = PanicError{}
+)
+
+// registeredHandler is a position handler along with its filters.
+type registeredHandler struct {
+	handle  PositionHandler
+	filters []PositionFilter
+}
+
+// matches returns whether or not all of the handler's filters match the position.
+func (rh registeredHandler) matches(p Position) bool {
+	for _, f := range rh.filters {
+		if !f(p) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// OnPosition registers a handler called with every position change matching all of the filters passed in,
+// once the user is Run. Handlers are called in the order they were registered in.
+func (u *User) OnPosition(h PositionHandler, filters ...PositionFilter) {
+	u.hMtx.Lock()
+	defer u.hMtx.Unlock()
+
+	u.handlers = append(u.handlers, registeredHandler{handle: h, filters: filters})
+}
+
+// OnSnapshot registers a handler called with every snapshot of user's positions, once the user is Run.
+// Snapshots are handled before any position changes following them.
+func (u *User) OnSnapshot(h SnapshotHandler) {
+	u.hMtx.Lock()
+	defer u.hMtx.Unlock()
+
+	u.snapshotHandlers = append(u.snapshotHandlers, h)
+}
+
+// OnError sets the handler called with every error occured while the user is Run.
+// By default, errors are logged with user's logger.
+func (u *User) OnError(h ErrorHandler) {
+	u.hMtx.Lock()
+	defer u.hMtx.Unlock()
+
+	u.errHandler = h
+}
+
+// Run subscribes to user's positions and passes them to the handlers registered with OnPosition,
+// until the context is cancelled. It returns once all handlers have returned.
+//
+// By default, handlers are run sequentially. With WithConcurrentHandlers, positions of different tickers
+// are handled concurrently, while positions of the same ticker are still handled in order. Snapshots are
+// handled once all positions preceding them were handled.
+func (u *User) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	queues := make(map[string]chan Position)
+
+	// flush waits until all queued positions are handled
+	flush := func() {
+		for t, q := range queues {
+			close(q)
+			delete(queues, t)
+		}
+		wg.Wait()
+	}
+	defer flush()
+
+	for e := range u.Subscribe(ctx) {
+		switch e := e.(type) {
+		case PositionChanged:
+			if !u.concurrentHandlers {
+				u.dispatch(ctx, e.Position)
+				continue
+			}
+
+			q, ok := queues[e.Position.Ticker]
+			if !ok {
+				q = make(chan Position, tickerQueueSize)
+				queues[e.Position.Ticker] = q
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for p := range q {
+						u.dispatch(ctx, p)
+					}
+				}()
+			}
+
+			select {
+			case q <- e.Position:
+			case <-ctx.Done():
+			}
+
+		case Snapshot:
+			flush()
+			u.dispatchSnapshot(ctx, e)
+
+		case ErrorEvent:
+			u.handleError(ctx, e.Err)
+		}
+	}
+
+	return ctx.Err()
+}
+
+// dispatch passes the position to all handlers it matches.
+func (u *User) dispatch(ctx context.Context, p Position) {
+	u.hMtx.RLock()
+	handlers := u.handlers
+	u.hMtx.RUnlock()
+
+	for _, h := range handlers {
+		if !h.matches(p) {
+			continue
+		}
+
+		if err := u.callHandler(ctx, h.handle, p); err != nil {
+			u.handleError(ctx, err)
+		}
+	}
+}
+
+// dispatchSnapshot passes the snapshot to all snapshot handlers.
+func (u *User) dispatchSnapshot(ctx context.Context, snap Snapshot) {
+	u.hMtx.RLock()
+	handlers := u.snapshotHandlers
+	u.hMtx.RUnlock()
+
+	for _, h := range handlers {
+		h := h
+		err := recoverHandler(Position{}, func() error {
+			return h(ctx, snap)
+		})
+		if err != nil {
+			u.handleError(ctx, fmt.Errorf("failed to handle snapshot: %w", err))
+		}
+	}
+}
+
+// callHandler calls the handler, recovering any panic.
+func (u *User) callHandler(ctx context.Context, h PositionHandler, p Position) error {
+	err := recoverHandler(p, func() error {
+		return h(ctx, p)
+	})
+
+	var pe PanicError
+	if err != nil && !errors.As(err, &pe) {
+		return HandlerError{Position: p, Err: err}
+	}
+
+	return err
+}
+
+// recoverHandler calls fn, turning a panic into a PanicError for the position passed in.
+func recoverHandler(p Position, fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = PanicError{Position: p, Value: r, Stack: debug.Stack()}
+		}
+	}()
+
+	return fn()
+}
+
+// handleError passes the error to user's error handler.
+func (u *User) handleError(ctx context.Context, err error) {
+	u.hMtx.RLock()
+	h := u.errHandler
+	u.hMtx.RUnlock()
+
+	if h == nil {
+		u.log.Printf("[%s] %v\n", u.UID, err)
+		return
+	}
+
+	h(ctx, err)
+}
+
+// OnlyTypes matches positions of any of the types passed in.
+func OnlyTypes(ts ...PositionType) PositionFilter {
+	return func(p Position) bool {
+		for _, t := range ts {
+			if p.Type == t {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// OnlyTickers matches positions of any of the tickers passed in.
+func OnlyTickers(tickers ...string) PositionFilter {
+	return func(p Position) bool {
+		for _, t := range tickers {
+			if p.Ticker == t {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// OnlyDirection matches positions of the direction passed in.
+func OnlyDirection(d TradeDirection) PositionFilter {
+	return func(p Position) bool {
+		return p.Direction == d
+	}
+}
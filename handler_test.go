@@ -0,0 +1,212 @@
+package bfldb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newSequenceAPI creates a fake API returning the position lists passed in, one per request, repeating the last one.
+func newSequenceAPI(t *testing.T, lists ...string) *httptest.Server {
+	t.Helper()
+
+	var calls int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(atomic.AddInt32(&calls, 1)) - 1
+		if i >= len(lists) {
+			i = len(lists) - 1
+		}
+		fmt.Fprintf(w, `{"success":true,"data":{"otherPositionRetList":%s}}`, lists[i])
+	}))
+	t.Cleanup(api.Close)
+
+	return api
+}
+
+func TestUser_Run(t *testing.T) {
+	api := newSequenceAPI(t,
+		`[]`,
+		`[{"symbol":"BTCUSDT","amount":1},{"symbol":"ETHUSDT","amount":-1}]`,
+		`[{"symbol":"BTCUSDT","amount":2},{"symbol":"ETHUSDT","amount":-1}]`,
+	)
+
+	u := NewUser("A", WithAPIBase(api.URL), WithCustomRefresh(time.Millisecond))
+
+	var mtx sync.Mutex
+	var opened, longs []string
+	var errs []error
+	done := make(chan struct{})
+
+	u.OnPosition(func(ctx context.Context, p Position) error {
+		mtx.Lock()
+		defer mtx.Unlock()
+		opened = append(opened, p.Ticker)
+		return nil
+	}, OnlyTypes(Opened))
+
+	u.OnPosition(func(ctx context.Context, p Position) error {
+		mtx.Lock()
+		defer mtx.Unlock()
+		longs = append(longs, p.Type.String())
+		if p.Type == AddedTo {
+			close(done)
+		}
+		return errors.New("failed")
+	}, OnlyTickers("BTCUSDT"), OnlyDirection(Long))
+
+	u.OnPosition(func(ctx context.Context, p Position) error {
+		panic("boom")
+	}, OnlyTickers("ETHUSDT"))
+
+	u.OnError(func(ctx context.Context, err error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		errs = append(errs, err)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error)
+	go func() {
+		runErr <- u.Run(ctx)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handlers weren't called")
+	}
+
+	cancel()
+	require.ErrorIs(t, <-runErr, context.Canceled)
+
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	require.ElementsMatch(t, []string{"BTCUSDT", "ETHUSDT"}, opened)
+	require.Equal(t, []string{"opened", "added to"}, longs)
+
+	var he HandlerError
+	var pe PanicError
+	var handlerErrs, panics int
+	for _, err := range errs {
+		switch {
+		case errors.As(err, &pe):
+			panics++
+			require.Equal(t, "boom", pe.Value)
+			require.NotEmpty(t, pe.Stack)
+		case errors.As(err, &he):
+			handlerErrs++
+			require.Equal(t, "BTCUSDT", he.Position.Ticker)
+		}
+	}
+	require.Equal(t, 2, handlerErrs)
+	require.Equal(t, 1, panics)
+}
+
+func TestUser_Run_Concurrent(t *testing.T) {
+	api := newSequenceAPI(t,
+		`[]`,
+		`[{"symbol":"BTCUSDT","amount":1},{"symbol":"ETHUSDT","amount":1}]`,
+		`[{"symbol":"BTCUSDT","amount":2},{"symbol":"ETHUSDT","amount":2}]`,
+		`[{"symbol":"BTCUSDT","amount":3},{"symbol":"ETHUSDT","amount":3}]`,
+	)
+
+	u := NewUser("A", WithAPIBase(api.URL), WithCustomRefresh(time.Millisecond), WithConcurrentHandlers())
+
+	var mtx sync.Mutex
+	amounts := make(map[string][]float64)
+	var wg sync.WaitGroup
+	wg.Add(6)
+
+	u.OnPosition(func(ctx context.Context, p Position) error {
+		// slow handler for BTC, so ETH gets ahead of it
+		if p.Ticker == "BTCUSDT" {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		mtx.Lock()
+		amounts[p.Ticker] = append(amounts[p.Ticker], p.Amount)
+		mtx.Unlock()
+
+		wg.Done()
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go u.Run(ctx)
+
+	wg.Wait()
+
+	// positions of the same ticker are handled in order
+	require.Equal(t, []float64{1, 2, 3}, amounts["BTCUSDT"])
+	require.Equal(t, []float64{1, 2, 3}, amounts["ETHUSDT"])
+}
+
+func TestUser_Run_ConcurrentSnapshot(t *testing.T) {
+	var infoCalls, positionCalls int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/getOtherLeaderboardBaseInfo") {
+			// sharing turns off on the third check and back on afterwards, resynchronizing positions
+			shared := atomic.AddInt32(&infoCalls, 1) != 3
+			fmt.Fprintf(w, `{"success":true,"data":{"positionShared":%t}}`, shared)
+			return
+		}
+
+		if atomic.AddInt32(&positionCalls, 1) == 1 {
+			w.Write([]byte(`{"success":true,"data":{"otherPositionRetList":[]}}`))
+			return
+		}
+		w.Write([]byte(`{"success":true,"data":{"otherPositionRetList":[{"symbol":"BTCUSDT","amount":1}]}}`))
+	}))
+	defer api.Close()
+
+	u := NewUser("A", WithAPIBase(api.URL), WithCustomRefresh(time.Millisecond), WithSharingCheck(time.Nanosecond), WithConcurrentHandlers())
+
+	var mtx sync.Mutex
+	var handled []string
+	done := make(chan struct{})
+
+	u.OnPosition(func(ctx context.Context, p Position) error {
+		// slow handler, so the snapshot arrives while the position is still being handled
+		time.Sleep(50 * time.Millisecond)
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		handled = append(handled, "position "+p.Type.String())
+		return nil
+	})
+
+	u.OnSnapshot(func(ctx context.Context, s Snapshot) error {
+		mtx.Lock()
+		defer mtx.Unlock()
+		handled = append(handled, "snapshot")
+		close(done)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go u.Run(ctx)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("snapshot wasn't handled")
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	// positions preceding the snapshot are handled before it
+	require.Equal(t, []string{"position opened", "snapshot"}, handled)
+}
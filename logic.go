@@ -3,52 +3,330 @@ package bfldb
 import (
 	"context"
 	"fmt"
+	"math/rand"
+	"sort"
 	"time"
 )
 
+// ErrorPolicy determines what happens to errors of a subscription when nobody's receiving them.
+type ErrorPolicy int
+
+const (
+	BufferErrors  ErrorPolicy = iota + 1 // Errors are buffered (see WithErrorBuffer) and dropped once the buffer is full
+	DropErrors                           // Errors are dropped unless the subscriber is ready to receive them
+	BlockOnErrors                        // The subscription waits until the error is received or the context is cancelled
+)
+
 // SubscribePositions subscribes to user's potition details in a new goroutine.
 //
 // Returns two read-only channels, one with user's positions, other with any errors occured during the subsription.
+// Positions are polled on a fixed cadence of user's delay (plus jitter, see WithJitter), so the time a poll takes
+// doesn't add up. Errors are delivered according to user's ErrorPolicy, BufferErrors by default.
+//
+// Both channels are closed as soon as the context is cancelled.
 func (u *User) SubscribePositions(ctx context.Context) (<-chan Position, <-chan error) {
 	cp := make(chan Position)
-	ce := make(chan error)
+
+	var ce chan error
+	if u.errPolicy == BufferErrors {
+		ce = make(chan error, u.errBuffer)
+	} else {
+		ce = make(chan error)
+	}
 
 	go func() {
 		defer close(cp)
 		defer close(ce)
 
-		for {
+		u.newSubscription(u.positionEmitter(cp, ce)).run(ctx)
+	}()
+
+	return cp, ce
+}
+
+// emitter receives events of a running subscription. It returns false if the subscription should stop.
+type emitter func(ctx context.Context, e Event) bool
+
+// positionEmitter creates an emitter sending position changes and positions of snapshots through cp
+// and errors through ce, according to user's ErrorPolicy. Other events are ignored.
+func (u *User) positionEmitter(cp chan<- Position, ce chan<- error) emitter {
+	return func(ctx context.Context, e Event) bool {
+		switch e := e.(type) {
+		case PositionChanged:
 			select {
+			case cp <- e.Position:
 			case <-ctx.Done():
-				return
-
-			default:
-				// u.log.Printf("[%s] Checking for new positions\n", u.id)
-				res, err := u.GetOtherPosition(ctx)
-				if err != nil {
-					ce <- fmt.Errorf("failed to fetch positions: %w", err)
-					time.Sleep(u.Delay())
-					continue
+			}
+		case Snapshot:
+			for _, p := range e.Positions {
+				select {
+				case cp <- p:
+				case <-ctx.Done():
+					return false
 				}
+			}
+		case ErrorEvent:
+			u.sendError(ctx, ce, e.Err)
+		}
 
-				if !res.Success {
-					ce <- fmt.Errorf("failed to fetch positions, bad response message: %v", res.Message)
-					time.Sleep(u.Delay())
-					continue
-				}
+		return ctx.Err() == nil
+	}
+}
 
-				// u.log.Printf("[%s] Updating %d positions\n", u.id, len(res.Data.OtherPositionRetList))
-				u.handlePositions(res.Data.OtherPositionRetList, cp, ce)
-				time.Sleep(u.Delay())
-			}
+// sendError sends the error through the channel according to user's ErrorPolicy.
+func (u *User) sendError(ctx context.Context, ce chan<- error, err error) {
+	if u.errPolicy == BlockOnErrors {
+		select {
+		case ce <- err:
+		case <-ctx.Done():
 		}
-	}()
+		return
+	}
 
-	return cp, ce
+	select {
+	case ce <- err:
+	default:
+		u.log.Printf("[%s] Dropping error nobody's receiving: %v\n", u.UID, err)
+	}
+}
+
+// subscription is a single running subscription to user's positions.
+type subscription struct {
+	u    *User   // user subscribed to
+	emit emitter // receives all events
+	seq  uint64  // sequence number of the latest event
+
+	shared      bool      // whether or not user shared their positions on the latest check
+	lastSharing time.Time // when sharing was last checked
+	resync      bool      // whether or not positions need to be resynchronized on the next poll
+	fetched     bool      // whether or not positions were successfully fetched yet
+}
+
+// newSubscription creates a new subscription passing all of its events to emit.
+//
+// If user has WithInitialSnapshot set, the subscription starts by resynchronizing positions.
+func (u *User) newSubscription(emit emitter) *subscription {
+	return &subscription{u: u, emit: emit, shared: true, resync: u.initialSnapshot}
+}
+
+// meta creates metadata for the next event.
+func (s *subscription) meta() EventMeta {
+	s.seq++
+	return EventMeta{UID: s.u.UID, Seq: s.seq, Time: time.Now()}
+}
+
+// run polls user's positions on a fixed cadence until the context is cancelled or emit asks to stop.
+func (s *subscription) run(ctx context.Context) {
+	u := s.u
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	var lastPoll, next time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		if !lastPoll.IsZero() {
+			u.observer.ObservePoll(u.UID, now.Sub(lastPoll), u.Delay())
+		}
+		lastPoll = now
+
+		if next.IsZero() {
+			next = now
+		}
+
+		if !s.poll(ctx) {
+			return
+		}
+
+		// schedule the next poll on a fixed cadence, if the poll took longer than the delay, poll right away
+		next = next.Add(u.Delay())
+		if now := time.Now(); next.Before(now) {
+			next = now
+		}
+
+		timer.Reset(time.Until(next) + u.randomJitter())
+	}
+}
+
+// poll fetches user's positions once and emits any changes or errors.
+// It returns false if the subscription should stop.
+func (s *subscription) poll(ctx context.Context) bool {
+	u := s.u
+
+	// u.log.Printf("[%s] Checking for new positions\n", u.id)
+	pctx, span := u.tracer.Start(ctx, SpanPoll)
+	span.SetAttribute(AttrUID, u.UID)
+
+	if !s.checkSharing(pctx) {
+		span.End(ctx.Err())
+		return false
+	}
+
+	if !s.shared {
+		// there's nothing to fetch
+		span.End(nil)
+		return s.emit(ctx, Heartbeat{s.meta()})
+	}
+
+	res, err := u.GetOtherPosition(pctx)
+
+	// the subscription was cancelled during the request, so nobody's interested in the outcome
+	if ctx.Err() != nil {
+		span.End(ctx.Err())
+		return false
+	}
+
+	if err == nil && !res.Success {
+		err = fmt.Errorf("bad response message: %v", res.Message)
+	}
+
+	if err != nil {
+		span.End(err)
+		return s.emit(ctx, ErrorEvent{s.meta(), fmt.Errorf("failed to fetch positions: %w", err)})
+	}
+
+	initial := !s.fetched
+	s.fetched = true
+
+	// u.log.Printf("[%s] Updating %d positions\n", u.id, len(res.Data.OtherPositionRetList))
+	if s.resync {
+		// positions aren't new, they were open before the subscription started or just weren't visible for a while
+		u.resetPositions()
+		u.diffPositions(res.Data.OtherPositionRetList)
+		s.resync = false
+
+		if !s.emit(ctx, Snapshot{EventMeta: s.meta(), Initial: initial, Positions: u.existingPositions()}) {
+			span.End(ctx.Err())
+			return false
+		}
+	} else if !s.emitChanges(pctx, u.diffPositions(res.Data.OtherPositionRetList)) {
+		span.End(ctx.Err())
+		return false
+	}
+
+	span.End(nil)
+
+	return s.emit(ctx, Heartbeat{s.meta()})
+}
+
+// checkSharing checks whether or not user is sharing their positions, if it's time to, and emits any change.
+// It returns false if the subscription should stop.
+func (s *subscription) checkSharing(ctx context.Context) bool {
+	u := s.u
+
+	if u.sharingCheck <= 0 || time.Since(s.lastSharing) < u.sharingCheck {
+		return true
+	}
+
+	res, err := u.GetOtherLeaderboardBaseInfo(ctx)
+	if ctx.Err() != nil {
+		return false
+	}
+
+	if err == nil && !res.Success {
+		err = fmt.Errorf("bad response message: %v", res.Message)
+	}
+
+	if err != nil {
+		// try again on the next poll
+		return s.emit(ctx, ErrorEvent{s.meta(), fmt.Errorf("failed to check sharing: %w", err)})
+	}
+
+	s.lastSharing = time.Now()
+	if res.Data.PositionShared == s.shared {
+		return true
+	}
+
+	s.shared = res.Data.PositionShared
+	if s.shared {
+		s.resync = true
+	}
+
+	return s.emit(ctx, SharingChanged{s.meta(), s.shared})
+}
+
+// emitChanges emits the changed positions. It returns false if the subscription should stop.
+//
+// Every position is emitted within its own span, which is a child of the span in ctx.
+func (s *subscription) emitChanges(ctx context.Context, changed []Position) bool {
+	u := s.u
+
+	for _, p := range changed {
+		_, span := u.tracer.Start(ctx, SpanPosition)
+		span.SetAttribute(AttrUID, u.UID)
+		span.SetAttribute(AttrTicker, p.Ticker)
+		span.SetAttribute(AttrPositionType, p.Type.String())
+
+		// propagate the trace context, so the subscriber can continue the trace
+		p.TraceParent = span.SpanContext().TraceParent()
+
+		u.observer.ObservePosition(u.UID, p)
+
+		if !s.emit(ctx, PositionChanged{s.meta(), p}) {
+			span.End(ctx.Err())
+			return false
+		}
+
+		span.End(nil)
+	}
+
+	return true
+}
+
+// randomJitter returns a random duration between 0 and user's jitter.
+func (u *User) randomJitter() time.Duration {
+	if u.jitter <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(u.jitter)))
 }
 
 // handlePositions parses raw positions, determines their type and sends the new ones through a channel.
-func (u *User) handlePositions(rps []rawPosition, cp chan<- Position, ce chan<- error) {
+//
+// Every position is sent within its own span, which is a child of the span in ctx.
+func (u *User) handlePositions(ctx context.Context, rps []rawPosition, cp chan<- Position, ce chan<- error) {
+	u.newSubscription(u.positionEmitter(cp, ce)).emitChanges(ctx, u.diffPositions(rps))
+}
+
+// existingPositions returns the positions user is currently in, sorted by ticker and marked as Existing.
+func (u *User) existingPositions() []Position {
+	ps := u.Positions()
+	for i := range ps {
+		ps[i].Type = Existing
+		ps[i].PrevAmount = 0
+	}
+
+	sort.Slice(ps, func(i, j int) bool {
+		return ps[i].Ticker < ps[j].Ticker
+	})
+
+	return ps
+}
+
+// resetPositions forgets all of user's positions, so the next fetch is handled as the first one.
+func (u *User) resetPositions() {
+	u.posMtx.Lock()
+	defer u.posMtx.Unlock()
+
+	u.positions = make(map[string]Position)
+	u.firstFetch = true
+}
+
+// diffPositions parses raw positions, determines their type, updates user's positions and returns the ones that changed.
+func (u *User) diffPositions(rps []rawPosition) []Position {
+	u.posMtx.Lock()
+	defer u.posMtx.Unlock()
+
+	var changed []Position
+
 	// used will be used for checking whether or not a position was already handled
 	// (thus if it's a new position or if it hasn't been present in the latest fetch and thus been closed)
 	used := make(map[string]struct{}, len(rps))
@@ -85,7 +363,7 @@ func (u *User) handlePositions(rps []rawPosition, cp chan<- Position, ce chan<-
 
 		// dont send the new position on first run (bc it's not really "new")
 		if !u.firstFetch {
-			cp <- p
+			changed = append(changed, p)
 		}
 
 		// add/update the old position to the current one
@@ -106,7 +384,7 @@ func (u *User) handlePositions(rps []rawPosition, cp chan<- Position, ce chan<-
 		p.PrevAmount = p.Amount
 		p.Amount = 0
 
-		cp <- p
+		changed = append(changed, p)
 
 		// remove the position from user's positions
 		delete(u.positions, h)
@@ -116,4 +394,8 @@ func (u *User) handlePositions(rps []rawPosition, cp chan<- Position, ce chan<-
 	if u.firstFetch {
 		u.firstFetch = false
 	}
+
+	u.observer.ObserveTracked(u.UID, len(u.positions))
+
+	return changed
 }
@@ -1,7 +1,12 @@
 package bfldb
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -95,12 +100,12 @@ func TestLogic(t *testing.T) {
 		ce := make(chan error)
 
 		// load in initial positions
-		u.handlePositions(tt.initPoss, cp, ce)
+		u.handlePositions(context.Background(), tt.initPoss, cp, ce)
 		t.Log("init positions:", u.positions)
 
 		go func() {
 			// handle positions
-			u.handlePositions(tt.rawPoss, cp, ce)
+			u.handlePositions(context.Background(), tt.rawPoss, cp, ce)
 		}()
 
 		ops, errs := chanToArrays(cp, ce)
@@ -122,3 +127,59 @@ func TestLogic(t *testing.T) {
 		require.EqualValues(t, tt.endPos, ep, "expected different end positions for test "+tt.msg)
 	}
 }
+
+func TestSubscribePositions_Cancel(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"success":true,"data":{"otherPositionRetList":[]}}`))
+	}))
+	defer api.Close()
+
+	u := NewUser("A", WithAPIBase(api.URL), WithCustomRefresh(time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	cp, ce := u.SubscribePositions(ctx)
+
+	// give the first poll a chance to happen, then cancel in the middle of the delay
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case _, ok := <-cp:
+		require.False(t, ok, "expected the positions channel to be closed")
+	case <-time.After(time.Second):
+		t.Fatal("subscription didn't stop after the context was cancelled")
+	}
+
+	_, ok := <-ce
+	require.False(t, ok, "expected the errors channel to be closed")
+}
+
+func TestSubscribePositions_UndrainedErrors(t *testing.T) {
+	for _, policy := range []UserOption{WithErrorPolicy(DropErrors), WithErrorBuffer(1)} {
+		var calls int32
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch atomic.AddInt32(&calls, 1) {
+			case 1:
+				w.Write([]byte(`{"success":true,"data":{"otherPositionRetList":[]}}`))
+			case 2, 3:
+				w.WriteHeader(http.StatusInternalServerError)
+			default:
+				w.Write([]byte(`{"success":true,"data":{"otherPositionRetList":[{"symbol":"BTCUSDT","amount":1}]}}`))
+			}
+		}))
+
+		u := NewUser("A", WithAPIBase(api.URL), WithCustomRefresh(time.Millisecond), policy)
+		ctx, cancel := context.WithCancel(context.Background())
+
+		// nobody's receiving errors, positions still have to come through
+		cp, _ := u.SubscribePositions(ctx)
+		select {
+		case p := <-cp:
+			require.Equal(t, "BTCUSDT", p.Ticker)
+		case <-time.After(time.Second):
+			t.Fatal("subscription got stuck on undrained errors")
+		}
+
+		cancel()
+		api.Close()
+	}
+}
@@ -0,0 +1,294 @@
+// Package metrics collects metrics about requests, polling and events of bfldb users
+// and exposes them in the Prometheus text format.
+//
+// Usage:
+//
+//	m := metrics.New()
+//	c := bfldb.NewClient(bfldb.WithClientObserver(m))
+//	http.Handle("/metrics", m)
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+)
+
+// DefaultBuckets are the default histogram buckets, in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// requestKey identifies a request counter.
+type requestKey struct {
+	endpoint string
+	status   string
+}
+
+// Metrics collects metrics of bfldb users. It implements bfldb.Observer and http.Handler.
+type Metrics struct {
+	mtx sync.Mutex // Synchronization for all of the metrics
+
+	requests  map[requestKey]uint64    // request counts by endpoint and status
+	durations map[string]*histogram    // request durations by endpoint
+	badStatus map[int]uint64           // BadStatusError counts by status code
+	pollLag   *histogram               // lag of polls behind the configured delay
+	lastLag   map[string]time.Duration // lag of the latest poll by UID
+	events    map[string]uint64        // events sent by position type
+	tracked   map[string]int           // currently tracked positions by UID
+}
+
+// New creates a new Metrics.
+func New() *Metrics {
+	return &Metrics{
+		requests:  make(map[requestKey]uint64),
+		durations: make(map[string]*histogram),
+		badStatus: make(map[int]uint64),
+		pollLag:   newHistogram(DefaultBuckets),
+		lastLag:   make(map[string]time.Duration),
+		events:    make(map[string]uint64),
+		tracked:   make(map[string]int),
+	}
+}
+
+var _ bfldb.Observer = (*Metrics)(nil)
+var _ http.Handler = (*Metrics)(nil)
+
+// ObserveRequest implements bfldb.Observer.
+func (m *Metrics) ObserveRequest(endpoint string, statusCode int, d time.Duration, err error) {
+	status := "error"
+	if statusCode != 0 {
+		status = strconv.Itoa(statusCode)
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.requests[requestKey{endpoint: endpoint, status: status}]++
+
+	h, ok := m.durations[endpoint]
+	if !ok {
+		h = newHistogram(DefaultBuckets)
+		m.durations[endpoint] = h
+	}
+	h.observe(d.Seconds())
+
+	if statusCode != 0 && statusCode != http.StatusOK {
+		m.badStatus[statusCode]++
+	}
+}
+
+// ObservePoll implements bfldb.Observer.
+func (m *Metrics) ObservePoll(UID string, interval, delay time.Duration) {
+	lag := interval - delay
+	if lag < 0 {
+		lag = 0
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.pollLag.observe(lag.Seconds())
+	m.lastLag[UID] = lag
+}
+
+// ObservePosition implements bfldb.Observer.
+func (m *Metrics) ObservePosition(UID string, p bfldb.Position) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.events[p.Type.String()]++
+}
+
+// ObserveTracked implements bfldb.Observer.
+func (m *Metrics) ObserveTracked(UID string, n int) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.tracked[UID] = n
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics to w in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	header(cw, "bfldb_requests_total", "counter", "Requests made to the leaderboard API by endpoint and status.")
+	rks := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		rks = append(rks, k)
+	}
+	sort.Slice(rks, func(i, j int) bool {
+		if rks[i].endpoint != rks[j].endpoint {
+			return rks[i].endpoint < rks[j].endpoint
+		}
+		return rks[i].status < rks[j].status
+	})
+	for _, k := range rks {
+		sample(cw, "bfldb_requests_total", labels("endpoint", k.endpoint, "status", k.status), float64(m.requests[k]))
+	}
+
+	header(cw, "bfldb_request_duration_seconds", "histogram", "Duration of requests made to the leaderboard API by endpoint.")
+	for _, e := range sortedKeys(m.durations) {
+		m.durations[e].write(cw, "bfldb_request_duration_seconds", "endpoint", e)
+	}
+
+	header(cw, "bfldb_bad_status_total", "counter", "Responses with an unexpected status code by code.")
+	codes := make([]int, 0, len(m.badStatus))
+	for c := range m.badStatus {
+		codes = append(codes, c)
+	}
+	sort.Ints(codes)
+	for _, c := range codes {
+		sample(cw, "bfldb_bad_status_total", labels("code", strconv.Itoa(c)), float64(m.badStatus[c]))
+	}
+
+	header(cw, "bfldb_poll_lag_seconds", "histogram", "How much later than the configured delay positions were polled.")
+	m.pollLag.write(cw, "bfldb_poll_lag_seconds")
+
+	header(cw, "bfldb_last_poll_lag_seconds", "gauge", "Lag of the latest poll behind the configured delay by UID.")
+	for _, uid := range sortedKeys(m.lastLag) {
+		sample(cw, "bfldb_last_poll_lag_seconds", labels("uid", uid), m.lastLag[uid].Seconds())
+	}
+
+	header(cw, "bfldb_events_total", "counter", "Position events sent to subscribers by position type.")
+	for _, t := range sortedKeys(m.events) {
+		sample(cw, "bfldb_events_total", labels("type", t), float64(m.events[t]))
+	}
+
+	header(cw, "bfldb_tracked_positions", "gauge", "Positions currently tracked by UID.")
+	for _, uid := range sortedKeys(m.tracked) {
+		sample(cw, "bfldb_tracked_positions", labels("uid", uid), float64(m.tracked[uid]))
+	}
+
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+
+	return cw.n, cw.w.Flush()
+}
+
+// histogram is a cumulative Prometheus histogram.
+type histogram struct {
+	buckets []float64 // upper bounds of the buckets
+	counts  []uint64  // counts of observations per bucket (not cumulative)
+	count   uint64    // total count of observations
+	sum     float64   // sum of all observations
+}
+
+// newHistogram creates a new histogram with the buckets passed in.
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+// observe records a single observation.
+func (h *histogram) observe(v float64) {
+	h.count++
+	h.sum += v
+
+	for i, b := range h.buckets {
+		if v <= b {
+			h.counts[i]++
+			return
+		}
+	}
+}
+
+// write writes the histogram samples, with the label pairs passed in.
+func (h *histogram) write(w io.Writer, name string, lvs ...string) {
+	var cum uint64
+	for i, b := range h.buckets {
+		cum += h.counts[i]
+		sample(w, name+"_bucket", labels(append(lvs, "le", formatFloat(b))...), float64(cum))
+	}
+	sample(w, name+"_bucket", labels(append(lvs, "le", "+Inf")...), float64(h.count))
+	sample(w, name+"_sum", labels(lvs...), h.sum)
+	sample(w, name+"_count", labels(lvs...), float64(h.count))
+}
+
+// header writes the HELP and TYPE lines of a metric.
+func header(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes a single sample line.
+func sample(w io.Writer, name, labels string, v float64) {
+	fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(v))
+}
+
+// labels formats label name and value pairs, e.g. labels("a", "b") returns {a="b"}.
+func labels(lvs ...string) string {
+	if len(lvs) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i := 0; i+1 < len(lvs); i += 2 {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(lvs[i])
+		sb.WriteString(`="`)
+		sb.WriteString(labelReplacer.Replace(lvs[i+1]))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+
+	return sb.String()
+}
+
+// labelReplacer escapes label values.
+var labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// formatFloat formats a sample value.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// sortedKeys returns the keys of the map passed in, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// countingWriter counts bytes written and remembers the first error.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+
+	return n, err
+}
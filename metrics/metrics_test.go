@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	m := New()
+
+	m.ObserveRequest("getOtherPosition", 200, 20*time.Millisecond, nil)
+	m.ObserveRequest("getOtherPosition", 429, 2*time.Second, bfldb.BadStatusError{StatusCode: 429})
+	m.ObserveRequest("searchNickname", 0, time.Second, errors.New("dial tcp: timeout"))
+	m.ObservePoll("A", 6*time.Second, 5*time.Second)
+	m.ObservePosition("A", bfldb.Position{Type: bfldb.Opened})
+	m.ObservePosition("A", bfldb.Position{Type: bfldb.Opened})
+	m.ObserveTracked("A", 3)
+
+	var sb strings.Builder
+	_, err := m.WriteTo(&sb)
+	require.NoError(t, err)
+	out := sb.String()
+
+	for _, line := range []string{
+		`# TYPE bfldb_requests_total counter`,
+		`bfldb_requests_total{endpoint="getOtherPosition",status="200"} 1`,
+		`bfldb_requests_total{endpoint="getOtherPosition",status="429"} 1`,
+		`bfldb_requests_total{endpoint="searchNickname",status="error"} 1`,
+		`bfldb_request_duration_seconds_bucket{endpoint="getOtherPosition",le="0.025"} 1`,
+		`bfldb_request_duration_seconds_bucket{endpoint="getOtherPosition",le="+Inf"} 2`,
+		`bfldb_request_duration_seconds_count{endpoint="getOtherPosition"} 2`,
+		`bfldb_bad_status_total{code="429"} 1`,
+		`bfldb_poll_lag_seconds_bucket{le="1"} 1`,
+		`bfldb_last_poll_lag_seconds{uid="A"} 1`,
+		`bfldb_events_total{type="opened"} 2`,
+		`bfldb_tracked_positions{uid="A"} 3`,
+	} {
+		require.Contains(t, out, line+"\n")
+	}
+}
+
+func TestLabels(t *testing.T) {
+	require.Equal(t, "", labels())
+	require.Equal(t, `{a="b",c="d\"\\\n"}`, labels("a", "b", "c", "d\"\\\n"))
+}
@@ -0,0 +1,49 @@
+package bfldb
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Call is a single call to the leaderboard API, as seen by middlewares.
+type Call struct {
+	Endpoint string            // Name of the endpoint called (e.g. getOtherPosition)
+	URL      string            // URL the request is sent to
+	UID      string            // UID of the user the call is made for, empty if it's not made for a specific user
+	Headers  map[string]string // Headers sent with the request
+	Request  any               // Typed request body (e.g. OtherPositionRequest), sent as JSON
+	Response any               // Pointer to the LdbAPIRes the response is decoded into (e.g. *LdbAPIRes[UserPositionData])
+}
+
+// CallFunc makes a call to the leaderboard API.
+type CallFunc func(ctx context.Context, c *Call) error
+
+// Middleware wraps a CallFunc. It can modify the call before passing it on to next
+// (e.g. sign or randomize the headers), inspect the decoded response after next returns,
+// or not call next at all (e.g. to serve a cached response, or to inject a fault).
+//
+// Middlewares only wrap the request itself, so the observer and tracer of a User or a Client
+// are only notified about requests that actually reach the API.
+type Middleware func(next CallFunc) CallFunc
+
+// chain wraps the CallFunc in the middlewares passed in, the first middleware being the outermost one.
+func chain(call CallFunc, mws []Middleware) CallFunc {
+	for i := len(mws) - 1; i >= 0; i-- {
+		call = mws[i](call)
+	}
+
+	return call
+}
+
+// LogCalls is a Middleware logging every call, its duration and error using the logger passed in.
+func LogCalls(l *log.Logger) Middleware {
+	return func(next CallFunc) CallFunc {
+		return func(ctx context.Context, c *Call) error {
+			start := time.Now()
+			err := next(ctx, c)
+			l.Printf("[%s] %s %+v took %s, error: %v\n", c.UID, c.Endpoint, c.Request, time.Since(start), err)
+			return err
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package bfldb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	var body map[string]string
+	var header string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		json.Unmarshal(b, &body)
+		header = r.Header.Get("x-signature")
+		w.Write([]byte(`{"success":true,"data":{"nickName":"Alpha"}}`))
+	}))
+	defer api.Close()
+
+	var order []string
+	record := func(name string) Middleware {
+		return func(next CallFunc) CallFunc {
+			return func(ctx context.Context, c *Call) error {
+				order = append(order, name)
+				return next(ctx, c)
+			}
+		}
+	}
+
+	sign := func(next CallFunc) CallFunc {
+		return func(ctx context.Context, c *Call) error {
+			c.Headers["x-signature"] = c.Endpoint + ":" + c.Request.(BaseInfoRequest).EncryptedUID
+			err := next(ctx, c)
+
+			// the response is already decoded
+			res := c.Response.(*LdbAPIRes[UserBaseInfo])
+			res.Data.Introduction = "seen by middleware"
+			return err
+		}
+	}
+
+	c := NewClient(WithClientAPIBase(api.URL), WithClientMiddleware(record("client")))
+	u := c.NewUser("A", WithMiddleware(record("user"), sign))
+
+	res, err := u.GetOtherLeaderboardBaseInfo(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"client", "user"}, order)
+	require.Equal(t, "A", body["encryptedUid"])
+	require.Equal(t, "getOtherLeaderboardBaseInfo:A", header)
+	require.Equal(t, "Alpha", res.Data.NickName)
+	require.Equal(t, "seen by middleware", res.Data.Introduction)
+}
+
+func TestMiddleware_ShortCircuit(t *testing.T) {
+	errInjected := errors.New("injected")
+	observed := 0
+
+	fault := func(next CallFunc) CallFunc {
+		return func(ctx context.Context, c *Call) error {
+			return errInjected
+		}
+	}
+
+	c := NewClient(WithClientAPIBase("http://127.0.0.1:0"), WithClientMiddleware(fault), WithClientObserver(countingObserver{&observed}))
+	_, err := c.SearchNickname(context.Background(), "Alpha")
+	require.ErrorIs(t, err, errInjected)
+
+	// the request never reached the API, so it's not observed
+	require.Equal(t, 0, observed)
+}
+
+// countingObserver counts the requests observed.
+type countingObserver struct {
+	n *int
+}
+
+func (o countingObserver) ObserveRequest(string, int, time.Duration, error) { *o.n++ }
+func (countingObserver) ObservePoll(string, time.Duration, time.Duration)   {}
+func (countingObserver) ObservePosition(string, Position)                   {}
+func (countingObserver) ObserveTracked(string, int)                         {}
@@ -0,0 +1,76 @@
+package bfldb
+
+import "time"
+
+// Observer gets notified about requests made, positions polled and events sent, e.g. to collect metrics.
+//
+// Methods are called synchronously, so they should return quickly.
+type Observer interface {
+	// ObserveRequest is called after every request to the API with the endpoint requested (e.g. getOtherPosition),
+	// the response status code (0 if no response was received), the request duration and any error that occured.
+	ObserveRequest(endpoint string, statusCode int, d time.Duration, err error)
+
+	// ObservePoll is called after every poll of user's positions with the time elapsed since the previous poll
+	// and the configured delay between polls.
+	ObservePoll(UID string, interval, delay time.Duration)
+
+	// ObservePosition is called for every position change sent to subscribers.
+	ObservePosition(UID string, p Position)
+
+	// ObserveTracked is called after every poll with the number of positions currently tracked for the user.
+	ObserveTracked(UID string, n int)
+}
+
+// nopObserver is an Observer that does nothing, used by default.
+type nopObserver struct{}
+
+func (nopObserver) ObserveRequest(string, int, time.Duration, error) {}
+func (nopObserver) ObservePoll(string, time.Duration, time.Duration) {}
+func (nopObserver) ObservePosition(string, Position)                 {}
+func (nopObserver) ObserveTracked(string, int)                       {}
+
+var _ Observer = nopObserver{}
+
+// multiObserver notifies multiple observers.
+type multiObserver []Observer
+
+// MultiObserver creates an Observer notifying all of the observers passed in, in order.
+func MultiObserver(obs ...Observer) Observer {
+	mo := make(multiObserver, 0, len(obs))
+	for _, o := range obs {
+		// flatten nested multi observers and drop the no-op ones
+		switch o := o.(type) {
+		case nopObserver:
+		case multiObserver:
+			mo = append(mo, o...)
+		default:
+			mo = append(mo, o)
+		}
+	}
+
+	return mo
+}
+
+func (mo multiObserver) ObserveRequest(endpoint string, statusCode int, d time.Duration, err error) {
+	for _, o := range mo {
+		o.ObserveRequest(endpoint, statusCode, d, err)
+	}
+}
+
+func (mo multiObserver) ObservePoll(UID string, interval, delay time.Duration) {
+	for _, o := range mo {
+		o.ObservePoll(UID, interval, delay)
+	}
+}
+
+func (mo multiObserver) ObservePosition(UID string, p Position) {
+	for _, o := range mo {
+		o.ObservePosition(UID, p)
+	}
+}
+
+func (mo multiObserver) ObserveTracked(UID string, n int) {
+	for _, o := range mo {
+		o.ObserveTracked(UID, n)
+	}
+}
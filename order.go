@@ -1,5 +1,7 @@
 package bfldb
 
+import "fmt"
+
 // TradeDirection can be either LONG / SHORT
 type TradeDirection int
 
@@ -15,6 +17,25 @@ func (pd TradeDirection) String() string {
 	return "LONG"
 }
 
+// MarshalText implements encoding.TextMarshaler.
+func (pd TradeDirection) MarshalText() ([]byte, error) {
+	return []byte(pd.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (pd *TradeDirection) UnmarshalText(b []byte) error {
+	switch string(b) {
+	default:
+		return fmt.Errorf("unknown trade direction %q", b)
+	case "SHORT":
+		*pd = Short
+	case "LONG":
+		*pd = Long
+	}
+
+	return nil
+}
+
 // Position represents an order to be used for placing a trade.
 type Order struct {
 	Direction  TradeDirection // Direction (e.g. LONG / SHORT)
@@ -22,4 +43,5 @@ type Order struct {
 	Amount     float64        // Amount
 	ReduceOnly bool           // Whether or not the order is reduce only
 	Leverage   int            // Leverage
+	Price      float64        // Reference price (the mark price of the position), used for sizing and risk checks
 }
@@ -2,6 +2,7 @@ package bfldb
 
 import (
 	"errors"
+	"fmt"
 	"math"
 )
 
@@ -17,6 +18,7 @@ const (
 	Closed                                  // A completely closed position
 	AddedTo                                 // A new position where there previously already was a position for the same direction and ticker + the amount increased
 	PartiallyClosed                         // A new position where there previously already was a position for the same direction and ticker + the amount decreased
+	Existing                                // A position that was already open when it was first seen, sent as a part of a Snapshot
 )
 
 func (pt PositionType) String() string {
@@ -31,21 +33,47 @@ func (pt PositionType) String() string {
 		return "added to"
 	case PartiallyClosed:
 		return "partially closed"
+	case Existing:
+		return "existing"
 	}
 }
 
+// MarshalText implements encoding.TextMarshaler.
+func (pt PositionType) MarshalText() ([]byte, error) {
+	return []byte(pt.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (pt *PositionType) UnmarshalText(b []byte) error {
+	for t := Opened; t <= Existing; t++ {
+		if t.String() == string(b) {
+			*pt = t
+			return nil
+		}
+	}
+
+	if len(b) == 0 {
+		*pt = 0
+		return nil
+	}
+
+	return fmt.Errorf("unknown position type %q", b)
+}
+
 // Position represents a position user is in.
 type Position struct {
-	Type       PositionType   // Type of the position
-	Direction  TradeDirection // Direction (e.g. LONG / SHORT)
-	Ticker     string         // Ticker of the position (e.g. BTCUSDT)
-	EntryPrice float64        // Entry price
-	MarkPrice  float64        // Entry price
-	Amount     float64        // Amount
-	PrevAmount float64        // previous amount, used for determining position type
-	Leverage   int            // Position leverage
-	Pnl        float64        // PNL
-	Roe        float64        // ROE
+	Type       PositionType   `json:"type"`       // Type of the position
+	Direction  TradeDirection `json:"direction"`  // Direction (e.g. LONG / SHORT)
+	Ticker     string         `json:"ticker"`     // Ticker of the position (e.g. BTCUSDT)
+	EntryPrice float64        `json:"entryPrice"` // Entry price
+	MarkPrice  float64        `json:"markPrice"`  // Entry price
+	Amount     float64        `json:"amount"`     // Amount
+	PrevAmount float64        `json:"prevAmount"` // previous amount, used for determining position type
+	Leverage   int            `json:"leverage"`   // Position leverage
+	Pnl        float64        `json:"pnl"`        // PNL
+	Roe        float64        `json:"roe"`        // ROE
+
+	TraceParent string `json:"traceParent,omitempty"` // W3C traceparent of the span the position was sent in, empty if not traced
 }
 
 // ToOrder converts a position into an Order.
@@ -57,6 +85,12 @@ func (p Position) ToOrder() Order {
 		Direction:  p.Direction,
 		Amount:     p.Amount,
 		Leverage:   p.Leverage,
+		Price:      p.MarkPrice,
+	}
+
+	// mark price isn't known yet
+	if o.Price == 0 {
+		o.Price = p.EntryPrice
 	}
 
 	if p.Type == Closed || p.Type == PartiallyClosed {
@@ -71,7 +105,7 @@ func (p Position) ToOrder() Order {
 		}
 	}
 
-	// opened = nothing changes
+	// opened / existing = nothing changes
 
 	if p.Type == Closed {
 		o.Amount = p.PrevAmount
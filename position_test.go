@@ -17,6 +17,21 @@ func TestToOrder(t *testing.T) {
 			p:    Position{Direction: Long, Amount: 1, PrevAmount: 0, Type: Opened},
 			want: Order{Direction: Long, Amount: 1, ReduceOnly: false},
 		},
+		{
+			name: "priced position",
+			p:    Position{Direction: Long, Amount: 1, Type: Opened, EntryPrice: 10, MarkPrice: 11},
+			want: Order{Direction: Long, Amount: 1, Price: 11},
+		},
+		{
+			name: "position without a mark price",
+			p:    Position{Direction: Long, Amount: 1, Type: Opened, EntryPrice: 10},
+			want: Order{Direction: Long, Amount: 1, Price: 10},
+		},
+		{
+			name: "existing position",
+			p:    Position{Direction: Short, Amount: 1, PrevAmount: 0, Type: Existing},
+			want: Order{Direction: Short, Amount: 1, ReduceOnly: false},
+		},
 		{
 			name: "closed position",
 			p:    Position{Direction: Long, Amount: 0, PrevAmount: 1, Type: Closed},
@@ -0,0 +1,189 @@
+package bfldb
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// NicknameChanged is sent by WatchProfile whenever user changes their nickname.
+type NicknameChanged struct {
+	EventMeta
+	Old string `json:"old"` // Previous nickname
+	New string `json:"new"` // Current nickname
+}
+
+// FollowerCountChanged is sent by WatchProfile whenever the number of user's followers changes.
+type FollowerCountChanged struct {
+	EventMeta
+	Old     int           `json:"old"`     // Previous follower count
+	New     int           `json:"new"`     // Current follower count
+	Elapsed time.Duration `json:"elapsed"` // Time since the previous follower count was seen
+}
+
+// Delta returns how many followers user gained, negative if they lost some.
+func (e FollowerCountChanged) Delta() int {
+	return e.New - e.Old
+}
+
+// PerHour returns the follower growth per hour since the previous follower count was seen.
+func (e FollowerCountChanged) PerHour() float64 {
+	if e.Elapsed <= 0 {
+		return 0
+	}
+
+	return float64(e.Delta()) / e.Elapsed.Hours()
+}
+
+// FollowingCountChanged is sent by WatchProfile whenever the number of people user follows changes.
+type FollowingCountChanged struct {
+	EventMeta
+	Old int `json:"old"` // Previous following count
+	New int `json:"new"` // Current following count
+}
+
+// SharingToggled is sent by WatchProfile whenever user starts or stops sharing their positions.
+type SharingToggled struct {
+	EventMeta
+	Delivery bool `json:"delivery"` // Whether it's sharing of COIN positions that changed, USD positions otherwise
+	Shared   bool `json:"shared"`   // Whether or not user is sharing the positions now
+}
+
+// TwitterChanged is sent by WatchProfile whenever user changes their Twitter URL.
+type TwitterChanged struct {
+	EventMeta
+	Old string `json:"old"` // Previous Twitter URL
+	New string `json:"new"` // Current Twitter URL
+}
+
+// IntroductionChanged is sent by WatchProfile whenever user changes their introduction.
+type IntroductionChanged struct {
+	EventMeta
+	Old string `json:"old"` // Previous introduction
+	New string `json:"new"` // Current introduction
+}
+
+// ProfileChanged is sent by WatchProfile after the typed changes above, whenever any field of user's
+// profile changed, including the ones without a typed change (e.g. the photo).
+type ProfileChanged struct {
+	EventMeta
+	Old UserBaseInfo `json:"old"` // Previous profile
+	New UserBaseInfo `json:"new"` // Current profile
+}
+
+var (
+	_ Event = NicknameChanged{}
+	_ Event = FollowerCountChanged{}
+	_ Event = FollowingCountChanged{}
+	_ Event = SharingToggled{}
+	_ Event = TwitterChanged{}
+	_ Event = IntroductionChanged{}
+	_ Event = ProfileChanged{}
+)
+
+// WatchProfile polls user's profile in a new goroutine every profile refresh (5 minutes by default,
+// see WithProfileRefresh) and sends changes to it through the channel. The first profile fetched is the baseline
+// the following ones are compared to, so it doesn't produce any changes.
+//
+// Errors are sent as ErrorEvent and the watching carries on. The channel is closed as soon as
+// the context is cancelled.
+func (u *User) WatchProfile(ctx context.Context) <-chan Event {
+	c := make(chan Event)
+
+	go func() {
+		defer close(c)
+
+		var seq uint64
+		meta := func() EventMeta {
+			seq++
+			return EventMeta{UID: u.UID, Seq: seq, Time: time.Now()}
+		}
+
+		emit := func(e Event) bool {
+			select {
+			case c <- e:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+
+		var prev UserBaseInfo
+		var prevTime time.Time
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+
+			res, err := u.GetOtherLeaderboardBaseInfo(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+
+			if err == nil && !res.Success {
+				err = fmt.Errorf("bad response message: %v", res.Message)
+			}
+
+			if err != nil {
+				if !emit(ErrorEvent{meta(), fmt.Errorf("failed to fetch profile: %w", err)}) {
+					return
+				}
+			} else {
+				now := time.Now()
+				if !prevTime.IsZero() {
+					for _, e := range diffProfile(meta, prev, res.Data, now.Sub(prevTime)) {
+						if !emit(e) {
+							return
+						}
+					}
+				}
+
+				prev, prevTime = res.Data, now
+			}
+
+			timer.Reset(u.profileRefresh)
+		}
+	}()
+
+	return c
+}
+
+// diffProfile returns the changes between two profiles, elapsed apart, creating their metadata with meta.
+func diffProfile(meta func() EventMeta, old, new UserBaseInfo, elapsed time.Duration) []Event {
+	var es []Event
+
+	if old.NickName != new.NickName {
+		es = append(es, NicknameChanged{meta(), old.NickName, new.NickName})
+	}
+	if old.FollowerCount != new.FollowerCount {
+		es = append(es, FollowerCountChanged{meta(), old.FollowerCount, new.FollowerCount, elapsed})
+	}
+	if old.FollowingCount != new.FollowingCount {
+		es = append(es, FollowingCountChanged{meta(), old.FollowingCount, new.FollowingCount})
+	}
+	if old.PositionShared != new.PositionShared {
+		es = append(es, SharingToggled{meta(), false, new.PositionShared})
+	}
+	if old.DeliveryPositionShared != new.DeliveryPositionShared {
+		es = append(es, SharingToggled{meta(), true, new.DeliveryPositionShared})
+	}
+	if old.TwitterURL != new.TwitterURL {
+		es = append(es, TwitterChanged{meta(), old.TwitterURL, new.TwitterURL})
+	}
+	if old.Introduction != new.Introduction {
+		es = append(es, IntroductionChanged{meta(), old.Introduction, new.Introduction})
+	}
+
+	// OpenID is of an unknown type, so it's left out
+	if len(es) > 0 || old.UserPhotoURL != new.UserPhotoURL || old.TwShared != new.TwShared || old.IsTwTrader != new.IsTwTrader {
+		es = append(es, ProfileChanged{meta(), old, new})
+	}
+
+	return es
+}
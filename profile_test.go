@@ -0,0 +1,61 @@
+package bfldb
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUser_WatchProfile(t *testing.T) {
+	profiles := []string{
+		`{"success":true,"data":{"nickName":"Alpha","followerCount":10,"positionShared":true}}`,
+		`{"success":true,"data":{"nickName":"Alpha","followerCount":10,"positionShared":true}}`,
+		`{"success":false,"message":"system busy"}`,
+		`{"success":true,"data":{"nickName":"Beta","followerCount":25,"positionShared":false,"userPhotoUrl":"x"}}`,
+		`{"success":true,"data":{"nickName":"Beta","followerCount":25,"positionShared":false,"userPhotoUrl":"y"}}`,
+	}
+
+	var calls int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(atomic.AddInt32(&calls, 1)) - 1
+		if i >= len(profiles) {
+			i = len(profiles) - 1
+		}
+		fmt.Fprint(w, profiles[i])
+	}))
+	defer api.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	u := NewUser("A", WithAPIBase(api.URL), WithProfileRefresh(time.Millisecond))
+	es := collect(t, u.WatchProfile(ctx), 6)
+
+	require.IsType(t, ErrorEvent{}, es[0])
+	require.Equal(t, NicknameChanged{es[1].Meta(), "Alpha", "Beta"}, es[1])
+	require.Equal(t, 15, es[2].(FollowerCountChanged).Delta())
+	require.Equal(t, SharingToggled{es[3].Meta(), false, false}, es[3])
+	require.Equal(t, "Beta", es[4].(ProfileChanged).New.NickName)
+
+	// changes without a typed event are still reported
+	require.Equal(t, "y", es[5].(ProfileChanged).New.UserPhotoURL)
+
+	for i, e := range es {
+		require.Equal(t, uint64(i+1), e.Meta().Seq)
+	}
+
+	cancel()
+}
+
+func TestFollowerCountChanged_PerHour(t *testing.T) {
+	e := FollowerCountChanged{Old: 100, New: 130, Elapsed: 30 * time.Minute}
+	require.Equal(t, 30, e.Delta())
+	require.Equal(t, 60.0, e.PerHour())
+	require.Equal(t, 0.0, FollowerCountChanged{Old: 1, New: 2}.PerHour())
+}
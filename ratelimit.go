@@ -0,0 +1,83 @@
+package bfldb
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter limits the rate of requests with a token bucket. A single RateLimiter can be shared
+// by any number of users, clients and batches, limiting all of their requests together.
+type RateLimiter struct {
+	mtx    sync.Mutex    // Synchronization for tokens and last
+	every  time.Duration // how often a token is added
+	burst  int           // maximum amount of tokens
+	tokens float64       // tokens currently available, negative if requests are waiting for them
+	last   time.Time     // when tokens were last updated
+}
+
+// NewRateLimiter creates a new RateLimiter allowing a single request every interval passed in,
+// with bursts of up to burst requests.
+func NewRateLimiter(every time.Duration, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &RateLimiter{
+		every:  every,
+		burst:  burst,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a request is allowed to be sent, or the context is cancelled.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l.every <= 0 {
+		return ctx.Err()
+	}
+
+	l.mtx.Lock()
+	now := time.Now()
+	l.tokens += float64(now.Sub(l.last)) / float64(l.every)
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+	l.last = now
+
+	// take the token right away, if it isn't available yet, wait until it is
+	l.tokens--
+	wait := time.Duration(-l.tokens * float64(l.every))
+	l.mtx.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// give the token back, nobody's going to use it
+		l.mtx.Lock()
+		l.tokens++
+		l.mtx.Unlock()
+
+		return ctx.Err()
+	}
+}
+
+// RateLimit is a Middleware waiting for the rate limiter passed in before every call.
+func RateLimit(l *RateLimiter) Middleware {
+	return func(next CallFunc) CallFunc {
+		return func(ctx context.Context, c *Call) error {
+			if err := l.Wait(ctx); err != nil {
+				return err
+			}
+			return next(ctx, c)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package bfldb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrNoMatch = errors.New("no user matches the nickname")
+)
+
+// Match is a user found by a nickname search, along with how well their nickname matches the one searched for.
+type Match struct {
+	NicknameDetails
+	Score float64 `json:"score"` // How well the nickname matches, 1 for an exact match, down to 0
+}
+
+// Resolver resolves nicknames to users, caching the search results.
+//
+// Matches are ranked by how well they match the nickname searched for, preferring exact matches,
+// followed by case-insensitive ones, nicknames starting with or containing the one searched for and
+// finally similar ones. Matches scoring the same are ranked by their follower count.
+type Resolver struct {
+	mtx   sync.Mutex                // Synchronization for cache
+	cache map[string]resolverResult // search results, mapped by the lowercased nickname searched for
+
+	client   *Client          // client used for searching
+	ttl      time.Duration    // how long search results are cached for
+	minScore float64          // minimum score of a match
+	now      func() time.Time // current time, replaceable for testing
+}
+
+// resolverResult is a single cached search result.
+type resolverResult struct {
+	Users   []NicknameDetails `json:"users"`   // users found
+	Fetched time.Time         `json:"fetched"` // when the users were searched for
+}
+
+type ResolverOption func(*Resolver)
+
+// NewResolver creates a new Resolver searching with the client passed in.
+//
+// If c is nil, a new Client with the default configuration is used. By default, results are cached for an hour
+// and matches scoring at least 0.25 are returned.
+func NewResolver(c *Client, opts ...ResolverOption) *Resolver {
+	if c == nil {
+		c = NewClient()
+	}
+
+	r := Resolver{
+		cache:    make(map[string]resolverResult),
+		client:   c,
+		ttl:      time.Hour,
+		minScore: 0.25,
+		now:      time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(&r)
+	}
+
+	return &r
+}
+
+// Search returns all users matching the nickname, ranked from the best match.
+func (r *Resolver) Search(ctx context.Context, nickname string) ([]Match, error) {
+	users, err := r.search(ctx, nickname)
+	if err != nil {
+		return nil, err
+	}
+
+	ms := make([]Match, 0, len(users))
+	for _, u := range users {
+		if s := matchScore(nickname, u.Nickname); s >= r.minScore {
+			ms = append(ms, Match{NicknameDetails: u, Score: s})
+		}
+	}
+
+	sort.SliceStable(ms, func(i, j int) bool {
+		if ms[i].Score != ms[j].Score {
+			return ms[i].Score > ms[j].Score
+		}
+		return ms[i].FollowerCount > ms[j].FollowerCount
+	})
+
+	return ms, nil
+}
+
+// Resolve returns the user best matching the nickname, or ErrNoMatch if there's none.
+func (r *Resolver) Resolve(ctx context.Context, nickname string) (NicknameDetails, error) {
+	ms, err := r.Search(ctx, nickname)
+	if err != nil {
+		return NicknameDetails{}, err
+	}
+
+	if len(ms) == 0 {
+		return NicknameDetails{}, ErrNoMatch
+	}
+
+	return ms[0].NicknameDetails, nil
+}
+
+// search returns the users found for the nickname, from the cache if they're cached and not expired.
+func (r *Resolver) search(ctx context.Context, nickname string) ([]NicknameDetails, error) {
+	key := strings.ToLower(nickname)
+
+	r.mtx.Lock()
+	res, ok := r.cache[key]
+	r.mtx.Unlock()
+
+	if ok && r.now().Sub(res.Fetched) < r.ttl {
+		return res.Users, nil
+	}
+
+	apiRes, err := r.client.SearchNickname(ctx, nickname)
+	if err == nil && !apiRes.Success {
+		err = fmt.Errorf("bad response message: %v", apiRes.Message)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to search nickname: %w", err)
+	}
+
+	r.mtx.Lock()
+	r.cache[key] = resolverResult{Users: apiRes.Data, Fetched: r.now()}
+	r.mtx.Unlock()
+
+	return apiRes.Data, nil
+}
+
+// Load loads search results previously saved with Save from the file at path.
+// Expired results are skipped. It's not an error if the file doesn't exist.
+func (r *Resolver) Load(path string) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read resolver cache: %w", err)
+	}
+
+	var cache map[string]resolverResult
+	if err := json.Unmarshal(b, &cache); err != nil {
+		return fmt.Errorf("failed to parse resolver cache: %w", err)
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	now := r.now()
+	for k, res := range cache {
+		if now.Sub(res.Fetched) < r.ttl {
+			r.cache[k] = res
+		}
+	}
+
+	return nil
+}
+
+// Save saves all search results that haven't expired yet to the file at path, replacing it.
+func (r *Resolver) Save(path string) error {
+	r.mtx.Lock()
+	now := r.now()
+	cache := make(map[string]resolverResult, len(r.cache))
+	for k, res := range r.cache {
+		if now.Sub(res.Fetched) < r.ttl {
+			cache[k] = res
+		}
+	}
+	r.mtx.Unlock()
+
+	b, err := json.Marshal(cache)
+	if err != nil {
+		return fmt.Errorf("failed to encode resolver cache: %w", err)
+	}
+
+	// write into a temporary file first, so the cache isn't corrupted if writing fails midway
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to save resolver cache: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to save resolver cache: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to save resolver cache: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to save resolver cache: %w", err)
+	}
+
+	return nil
+}
+
+// matchScore scores how well the nickname matches the query, from 1 for an exact match down to 0.
+func matchScore(query, nickname string) float64 {
+	if nickname == query {
+		return 1
+	}
+	if strings.EqualFold(nickname, query) {
+		return 0.9
+	}
+
+	q, n := strings.ToLower(query), strings.ToLower(nickname)
+
+	// similarity of the whole nicknames, between 0 and 1
+	longest := utf8.RuneCountInString(q)
+	if l := utf8.RuneCountInString(n); l > longest {
+		longest = l
+	}
+	sim := 1 - float64(levenshtein(q, n))/float64(longest)
+
+	switch {
+	case strings.HasPrefix(n, q):
+		return 0.6 + 0.2*sim
+	case strings.Contains(n, q):
+		return 0.4 + 0.2*sim
+	default:
+		return 0.5 * sim
+	}
+}
+
+// levenshtein returns the edit distance between two strings.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// min3 returns the smallest of three ints.
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// WithResolverTTL sets how long search results are cached for.
+func WithResolverTTL(d time.Duration) ResolverOption {
+	return func(r *Resolver) {
+		r.ttl = d
+	}
+}
+
+// WithMinScore sets the minimum score of matches returned, between 0 and 1.
+// A minimum score of 1 only returns exact matches.
+func WithMinScore(s float64) ResolverOption {
+	return func(r *Resolver) {
+		r.minScore = s
+	}
+}
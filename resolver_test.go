@@ -0,0 +1,86 @@
+package bfldb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolver(t *testing.T) {
+	var calls int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte(`{"success":true,"data":[
+			{"encryptedUid":"1","nickname":"AlphaTrader","followerCount":500},
+			{"encryptedUid":"2","nickname":"alpha","followerCount":10},
+			{"encryptedUid":"3","nickname":"Alpha","followerCount":5},
+			{"encryptedUid":"4","nickname":"TheAlphaOne","followerCount":9000},
+			{"encryptedUid":"5","nickname":"Alpah","followerCount":1},
+			{"encryptedUid":"6","nickname":"Beta","followerCount":1}
+		]}`))
+	}))
+	defer api.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	r := NewResolver(NewClient(WithClientAPIBase(api.URL)))
+	r.now = func() time.Time { return now }
+
+	ms, err := r.Search(ctx, "Alpha")
+	require.NoError(t, err)
+
+	uids := make([]string, 0, len(ms))
+	for _, m := range ms {
+		uids = append(uids, m.EncryptedUID)
+	}
+	require.Equal(t, []string{"3", "2", "1", "4", "5"}, uids)
+	require.Equal(t, 1.0, ms[0].Score)
+
+	u, err := r.Resolve(ctx, "Alpha")
+	require.NoError(t, err)
+	require.Equal(t, "3", u.EncryptedUID)
+
+	// cached results are case-insensitive
+	u, err = r.Resolve(ctx, "ALPHA")
+	require.NoError(t, err)
+	require.Equal(t, "2", u.EncryptedUID, "case-insensitive matches are ranked by their follower count")
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	_, err = NewResolver(NewClient(WithClientAPIBase(api.URL)), WithMinScore(1)).Resolve(ctx, "Gamma")
+	require.ErrorIs(t, err, ErrNoMatch)
+
+	// results expire
+	now = now.Add(2 * time.Hour)
+	_, err = r.Search(ctx, "Alpha")
+	require.NoError(t, err)
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// results survive a restart
+	path := filepath.Join(t.TempDir(), "resolver.json")
+	require.NoError(t, r.Save(path))
+
+	r = NewResolver(NewClient(WithClientAPIBase(api.URL)))
+	require.NoError(t, r.Load(path))
+	_, err = r.Search(ctx, "alpha")
+	require.NoError(t, err)
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	require.NoError(t, r.Load(filepath.Join(t.TempDir(), "missing.json")))
+}
+
+func TestMatchScore(t *testing.T) {
+	require.Equal(t, 1.0, matchScore("Alpha", "Alpha"))
+	require.Equal(t, 0.9, matchScore("Alpha", "ALPHA"))
+	require.Greater(t, matchScore("Alpha", "AlphaX"), matchScore("Alpha", "AlphaTrader"))
+	require.Greater(t, matchScore("Alpha", "AlphaTrader"), matchScore("Alpha", "TheAlpha"))
+	require.Greater(t, matchScore("Alpha", "TheAlpha"), matchScore("Alpha", "Alpah"))
+	require.Equal(t, 0.0, matchScore("abc", "xyz"))
+	require.Equal(t, 3, levenshtein("kitten", "sitting"))
+}
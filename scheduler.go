@@ -0,0 +1,230 @@
+package bfldb
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Scheduler adapts the delay between position refreshes of users based on their activity.
+//
+// Users who recently changed a position are polled at their minimum interval. Once they go quiet, their interval
+// doubles for every active window without a change, up to their maximum interval. Users holding open positions
+// are backed off less, up to the geometric mean of their minimum and maximum interval.
+//
+// If the resulting polling rate of all users exceeds the requests per minute budget, all intervals are stretched
+// proportionally to fit it. Maximum intervals take precedence over the budget, so once polling every user at their
+// maximum interval exceeds it, the budget is exceeded as well.
+//
+// Scheduler implements Observer, which is how it learns about users' activity, see WithScheduler.
+type Scheduler struct {
+	mtx    sync.Mutex                  // Synchronization for all fields
+	users  map[string]*scheduledUser   // tracked users, mapped by their UID
+	bounds map[string][2]time.Duration // minimum and maximum intervals of users, mapped by their UID
+
+	budget int           // requests per minute across all users, 0 means unlimited
+	min    time.Duration // default minimum interval
+	max    time.Duration // default maximum interval
+	window time.Duration // how long a user is considered active after a position change
+
+	now func() time.Time // current time, replaceable for testing
+}
+
+// scheduledUser is a single user tracked by a Scheduler.
+type scheduledUser struct {
+	user       *User     // the user itself
+	lastChange time.Time // when the user last changed a position
+	lastPoll   time.Time // when the user was last polled
+	open       int       // number of positions the user is in
+}
+
+type SchedulerOption func(*Scheduler)
+
+// NewScheduler creates a new Scheduler.
+//
+// By default, users are polled between every 2 seconds and every 2 minutes, stay active for 5 minutes
+// after a position change and the budget is unlimited.
+func NewScheduler(opts ...SchedulerOption) *Scheduler {
+	s := Scheduler{
+		users:  make(map[string]*scheduledUser),
+		bounds: make(map[string][2]time.Duration),
+		min:    2 * time.Second,
+		max:    2 * time.Minute,
+		window: 5 * time.Minute,
+		now:    time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	return &s
+}
+
+// Track starts adapting the delay of the user passed in.
+//
+// Users that haven't been polled for more than twice their maximum interval (e.g. they aren't subscribed to yet)
+// don't count towards the budget until they are polled again.
+func (s *Scheduler) Track(u *User) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	now := s.now()
+	s.users[u.UID] = &scheduledUser{user: u, lastChange: now, lastPoll: now}
+	s.rebalance()
+}
+
+// Untrack stops adapting the delay of the user with the UID passed in and forgets its intervals set with
+// SetIntervals. Users removed from a Watcher are untracked automatically.
+func (s *Scheduler) Untrack(UID string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	delete(s.users, UID)
+	delete(s.bounds, UID)
+	s.rebalance()
+}
+
+// SetIntervals sets the minimum and maximum interval of the user with the UID passed in,
+// overriding the default ones.
+func (s *Scheduler) SetIntervals(UID string, min, max time.Duration) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.bounds[UID] = [2]time.Duration{min, max}
+	s.rebalance()
+}
+
+// ObserveRequest implements Observer.
+func (s *Scheduler) ObserveRequest(string, int, time.Duration, error) {}
+
+// ObservePoll implements Observer.
+func (s *Scheduler) ObservePoll(UID string, _, _ time.Duration) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if su, ok := s.users[UID]; ok {
+		su.lastPoll = s.now()
+		s.rebalance()
+	}
+}
+
+// ObservePosition implements Observer.
+func (s *Scheduler) ObservePosition(UID string, _ Position) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if su, ok := s.users[UID]; ok {
+		su.lastChange = s.now()
+		s.rebalance()
+	}
+}
+
+// ObserveTracked implements Observer.
+func (s *Scheduler) ObserveTracked(UID string, n int) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if su, ok := s.users[UID]; ok {
+		su.open = n
+	}
+}
+
+var _ Observer = (*Scheduler)(nil)
+
+// intervals returns the minimum and maximum interval of the user with the UID passed in.
+func (s *Scheduler) intervals(UID string) (time.Duration, time.Duration) {
+	if b, ok := s.bounds[UID]; ok {
+		return b[0], b[1]
+	}
+
+	return s.min, s.max
+}
+
+// desired returns the delay the user should be polled with, disregarding the budget.
+func (s *Scheduler) desired(su *scheduledUser, now time.Time) time.Duration {
+	min, max := s.intervals(su.user.UID)
+
+	// users holding positions can close them at any time, so don't back off all the way
+	if su.open > 0 {
+		max = time.Duration(math.Sqrt(float64(min) * float64(max)))
+	}
+
+	d := min
+	if s.window > 0 {
+		// double the interval for every active window without a position change
+		for idle := now.Sub(su.lastChange); idle >= s.window && d < max; idle -= s.window {
+			d *= 2
+		}
+	}
+
+	if d > max {
+		d = max
+	}
+
+	return d
+}
+
+// rebalance recomputes and sets the delays of all tracked users. s.mtx has to be held by the caller.
+func (s *Scheduler) rebalance() {
+	now := s.now()
+	delays := make(map[*scheduledUser]time.Duration, len(s.users))
+
+	var rpm float64
+	for uid, su := range s.users {
+		_, max := s.intervals(uid)
+		if now.Sub(su.lastPoll) > 2*max {
+			// user is not being polled at the moment, so they don't use up any of the budget
+			continue
+		}
+
+		d := s.desired(su, now)
+		delays[su] = d
+		if d > 0 {
+			rpm += float64(time.Minute) / float64(d)
+		}
+	}
+
+	// stretch all intervals proportionally to fit into the budget
+	stretch := 1.0
+	if s.budget > 0 && rpm > float64(s.budget) {
+		stretch = rpm / float64(s.budget)
+	}
+
+	for su, d := range delays {
+		_, max := s.intervals(su.user.UID)
+
+		d = time.Duration(float64(d) * stretch)
+		if d > max {
+			d = max
+		}
+
+		if su.user.Delay() != d {
+			su.user.SetDelay(d)
+		}
+	}
+}
+
+// WithBudget sets the maximum requests per minute across all users.
+//
+// The budget is best effort, users are never polled less often than at their maximum interval to fit it.
+func WithBudget(rpm int) SchedulerOption {
+	return func(s *Scheduler) {
+		s.budget = rpm
+	}
+}
+
+// WithIntervals sets the default minimum and maximum interval between polls of a user.
+func WithIntervals(min, max time.Duration) SchedulerOption {
+	return func(s *Scheduler) {
+		s.min = min
+		s.max = max
+	}
+}
+
+// WithActiveWindow sets how long a user is considered active after a position change.
+func WithActiveWindow(d time.Duration) SchedulerOption {
+	return func(s *Scheduler) {
+		s.window = d
+	}
+}
@@ -0,0 +1,91 @@
+package bfldb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := NewScheduler(WithIntervals(time.Second, time.Minute), WithActiveWindow(time.Minute))
+	s.now = func() time.Time { return now }
+
+	active := NewUser("active", WithScheduler(s))
+	holding := NewUser("holding", WithScheduler(s))
+	idle := NewUser("idle", WithScheduler(s))
+
+	// freshly tracked users are polled at the minimum interval
+	require.Equal(t, time.Second, active.Delay())
+	require.Equal(t, time.Second, idle.Delay())
+
+	holding.observer.ObserveTracked(holding.UID, 1)
+	for i := 0; i < 10; i++ {
+		now = now.Add(time.Minute)
+		for _, u := range []*User{active, holding, idle} {
+			u.observer.ObservePoll(u.UID, time.Second, u.Delay())
+		}
+	}
+	active.observer.ObservePosition(active.UID, Position{Type: Opened})
+
+	require.Equal(t, time.Second, active.Delay())
+	// geometric mean of 1s and 1m
+	require.Equal(t, time.Duration(7745966692), holding.Delay())
+	require.Equal(t, time.Minute, idle.Delay())
+
+	// per user intervals override the default ones
+	s.SetIntervals(active.UID, 5*time.Second, time.Minute)
+	require.Equal(t, 5*time.Second, active.Delay())
+}
+
+func TestScheduler_Budget(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := NewScheduler(WithIntervals(time.Second, time.Minute), WithBudget(60))
+	s.now = func() time.Time { return now }
+
+	// 2 users at 1s would be 120 requests per minute, so they get stretched to 2s
+	a := NewUser("a", WithScheduler(s))
+	b := NewUser("b", WithScheduler(s))
+	require.Equal(t, 2*time.Second, a.Delay())
+	require.Equal(t, 2*time.Second, b.Delay())
+
+	// users that stopped being polled free up the budget
+	now = now.Add(90 * time.Second)
+	a.observer.ObservePoll(a.UID, time.Second, a.Delay())
+	now = now.Add(time.Minute)
+	a.observer.ObservePoll(a.UID, time.Second, a.Delay())
+	s.ObservePosition(a.UID, Position{})
+	require.Equal(t, time.Second, a.Delay())
+
+	// and use it up again once they are polled again, e.g. after they were subscribed to late
+	b.observer.ObservePoll(b.UID, time.Second, b.Delay())
+	require.Equal(t, 2*time.Second, a.Delay())
+	require.Equal(t, 2*time.Second, b.Delay())
+
+	// the maximum interval takes precedence over the budget
+	c := NewUser("c", WithScheduler(s))
+	s.SetIntervals(c.UID, time.Second, time.Second)
+	require.Equal(t, time.Second, c.Delay())
+}
+
+func TestScheduler_WatcherRemove(t *testing.T) {
+	s := NewScheduler(WithIntervals(time.Second, time.Minute), WithBudget(60))
+	w := NewWatcher(nil, WithScheduler(s))
+	require.NoError(t, w.Add("a"))
+	require.NoError(t, w.Add("b"))
+	s.SetIntervals("b", time.Second, 2*time.Minute)
+
+	a, _ := w.User("a")
+	require.Equal(t, 2*time.Second, a.Delay())
+
+	// removed users are untracked, so they don't take up the budget anymore
+	require.NoError(t, w.Remove("b"))
+	require.Equal(t, time.Second, a.Delay())
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	require.NotContains(t, s.users, "b")
+	require.NotContains(t, s.bounds, "b")
+	require.Contains(t, s.users, "a")
+}
@@ -0,0 +1,282 @@
+// Package server exposes users watched by a bfldb.Watcher over a REST API and a Server-Sent Events stream,
+// so that one poller can serve many consumers, regardless of the language they are written in.
+//
+// Endpoints:
+//
+//	GET    /traders                  lists UIDs of watched traders
+//	POST   /traders                  starts watching a trader, body: {"uid": "<uid>"}
+//	DELETE /traders/<uid>            stops watching a trader
+//	GET    /traders/<uid>/positions  current positions of a watched trader, sorted by ticker
+//	GET    /traders/<uid>/profile    leaderboard profile of any trader
+//	GET    /events                   Server-Sent Events stream of position changes and errors,
+//	                                 optionally filtered with one or more ?uid=<uid> query parameters
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+)
+
+// streamBuffer is the amount of events buffered for each stream before events start being dropped.
+const streamBuffer = 64
+
+// Event is a position change or an error of a watched trader, as sent to streams.
+type Event struct {
+	UID      string          `json:"uid"`                // Encrypted UID of the trader
+	Time     time.Time       `json:"time"`               // Time the event was received at
+	Position *bfldb.Position `json:"position,omitempty"` // Position change, nil if Error is set
+	Error    string          `json:"error,omitempty"`    // Error that occured during the subscription
+}
+
+// newEvent creates a new Event from a bfldb.WatchEvent.
+func newEvent(we bfldb.WatchEvent) Event {
+	e := Event{UID: we.UID, Time: we.Time}
+	if we.Err != nil {
+		e.Error = we.Err.Error()
+	} else {
+		p := we.Position
+		e.Position = &p
+	}
+
+	return e
+}
+
+// Server serves users watched by a bfldb.Watcher over HTTP.
+type Server struct {
+	watcher *bfldb.Watcher // watcher providing the positions
+	client  *bfldb.Client  // client used for profile requests
+	mux     *http.ServeMux // request router
+
+	mtx     sync.Mutex              // Synchronization for streams
+	streams map[chan Event]struct{} // currently connected event streams
+	done    chan struct{}           // closed once Run returns, ending all streams
+}
+
+// New creates a new Server serving the watcher passed in. Profiles are requested with the client passed in.
+//
+// If c is nil, a new Client with the default configuration is used.
+func New(w *bfldb.Watcher, c *bfldb.Client) *Server {
+	if c == nil {
+		c = bfldb.NewClient()
+	}
+
+	s := &Server{
+		watcher: w,
+		client:  c,
+		mux:     http.NewServeMux(),
+		streams: make(map[chan Event]struct{}),
+		done:    make(chan struct{}),
+	}
+
+	s.mux.HandleFunc("/traders", s.handleTraders)
+	s.mux.HandleFunc("/traders/", s.handleTrader)
+	s.mux.HandleFunc("/events", s.handleEvents)
+
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Run runs the watcher and broadcasts its events to all connected streams, until the context is cancelled.
+// All streams are ended once it returns, so that they don't hold up shutting down the HTTP server.
+//
+// Run should only be called once.
+func (s *Server) Run(ctx context.Context) error {
+	defer close(s.done)
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- s.watcher.Run(ctx)
+	}()
+
+	for we := range s.watcher.Events() {
+		s.broadcast(newEvent(we))
+	}
+
+	return <-errC
+}
+
+// broadcast sends the event to all connected streams, dropping it for streams that can't keep up.
+func (s *Server) broadcast(e Event) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for c := range s.streams {
+		select {
+		case c <- e:
+		default:
+		}
+	}
+}
+
+// handleTraders handles the /traders endpoint.
+func (s *Server) handleTraders(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.watcher.UIDs())
+
+	case http.MethodPost:
+		var body struct {
+			UID string `json:"uid"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("failed to decode body: %w", err))
+			return
+		}
+		if body.UID == "" {
+			writeError(w, http.StatusBadRequest, errors.New("missing uid"))
+			return
+		}
+
+		if err := s.watcher.Add(body.UID); err != nil {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, body)
+	}
+}
+
+// handleTrader handles the /traders/<uid>[/positions|/profile] endpoints.
+func (s *Server) handleTrader(w http.ResponseWriter, r *http.Request) {
+	uid, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/traders/"), "/")
+	if uid == "" {
+		writeError(w, http.StatusNotFound, errors.New("missing uid"))
+		return
+	}
+
+	switch {
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown endpoint %s", r.URL.Path))
+
+	case sub == "" && r.Method == http.MethodDelete:
+		if err := s.watcher.Remove(uid); err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	case sub == "positions" && r.Method == http.MethodGet:
+		u, ok := s.watcher.User(uid)
+		if !ok {
+			writeError(w, http.StatusNotFound, bfldb.ErrNotWatched)
+			return
+		}
+
+		ps := u.Positions()
+		sort.Slice(ps, func(i, j int) bool {
+			if ps[i].Ticker != ps[j].Ticker {
+				return ps[i].Ticker < ps[j].Ticker
+			}
+			return ps[i].Direction < ps[j].Direction
+		})
+
+		writeJSON(w, http.StatusOK, ps)
+
+	case sub == "profile" && r.Method == http.MethodGet:
+		res, err := s.client.NewUser(uid).GetOtherLeaderboardBaseInfo(r.Context())
+		if err != nil {
+			writeError(w, http.StatusBadGateway, fmt.Errorf("failed to get profile: %w", err))
+			return
+		}
+		if !res.Success {
+			writeError(w, http.StatusBadGateway, fmt.Errorf("failed to get profile, bad response message: %v", res.Message))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, res.Data)
+	}
+}
+
+// handleEvents handles the /events endpoint, streaming events as Server-Sent Events.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	f, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+
+	// only send events of these UIDs, if any were specified
+	filter := make(map[string]struct{})
+	for _, uid := range r.URL.Query()["uid"] {
+		filter[uid] = struct{}{}
+	}
+
+	c := make(chan Event, streamBuffer)
+	s.mtx.Lock()
+	s.streams[c] = struct{}{}
+	s.mtx.Unlock()
+
+	defer func() {
+		s.mtx.Lock()
+		delete(s.streams, c)
+		s.mtx.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	f.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-s.done:
+			return
+
+		case e := <-c:
+			if _, ok := filter[e.UID]; len(filter) != 0 && !ok {
+				continue
+			}
+
+			b, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+
+			name := "position"
+			if e.Error != "" {
+				name = "error"
+			}
+
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, b)
+			f.Flush()
+		}
+	}
+}
+
+// writeJSON writes v as the JSON response body.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes err as a JSON response body.
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeAPI creates a server mimicking Binance's leaderboard API.
+func newFakeAPI(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/getOtherLeaderboardBaseInfo"):
+			w.Write([]byte(`{"success":true,"code":"000000","data":{"nickName":"Alpha","followerCount":42}}`))
+		default:
+			w.Write([]byte(`{"success":true,"code":"000000","data":{"otherPositionRetList":[` +
+				`{"symbol":"SOLUSDT","amount":1},{"symbol":"BTCUSDT","amount":1},{"symbol":"ETHUSDT","amount":-1}]}}`))
+		}
+	}))
+	t.Cleanup(api.Close)
+
+	return api
+}
+
+func TestServer_Traders(t *testing.T) {
+	api := newFakeAPI(t)
+	c := bfldb.NewClient(bfldb.WithClientAPIBase(api.URL))
+	s := New(bfldb.NewWatcher(c), c)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+		resp   string
+	}{
+		{method: http.MethodGet, path: "/traders", status: http.StatusOK, resp: "[]"},
+		{method: http.MethodPost, path: "/traders", body: `{"uid":"A"}`, status: http.StatusCreated, resp: `{"uid":"A"}`},
+		{method: http.MethodPost, path: "/traders", body: `{"uid":"A"}`, status: http.StatusConflict},
+		{method: http.MethodPost, path: "/traders", body: `{}`, status: http.StatusBadRequest},
+		{method: http.MethodGet, path: "/traders", status: http.StatusOK, resp: `["A"]`},
+		{method: http.MethodGet, path: "/traders/A/positions", status: http.StatusOK, resp: "[]"},
+		{method: http.MethodGet, path: "/traders/B/positions", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/traders/B/profile", status: http.StatusOK, resp: `"followerCount":42`},
+		{method: http.MethodDelete, path: "/traders/A", status: http.StatusNoContent},
+		{method: http.MethodDelete, path: "/traders/A", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/traders", status: http.StatusOK, resp: "[]"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+
+		require.Equalf(t, tt.status, rec.Code, "%s %s: %s", tt.method, tt.path, rec.Body)
+		require.Containsf(t, rec.Body.String(), tt.resp, "%s %s", tt.method, tt.path)
+	}
+}
+
+func TestServer_Run(t *testing.T) {
+	api := newFakeAPI(t)
+	c := bfldb.NewClient(bfldb.WithClientAPIBase(api.URL))
+	s := New(bfldb.NewWatcher(c), c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+
+	hs := httptest.NewServer(s)
+	defer hs.Close()
+
+	res, err := http.Get(hs.URL + "/events")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+
+	// the stream ends once Run returns
+	ended := make(chan error)
+	go func() {
+		_, err := io.ReadAll(res.Body)
+		ended <- err
+	}()
+
+	select {
+	case err := <-ended:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("stream didn't end after Run returned")
+	}
+}
+
+func TestServer_Positions(t *testing.T) {
+	api := newFakeAPI(t)
+	c := bfldb.NewClient(bfldb.WithClientAPIBase(api.URL))
+	w := bfldb.NewWatcher(c, bfldb.WithCustomRefresh(10*time.Millisecond))
+	require.NoError(t, w.Add("A"))
+	s := New(w, c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	u, _ := w.User("A")
+	require.Eventually(t, u.Synced, time.Second, 5*time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/traders/A/positions", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var ps []bfldb.Position
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&ps))
+
+	var tickers []string
+	for _, p := range ps {
+		tickers = append(tickers, p.Ticker)
+	}
+	require.Equal(t, []string{"BTCUSDT", "ETHUSDT", "SOLUSDT"}, tickers)
+}
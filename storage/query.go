@@ -0,0 +1,300 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+)
+
+// Query filters the records returned by Events, Snapshots, Profiles and SharingChanges. Zero values don't filter anything.
+type Query struct {
+	UID    string               // Only records of the user with this UID
+	Ticker string               // Only positions of this ticker (e.g. SOLUSDT), ignored by SharingChanges
+	Types  []bfldb.PositionType // Only events of any of these types, ignored by Snapshots, Profiles and SharingChanges
+	From   time.Time            // Only records at or after this time
+	To     time.Time            // Only records before this time
+	Limit  int                  // Maximum number of records returned
+	Newest bool                 // Return the newest records first, instead of the oldest
+}
+
+// where builds the WHERE clause of the query, along with its arguments.
+// tickers determines whether or not records can be filtered by ticker, types whether or not by position type.
+func (q Query) where(tickers, types bool) (string, []any) {
+	var conds []string
+	var args []any
+
+	if q.UID != "" {
+		conds = append(conds, "uid = ?")
+		args = append(args, q.UID)
+	}
+
+	if tickers && q.Ticker != "" {
+		conds = append(conds, "ticker = ?")
+		args = append(args, q.Ticker)
+	}
+
+	if types && len(q.Types) > 0 {
+		conds = append(conds, "type IN (?"+strings.Repeat(", ?", len(q.Types)-1)+")")
+		for _, t := range q.Types {
+			args = append(args, t.String())
+		}
+	}
+
+	if !q.From.IsZero() {
+		conds = append(conds, "time >= ?")
+		args = append(args, q.From.UnixNano())
+	}
+
+	if !q.To.IsZero() {
+		conds = append(conds, "time < ?")
+		args = append(args, q.To.UnixNano())
+	}
+
+	if len(conds) == 0 {
+		return "", nil
+	}
+
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// order builds the ORDER BY and LIMIT clauses of the query.
+func (q Query) order() string {
+	s := " ORDER BY time, id"
+	if q.Newest {
+		s = " ORDER BY time DESC, id DESC"
+	}
+
+	if q.Limit > 0 {
+		s += fmt.Sprintf(" LIMIT %d", q.Limit)
+	}
+
+	return s
+}
+
+// Events returns saved position changes matching the query.
+func (s *Store) Events(ctx context.Context, q Query) ([]bfldb.PositionChanged, error) {
+	where, args := q.where(true, true)
+
+	rows, err := s.db.QueryContext(ctx, `SELECT
+		uid, seq, time, type, direction, ticker, entry_price, mark_price, amount, prev_amount, leverage, pnl, roe, trace_parent
+	FROM events`+where+q.order(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	var es []bfldb.PositionChanged
+	for rows.Next() {
+		var e bfldb.PositionChanged
+		var t int64
+		var typ, dir string
+
+		p := &e.Position
+		err := rows.Scan(&e.UID, &e.Seq, &t, &typ, &dir, &p.Ticker, &p.EntryPrice, &p.MarkPrice, &p.Amount, &p.PrevAmount,
+			&p.Leverage, &p.Pnl, &p.Roe, &p.TraceParent,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+
+		e.Time = time.Unix(0, t)
+		if err := p.Type.UnmarshalText([]byte(typ)); err != nil {
+			return nil, err
+		}
+		if err := p.Direction.UnmarshalText([]byte(dir)); err != nil {
+			return nil, err
+		}
+
+		es = append(es, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+
+	return es, nil
+}
+
+// LastEvent returns the latest saved position change matching the query, e.g. when a user last opened
+// a position of a ticker. Returns ErrNotFound if there's none.
+func (s *Store) LastEvent(ctx context.Context, q Query) (bfldb.PositionChanged, error) {
+	q.Newest = true
+	q.Limit = 1
+
+	es, err := s.Events(ctx, q)
+	if err != nil {
+		return bfldb.PositionChanged{}, err
+	}
+
+	if len(es) == 0 {
+		return bfldb.PositionChanged{}, ErrNotFound
+	}
+
+	return es[0], nil
+}
+
+// Snapshots returns saved snapshots matching the query. If the query has a ticker set,
+// snapshots only contain positions of that ticker.
+func (s *Store) Snapshots(ctx context.Context, q Query) ([]bfldb.Snapshot, error) {
+	where, args := q.where(false, false)
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id, uid, seq, time, initial FROM snapshots"+where+q.order(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	var snaps []bfldb.Snapshot
+	for rows.Next() {
+		var id, t int64
+		var snap bfldb.Snapshot
+		if err := rows.Scan(&id, &snap.UID, &snap.Seq, &t, &snap.Initial); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot: %w", err)
+		}
+
+		snap.Time = time.Unix(0, t)
+		ids = append(ids, id)
+		snaps = append(snaps, snap)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query snapshots: %w", err)
+	}
+	rows.Close()
+
+	for i, id := range ids {
+		ps, err := s.snapshotPositions(ctx, id, q.Ticker)
+		if err != nil {
+			return nil, err
+		}
+		snaps[i].Positions = ps
+	}
+
+	return snaps, nil
+}
+
+// snapshotPositions returns positions of the snapshot with the ID passed in, sorted by ticker.
+// If ticker isn't empty, only positions of the ticker are returned.
+func (s *Store) snapshotPositions(ctx context.Context, id int64, ticker string) ([]bfldb.Position, error) {
+	query := `SELECT direction, ticker, entry_price, mark_price, amount, leverage, pnl, roe
+	FROM snapshot_positions WHERE snapshot_id = ?`
+	args := []any{id}
+
+	if ticker != "" {
+		query += " AND ticker = ?"
+		args = append(args, ticker)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query+" ORDER BY ticker", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshot positions: %w", err)
+	}
+	defer rows.Close()
+
+	ps := []bfldb.Position{}
+	for rows.Next() {
+		p := bfldb.Position{Type: bfldb.Existing}
+		var dir string
+		if err := rows.Scan(&dir, &p.Ticker, &p.EntryPrice, &p.MarkPrice, &p.Amount, &p.Leverage, &p.Pnl, &p.Roe); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot position: %w", err)
+		}
+
+		if err := p.Direction.UnmarshalText([]byte(dir)); err != nil {
+			return nil, err
+		}
+
+		ps = append(ps, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query snapshot positions: %w", err)
+	}
+
+	return ps, nil
+}
+
+// SharingChanges returns saved sharing changes matching the query, e.g. to compute how consistently a user
+// shared their positions with analysis.ObserveStats.
+func (s *Store) SharingChanges(ctx context.Context, q Query) ([]bfldb.SharingChanged, error) {
+	where, args := q.where(false, false)
+
+	rows, err := s.db.QueryContext(ctx, "SELECT uid, seq, time, shared FROM sharing_changes"+where+q.order(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sharing changes: %w", err)
+	}
+	defer rows.Close()
+
+	var es []bfldb.SharingChanged
+	for rows.Next() {
+		var e bfldb.SharingChanged
+		var t int64
+		if err := rows.Scan(&e.UID, &e.Seq, &t, &e.Shared); err != nil {
+			return nil, fmt.Errorf("failed to scan sharing change: %w", err)
+		}
+
+		e.Time = time.Unix(0, t)
+		es = append(es, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query sharing changes: %w", err)
+	}
+
+	return es, nil
+}
+
+// Profiles returns saved profiles matching the query.
+func (s *Store) Profiles(ctx context.Context, q Query) ([]Profile, error) {
+	where, args := q.where(false, false)
+
+	rows, err := s.db.QueryContext(ctx, `SELECT
+		uid, time, nickname, photo_url, position_shared, delivery_position_shared, following_count, follower_count,
+		twitter_url, introduction, tw_shared, is_tw_trader
+	FROM profiles`+where+q.order(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query profiles: %w", err)
+	}
+	defer rows.Close()
+
+	var profiles []Profile
+	for rows.Next() {
+		var p Profile
+		var t int64
+
+		i := &p.Info
+		err := rows.Scan(&p.UID, &t, &i.NickName, &i.UserPhotoURL, &i.PositionShared, &i.DeliveryPositionShared,
+			&i.FollowingCount, &i.FollowerCount, &i.TwitterURL, &i.Introduction, &i.TwShared, &i.IsTwTrader,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan profile: %w", err)
+		}
+
+		p.Time = time.Unix(0, t)
+		profiles = append(profiles, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query profiles: %w", err)
+	}
+
+	return profiles, nil
+}
+
+// Profile returns the latest saved profile of the user with the UID passed in.
+// Returns ErrNotFound if there's none.
+func (s *Store) Profile(ctx context.Context, UID string) (Profile, error) {
+	profiles, err := s.Profiles(ctx, Query{UID: UID, Newest: true, Limit: 1})
+	if err != nil {
+		return Profile{}, err
+	}
+
+	if len(profiles) == 0 {
+		return Profile{}, ErrNotFound
+	}
+
+	return profiles[0], nil
+}
@@ -0,0 +1,327 @@
+// Package storage persists profiles, position snapshots, position events and sharing changes of bfldb users
+// into SQLite, so their history can be queried later.
+//
+// Usage:
+//
+//	s, err := storage.Open("bfldb.db")
+//	if err != nil {
+//		// ...
+//	}
+//	defer s.Close()
+//
+//	err = s.Record(ctx, u.Subscribe(ctx))
+//
+// Opening a database with Open requires cgo, since it uses github.com/mattn/go-sqlite3.
+// Any other SQLite driver can be used by passing the database to New instead.
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var (
+	ErrNotFound = errors.New("no record found")
+)
+
+// migrations are the schema migrations, applied in order. Applied migrations are tracked with PRAGMA user_version,
+// so migrations must never be changed or reordered once released, only appended.
+var migrations = []string{
+	`CREATE TABLE profiles (
+		id                       INTEGER PRIMARY KEY,
+		uid                      TEXT    NOT NULL,
+		time                     INTEGER NOT NULL,
+		nickname                 TEXT    NOT NULL,
+		photo_url                TEXT    NOT NULL,
+		position_shared          INTEGER NOT NULL,
+		delivery_position_shared INTEGER NOT NULL,
+		following_count          INTEGER NOT NULL,
+		follower_count           INTEGER NOT NULL,
+		twitter_url              TEXT    NOT NULL,
+		introduction             TEXT    NOT NULL,
+		tw_shared                INTEGER NOT NULL,
+		is_tw_trader             INTEGER NOT NULL
+	);
+	CREATE INDEX profiles_uid_time ON profiles (uid, time);
+
+	CREATE TABLE snapshots (
+		id      INTEGER PRIMARY KEY,
+		uid     TEXT    NOT NULL,
+		seq     INTEGER NOT NULL,
+		time    INTEGER NOT NULL,
+		initial INTEGER NOT NULL
+	);
+	CREATE INDEX snapshots_uid_time ON snapshots (uid, time);
+
+	CREATE TABLE snapshot_positions (
+		snapshot_id INTEGER NOT NULL REFERENCES snapshots (id),
+		direction   TEXT    NOT NULL,
+		ticker      TEXT    NOT NULL,
+		entry_price REAL    NOT NULL,
+		mark_price  REAL    NOT NULL,
+		amount      REAL    NOT NULL,
+		leverage    INTEGER NOT NULL,
+		pnl         REAL    NOT NULL,
+		roe         REAL    NOT NULL
+	);
+	CREATE INDEX snapshot_positions_snapshot_id ON snapshot_positions (snapshot_id);
+
+	CREATE TABLE events (
+		id           INTEGER PRIMARY KEY,
+		uid          TEXT    NOT NULL,
+		seq          INTEGER NOT NULL,
+		time         INTEGER NOT NULL,
+		type         TEXT    NOT NULL,
+		direction    TEXT    NOT NULL,
+		ticker       TEXT    NOT NULL,
+		entry_price  REAL    NOT NULL,
+		mark_price   REAL    NOT NULL,
+		amount       REAL    NOT NULL,
+		prev_amount  REAL    NOT NULL,
+		leverage     INTEGER NOT NULL,
+		pnl          REAL    NOT NULL,
+		roe          REAL    NOT NULL,
+		trace_parent TEXT    NOT NULL
+	);
+	CREATE INDEX events_uid_time ON events (uid, time);
+	CREATE INDEX events_ticker_time ON events (ticker, time);`,
+
+	`CREATE TABLE sharing_changes (
+		id     INTEGER PRIMARY KEY,
+		uid    TEXT    NOT NULL,
+		seq    INTEGER NOT NULL,
+		time   INTEGER NOT NULL,
+		shared INTEGER NOT NULL
+	);
+	CREATE INDEX sharing_changes_uid_time ON sharing_changes (uid, time);`,
+}
+
+// Profile is a profile of a user at a point in time.
+type Profile struct {
+	UID  string             `json:"uid"`  // Encrypted UID of the user
+	Time time.Time          `json:"time"` // When the profile was fetched
+	Info bfldb.UserBaseInfo `json:"info"` // The profile itself
+}
+
+// Store stores profiles, position snapshots, position events and sharing changes in a SQLite database.
+type Store struct {
+	db *sql.DB // the database
+}
+
+// Open opens the SQLite database at path, creating it if it doesn't exist, and migrates it to the latest schema.
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// SQLite doesn't handle concurrent writers, in-memory databases are also private to their connection
+	db.SetMaxOpenConns(1)
+
+	s, err := New(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// New creates a new Store from an already opened SQLite database and migrates it to the latest schema.
+func New(db *sql.DB) (*Store, error) {
+	s := Store{db: db}
+	if err := s.migrate(); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// DB returns the underlying database, e.g. for custom queries.
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// migrate applies all migrations that haven't been applied yet.
+func (s *Store) migrate() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		err := s.tx(context.Background(), func(tx *sql.Tx) error {
+			if _, err := tx.Exec(migrations[i]); err != nil {
+				return err
+			}
+
+			// PRAGMA doesn't support placeholders
+			_, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// tx runs fn within a transaction, which is committed if fn succeeds and rolled back otherwise.
+func (s *Store) tx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SaveProfile saves the profile of the user with the UID passed in, as fetched at t.
+func (s *Store) SaveProfile(ctx context.Context, UID string, t time.Time, info bfldb.UserBaseInfo) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO profiles (
+		uid, time, nickname, photo_url, position_shared, delivery_position_shared, following_count, follower_count,
+		twitter_url, introduction, tw_shared, is_tw_trader
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		UID, t.UnixNano(), info.NickName, info.UserPhotoURL, info.PositionShared, info.DeliveryPositionShared,
+		info.FollowingCount, info.FollowerCount, info.TwitterURL, info.Introduction, info.TwShared, info.IsTwTrader,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save profile: %w", err)
+	}
+
+	return nil
+}
+
+// SaveSnapshot saves raw positions of the user with the UID passed in, as fetched at t.
+func (s *Store) SaveSnapshot(ctx context.Context, UID string, t time.Time, data bfldb.UserPositionData) error {
+	return s.saveSnapshot(ctx, bfldb.Snapshot{
+		EventMeta: bfldb.EventMeta{UID: UID, Time: t},
+		Positions: data.Positions(),
+	})
+}
+
+// Save saves a single event of a subscription. PositionChanged events are saved as events, Snapshot events
+// as snapshots and SharingChanged events as sharing changes, other events are ignored.
+func (s *Store) Save(ctx context.Context, e bfldb.Event) error {
+	switch e := e.(type) {
+	case bfldb.PositionChanged:
+		return s.saveEvent(ctx, e)
+	case bfldb.Snapshot:
+		return s.saveSnapshot(ctx, e)
+	case bfldb.SharingChanged:
+		return s.saveSharingChange(ctx, e)
+	}
+
+	return nil
+}
+
+// Record saves all events received through the channel, until it's closed. Use it along with User.Subscribe
+// to persist a subscription.
+//
+// Events failing to save don't stop the recording, so the subscription is never blocked. Once the channel
+// is closed, the first error occurred is returned.
+func (s *Store) Record(ctx context.Context, events <-chan bfldb.Event) error {
+	var err error
+	for e := range events {
+		if serr := s.Save(ctx, e); serr != nil && err == nil {
+			err = serr
+		}
+	}
+
+	return err
+}
+
+// Attach registers handlers on the user, saving all of its position changes and snapshots once it's Run.
+//
+// Position handlers don't receive event metadata, so position changes are saved with the current time
+// and a sequence number of 0.
+func (s *Store) Attach(u *bfldb.User) {
+	u.OnPosition(func(ctx context.Context, p bfldb.Position) error {
+		return s.saveEvent(ctx, bfldb.PositionChanged{EventMeta: bfldb.EventMeta{UID: u.UID, Time: time.Now()}, Position: p})
+	})
+	u.OnSnapshot(func(ctx context.Context, snap bfldb.Snapshot) error {
+		return s.saveSnapshot(ctx, snap)
+	})
+}
+
+// saveEvent saves a single position change.
+func (s *Store) saveEvent(ctx context.Context, e bfldb.PositionChanged) error {
+	p := e.Position
+
+	_, err := s.db.ExecContext(ctx, `INSERT INTO events (
+		uid, seq, time, type, direction, ticker, entry_price, mark_price, amount, prev_amount, leverage, pnl, roe, trace_parent
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.UID, e.Seq, e.Time.UnixNano(), p.Type.String(), p.Direction.String(), p.Ticker, p.EntryPrice, p.MarkPrice,
+		p.Amount, p.PrevAmount, p.Leverage, p.Pnl, p.Roe, p.TraceParent,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save event: %w", err)
+	}
+
+	return nil
+}
+
+// saveSharingChange saves a single sharing change.
+func (s *Store) saveSharingChange(ctx context.Context, e bfldb.SharingChanged) error {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO sharing_changes (uid, seq, time, shared) VALUES (?, ?, ?, ?)",
+		e.UID, e.Seq, e.Time.UnixNano(), e.Shared,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save sharing change: %w", err)
+	}
+
+	return nil
+}
+
+// saveSnapshot saves a snapshot along with all of its positions.
+func (s *Store) saveSnapshot(ctx context.Context, snap bfldb.Snapshot) error {
+	err := s.tx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "INSERT INTO snapshots (uid, seq, time, initial) VALUES (?, ?, ?, ?)",
+			snap.UID, snap.Seq, snap.Time.UnixNano(), snap.Initial,
+		)
+		if err != nil {
+			return err
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		for _, p := range snap.Positions {
+			_, err := tx.ExecContext(ctx, `INSERT INTO snapshot_positions (
+				snapshot_id, direction, ticker, entry_price, mark_price, amount, leverage, pnl, roe
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				id, p.Direction.String(), p.Ticker, p.EntryPrice, p.MarkPrice, p.Amount, p.Leverage, p.Pnl, p.Roe,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rtunazzz/bfldb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Events(t *testing.T) {
+	ctx := context.Background()
+
+	s, err := Open(":memory:")
+	require.NoError(t, err)
+	defer s.Close()
+
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := []bfldb.PositionChanged{
+		{EventMeta: bfldb.EventMeta{UID: "A", Seq: 1, Time: start}, Position: bfldb.Position{Type: bfldb.Opened, Direction: bfldb.Long, Ticker: "SOLUSDT", Amount: 10, Leverage: 5}},
+		{EventMeta: bfldb.EventMeta{UID: "A", Seq: 2, Time: start.Add(time.Hour)}, Position: bfldb.Position{Type: bfldb.Closed, Direction: bfldb.Long, Ticker: "SOLUSDT", PrevAmount: 10}},
+		{EventMeta: bfldb.EventMeta{UID: "A", Seq: 3, Time: start.Add(2 * time.Hour)}, Position: bfldb.Position{Type: bfldb.Opened, Direction: bfldb.Short, Ticker: "SOLUSDT", Amount: 3, EntryPrice: 21.5}},
+		{EventMeta: bfldb.EventMeta{UID: "B", Seq: 1, Time: start.Add(3 * time.Hour)}, Position: bfldb.Position{Type: bfldb.Opened, Direction: bfldb.Long, Ticker: "BTCUSDT", Amount: 1}},
+	}
+
+	for _, e := range events {
+		require.NoError(t, s.Save(ctx, e))
+	}
+
+	// other events are ignored
+	require.NoError(t, s.Save(ctx, bfldb.Heartbeat{EventMeta: bfldb.EventMeta{UID: "A"}}))
+
+	tests := []struct {
+		name string
+		q    Query
+		want []bfldb.PositionChanged
+	}{
+		{name: "all", q: Query{}, want: events},
+		{name: "uid", q: Query{UID: "B"}, want: events[3:]},
+		{name: "ticker", q: Query{Ticker: "SOLUSDT"}, want: events[:3]},
+		{name: "types", q: Query{Types: []bfldb.PositionType{bfldb.Closed, bfldb.AddedTo}}, want: events[1:2]},
+		{name: "time range", q: Query{From: start.Add(time.Hour), To: start.Add(3 * time.Hour)}, want: events[1:3]},
+		{name: "newest", q: Query{UID: "A", Newest: true, Limit: 2}, want: []bfldb.PositionChanged{events[2], events[1]}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Events(ctx, tt.q)
+			require.NoError(t, err)
+			require.Len(t, got, len(tt.want))
+
+			for i := range got {
+				require.True(t, tt.want[i].Time.Equal(got[i].Time))
+				got[i].Time = tt.want[i].Time
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	e, err := s.LastEvent(ctx, Query{UID: "A", Ticker: "SOLUSDT", Types: []bfldb.PositionType{bfldb.Opened}})
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), e.Seq)
+
+	_, err = s.LastEvent(ctx, Query{UID: "C"})
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestStore_Snapshots(t *testing.T) {
+	ctx := context.Background()
+
+	s, err := Open(":memory:")
+	require.NoError(t, err)
+	defer s.Close()
+
+	var data bfldb.UserPositionData
+	require.NoError(t, json.Unmarshal([]byte(`{"otherPositionRetList":[{"symbol":"SOLUSDT","amount":-5,"leverage":3}]}`), &data))
+	require.NoError(t, s.SaveSnapshot(ctx, "A", time.Now(), data))
+
+	snap := bfldb.Snapshot{
+		EventMeta: bfldb.EventMeta{UID: "A", Seq: 1, Time: time.Now()},
+		Initial:   true,
+		Positions: []bfldb.Position{
+			{Type: bfldb.Existing, Direction: bfldb.Long, Ticker: "BTCUSDT", Amount: 1, Leverage: 10},
+			{Type: bfldb.Existing, Direction: bfldb.Short, Ticker: "ETHUSDT", Amount: 2, Leverage: 20},
+		},
+	}
+	require.NoError(t, s.Save(ctx, snap))
+
+	got, err := s.Snapshots(ctx, Query{UID: "A"})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, []bfldb.Position{{Type: bfldb.Existing, Direction: bfldb.Short, Ticker: "SOLUSDT", Amount: 5, Leverage: 3}}, got[0].Positions)
+	require.True(t, got[1].Initial)
+	require.Equal(t, snap.Positions, got[1].Positions)
+
+	got, err = s.Snapshots(ctx, Query{Ticker: "ETHUSDT", Newest: true, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, snap.Positions[1:], got[0].Positions)
+}
+
+func TestStore_SharingChanges(t *testing.T) {
+	ctx := context.Background()
+
+	s, err := Open(":memory:")
+	require.NoError(t, err)
+	defer s.Close()
+
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	changes := []bfldb.SharingChanged{
+		{EventMeta: bfldb.EventMeta{UID: "A", Seq: 1, Time: start}, Shared: false},
+		{EventMeta: bfldb.EventMeta{UID: "B", Seq: 1, Time: start.Add(time.Hour)}, Shared: false},
+		{EventMeta: bfldb.EventMeta{UID: "A", Seq: 2, Time: start.Add(2 * time.Hour)}, Shared: true},
+	}
+	for _, e := range changes {
+		require.NoError(t, s.Save(ctx, e))
+	}
+
+	got, err := s.SharingChanges(ctx, Query{UID: "A"})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	for i, e := range []bfldb.SharingChanged{changes[0], changes[2]} {
+		require.True(t, e.Time.Equal(got[i].Time))
+		got[i].Time = e.Time
+		require.Equal(t, e, got[i])
+	}
+
+	got, err = s.SharingChanges(ctx, Query{From: start.Add(time.Hour), Newest: true})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, "A", got[0].UID)
+	require.True(t, got[0].Shared)
+}
+
+func TestStore_Record(t *testing.T) {
+	ctx := context.Background()
+
+	s, err := Open(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	events := make(chan bfldb.Event)
+	go func() {
+		defer close(events)
+
+		// the store keeps receiving events after it failed to save one
+		for i := 0; i < 3; i++ {
+			events <- bfldb.PositionChanged{EventMeta: bfldb.EventMeta{UID: "A", Seq: uint64(i)}}
+		}
+	}()
+
+	done := make(chan error)
+	go func() {
+		done <- s.Record(ctx, events)
+	}()
+
+	select {
+	case err := <-done:
+		require.ErrorContains(t, err, "failed to save event")
+	case <-time.After(time.Second):
+		t.Fatal("recording stopped receiving events")
+	}
+
+	// all events were received
+	_, ok := <-events
+	require.False(t, ok)
+}
+
+func TestStore_Profiles(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "bfldb.db")
+
+	s, err := Open(path)
+	require.NoError(t, err)
+
+	_, err = s.Profile(ctx, "A")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, s.SaveProfile(ctx, "A", time.Now().Add(-time.Hour), bfldb.UserBaseInfo{NickName: "Alpha", FollowerCount: 1}))
+	require.NoError(t, s.SaveProfile(ctx, "A", time.Now(), bfldb.UserBaseInfo{NickName: "Alpha", FollowerCount: 2, PositionShared: true}))
+	require.NoError(t, s.Close())
+
+	// reopening an already migrated database
+	s, err = Open(path)
+	require.NoError(t, err)
+	defer s.Close()
+
+	p, err := s.Profile(ctx, "A")
+	require.NoError(t, err)
+	require.Equal(t, 2, p.Info.FollowerCount)
+	require.True(t, p.Info.PositionShared)
+
+	ps, err := s.Profiles(ctx, Query{UID: "A"})
+	require.NoError(t, err)
+	require.Len(t, ps, 2)
+}
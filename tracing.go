@@ -0,0 +1,122 @@
+package bfldb
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidTraceParent = errors.New("invalid traceparent")
+)
+
+// Names of the spans started by a User or a Client.
+const (
+	SpanRequest  = "bfldb.request"  // A single request to the API
+	SpanPoll     = "bfldb.poll"     // A single poll of user's positions, parent of the request and its position spans
+	SpanPosition = "bfldb.position" // Sending of a single position change to the subscriber
+)
+
+// Attribute keys set on spans.
+const (
+	AttrUID          = "bfldb.uid"           // Encrypted UID of the user
+	AttrEndpoint     = "bfldb.endpoint"      // API endpoint (e.g. getOtherPosition)
+	AttrStatusCode   = "http.status_code"    // Response status code
+	AttrTicker       = "bfldb.ticker"        // Ticker of the position
+	AttrPositionType = "bfldb.position_type" // Type of the position
+)
+
+// SpanContext identifies a span within a trace, in the same way as the W3C Trace Context does.
+type SpanContext struct {
+	TraceID [16]byte // ID of the whole trace
+	SpanID  [8]byte  // ID of the span
+	Sampled bool     // Whether or not the trace is sampled
+}
+
+// IsValid returns whether or not both the trace and span IDs are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// TraceParent formats the span context as a W3C traceparent header value.
+// Returns an empty string if the span context is not valid.
+func (sc SpanContext) TraceParent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), flags)
+}
+
+// ParseTraceParent parses a W3C traceparent header value, such as the one set on Position.TraceParent.
+func ParseTraceParent(s string) (SpanContext, error) {
+	var sc SpanContext
+
+	parts := strings.Split(s, "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, ErrInvalidTraceParent
+	}
+
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, ErrInvalidTraceParent
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, ErrInvalidTraceParent
+	}
+
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, ErrInvalidTraceParent
+	}
+	sc.Sampled = flags[0]&1 == 1
+
+	if !sc.IsValid() {
+		return sc, ErrInvalidTraceParent
+	}
+
+	return sc, nil
+}
+
+// Span is a single traced operation.
+type Span interface {
+	// SpanContext returns the span context of the span.
+	SpanContext() SpanContext
+
+	// SetAttribute sets an attribute on the span.
+	SetAttribute(key string, value any)
+
+	// End ends the span, err is the error the operation ended with, if any.
+	End(err error)
+}
+
+// Tracer starts spans around requests to the API and position changes sent to subscribers.
+//
+// It's modeled after OpenTelemetry's tracer, so it can be implemented by a thin adapter around one.
+type Tracer interface {
+	// Start starts a new span, which is a child of the span in ctx, if there's any.
+	// The returned context has to carry the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// nopTracer is a Tracer that does nothing, used by default.
+type nopTracer struct{}
+
+func (nopTracer) Start(ctx context.Context, _ string) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+// nopSpan is a Span that does nothing.
+type nopSpan struct{}
+
+func (nopSpan) SpanContext() SpanContext { return SpanContext{} }
+func (nopSpan) SetAttribute(string, any) {}
+func (nopSpan) End(error)                {}
+
+var _ Tracer = nopTracer{}
@@ -0,0 +1,116 @@
+package bfldb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordedSpan is a span recorded by recordingTracer.
+type recordedSpan struct {
+	name   string
+	parent SpanContext
+	sc     SpanContext
+	attrs  map[string]any
+	err    error
+	ended  bool
+}
+
+func (s *recordedSpan) SpanContext() SpanContext           { return s.sc }
+func (s *recordedSpan) SetAttribute(key string, value any) { s.attrs[key] = value }
+func (s *recordedSpan) End(err error)                      { s.err, s.ended = err, true }
+
+type spanKey struct{}
+
+// recordingTracer is a Tracer recording all of the spans started.
+type recordingTracer struct {
+	mtx   sync.Mutex
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	s := &recordedSpan{name: name, attrs: make(map[string]any)}
+	s.sc.SpanID[0] = byte(len(t.spans) + 1)
+	s.sc.TraceID[0] = 1
+	s.sc.Sampled = true
+
+	if p, ok := ctx.Value(spanKey{}).(*recordedSpan); ok {
+		s.parent = p.sc
+	}
+
+	t.spans = append(t.spans, s)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func TestTracing(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"success":true,"data":{"otherPositionRetList":[{"symbol":"BTCUSDT","amount":1}]}}`))
+	}))
+	defer api.Close()
+
+	tr := &recordingTracer{}
+	u := NewUser("A", WithAPIBase(api.URL), WithTracer(tr))
+	u.firstFetch = false
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cp, _ := u.SubscribePositions(ctx)
+	p := <-cp
+
+	tr.mtx.Lock()
+	defer tr.mtx.Unlock()
+
+	require.GreaterOrEqual(t, len(tr.spans), 3)
+	poll, req, pos := tr.spans[0], tr.spans[1], tr.spans[2]
+
+	require.Equal(t, SpanPoll, poll.name)
+	require.Equal(t, "A", poll.attrs[AttrUID])
+
+	require.Equal(t, SpanRequest, req.name)
+	require.Equal(t, poll.sc, req.parent)
+	require.True(t, req.ended)
+	require.Equal(t, "getOtherPosition", req.attrs[AttrEndpoint])
+	require.Equal(t, http.StatusOK, req.attrs[AttrStatusCode])
+
+	require.Equal(t, SpanPosition, pos.name)
+	require.Equal(t, poll.sc, pos.parent)
+	require.Equal(t, "BTCUSDT", pos.attrs[AttrTicker])
+	require.Equal(t, "opened", pos.attrs[AttrPositionType])
+
+	// the position carries the trace context of its span
+	sc, err := ParseTraceParent(p.TraceParent)
+	require.NoError(t, err)
+	require.Equal(t, pos.sc, sc)
+}
+
+func TestParseTraceParent(t *testing.T) {
+	tests := []struct {
+		in        string
+		assertion require.ErrorAssertionFunc
+	}{
+		{in: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", assertion: require.NoError},
+		{in: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", assertion: require.NoError},
+		{in: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", assertion: require.Error},
+		{in: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", assertion: require.Error},
+		{in: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", assertion: require.Error},
+		{in: "", assertion: require.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			sc, err := ParseTraceParent(tt.in)
+			tt.assertion(t, err)
+			if err == nil {
+				require.Equal(t, tt.in, sc.TraceParent())
+			}
+		})
+	}
+}
@@ -18,10 +18,27 @@ type User struct {
 	delay   time.Duration     // duration between requests updating current positions
 	headers map[string]string // headers
 
-	positions  map[string]Position // map of positions user is currently in
-	client     *http.Client        // http client
-	log        *log.Logger         // Logger
-	firstFetch bool                // indicating first fetch
+	posMtx          sync.RWMutex        // Synchronization for positions
+	positions       map[string]Position // map of positions user is currently in
+	client          *http.Client        // http client
+	observer        Observer            // observer notified about requests, polls and events
+	tracer          Tracer              // tracer starting spans around requests, polls and events
+	mws             []Middleware        // middlewares wrapping every request
+	jitter          time.Duration       // maximum random delay added to every poll
+	errPolicy       ErrorPolicy         // what happens to errors nobody's receiving
+	errBuffer       int                 // size of the error buffer when using BufferErrors
+	sharingCheck    time.Duration       // how often subscriptions check whether or not positions are shared, 0 disables it
+	initialSnapshot bool                // whether or not subscriptions start with a snapshot of the open positions
+	profileRefresh  time.Duration       // duration between requests checking user's profile, see WatchProfile
+	log             *log.Logger         // Logger
+	firstFetch      bool                // indicating first fetch
+	releases        []func()            // called once the user isn't used anymore, see release
+
+	hMtx               sync.RWMutex        // Synchronization for handlers and errHandler
+	handlers           []registeredHandler // position handlers, see OnPosition
+	snapshotHandlers   []SnapshotHandler   // snapshot handlers, see OnSnapshot
+	errHandler         ErrorHandler        // error handler, see OnError
+	concurrentHandlers bool                // whether or not handlers of different tickers run concurrently
 }
 
 type UserOption func(*User)
@@ -29,14 +46,19 @@ type UserOption func(*User)
 // NewUser creates a new User with his encrypted UserID.
 func NewUser(UID string, opts ...UserOption) *User {
 	u := User{
-		UID:        UID,
-		log:        logger,
-		positions:  make(map[string]Position),
-		delay:      time.Second * 5,
-		client:     http.DefaultClient,
-		firstFetch: true,
-		headers:    defaultHeaders,
-		apiBase:    defaultApiBase,
+		UID:            UID,
+		log:            logger,
+		positions:      make(map[string]Position),
+		delay:          time.Second * 5,
+		profileRefresh: 5 * time.Minute,
+		client:         http.DefaultClient,
+		observer:       nopObserver{},
+		tracer:         nopTracer{},
+		errPolicy:      BufferErrors,
+		errBuffer:      16,
+		firstFetch:     true,
+		headers:        defaultHeaders,
+		apiBase:        defaultApiBase,
 	}
 
 	// disable logging by default
@@ -110,6 +132,43 @@ func (u *User) Headers() map[string]string {
 	return headers
 }
 
+// request creates a new request to the endpoint and path on user's API base.
+func (u *User) request(endpoint, path string, body any) request {
+	return request{
+		client:      u.client,
+		observer:    u.observer,
+		tracer:      u.tracer,
+		middlewares: u.mws,
+		UID:         u.UID,
+		endpoint:    u.APIBase() + endpoint,
+		path:        path,
+		headers:     u.Headers(),
+		body:        body,
+	}
+}
+
+// Positions returns the positions user is currently in, as of the latest fetch of a running subscription.
+func (u *User) Positions() []Position {
+	u.posMtx.RLock()
+	defer u.posMtx.RUnlock()
+
+	ps := make([]Position, 0, len(u.positions))
+	for _, p := range u.positions {
+		ps = append(ps, p)
+	}
+
+	return ps
+}
+
+// Synced returns whether or not user's positions were fetched since the subscription started or was last
+// resynchronized, i.e. whether or not Positions reflects them.
+func (u *User) Synced() bool {
+	u.posMtx.RLock()
+	defer u.posMtx.RUnlock()
+
+	return !u.firstFetch
+}
+
 // WithCustomLogger writes all user logs using the logger provided.
 func WithCustomLogger(l *log.Logger) UserOption {
 	return func(u *User) {
@@ -131,6 +190,63 @@ func WithCustomRefresh(d time.Duration) UserOption {
 	}
 }
 
+// WithJitter adds a random delay of up to d to every poll of user's positions,
+// without shifting the cadence of the polls.
+func WithJitter(d time.Duration) UserOption {
+	return func(u *User) {
+		u.jitter = d
+	}
+}
+
+// WithErrorPolicy sets what happens to subscription errors nobody's receiving.
+func WithErrorPolicy(p ErrorPolicy) UserOption {
+	return func(u *User) {
+		u.errPolicy = p
+	}
+}
+
+// WithErrorBuffer buffers up to n subscription errors nobody's receiving, dropping any further ones.
+func WithErrorBuffer(n int) UserOption {
+	return func(u *User) {
+		u.errPolicy = BufferErrors
+		u.errBuffer = n
+	}
+}
+
+// WithSharingCheck makes subscriptions check whether or not user is sharing their positions every d.
+// While user isn't sharing, positions aren't fetched, so they don't appear as closed, and once user starts
+// sharing again, their positions are resynchronized without being reported as opened.
+func WithSharingCheck(d time.Duration) UserOption {
+	return func(u *User) {
+		u.sharingCheck = d
+	}
+}
+
+// WithInitialSnapshot makes every subscription start with a Snapshot of all positions that are currently open,
+// instead of silently dropping them. Positions of the snapshot have the Existing type.
+//
+// SubscribePositions sends the positions of the snapshot one by one, before any position changes.
+func WithInitialSnapshot() UserOption {
+	return func(u *User) {
+		u.initialSnapshot = true
+	}
+}
+
+// WithProfileRefresh sets the duration between requests checking user's profile, see WatchProfile.
+func WithProfileRefresh(d time.Duration) UserOption {
+	return func(u *User) {
+		u.profileRefresh = d
+	}
+}
+
+// WithConcurrentHandlers runs position handlers of different tickers concurrently,
+// positions of the same ticker are still handled in order.
+func WithConcurrentHandlers() UserOption {
+	return func(u *User) {
+		u.concurrentHandlers = true
+	}
+}
+
 // WithHTTPClient sets user's HTTP Client.
 func WithHTTPClient(c *http.Client) UserOption {
 	return func(u *User) {
@@ -138,6 +254,13 @@ func WithHTTPClient(c *http.Client) UserOption {
 	}
 }
 
+// WithProxyPool sends all of user's requests through the proxy pool passed in.
+func WithProxyPool(pp *ProxyPool) UserOption {
+	return func(u *User) {
+		u.client = &http.Client{Transport: pp}
+	}
+}
+
 // WithHeaders sets headers the client uses for every request.
 func WithHeaders(h map[string]string) UserOption {
 	return func(u *User) {
@@ -145,6 +268,55 @@ func WithHeaders(h map[string]string) UserOption {
 	}
 }
 
+// WithAPIBase sets the API base used for requests.
+func WithAPIBase(s string) UserOption {
+	return func(u *User) {
+		u.apiBase = s
+	}
+}
+
+// WithObserver sets an observer notified about every request, poll and event of the user.
+func WithObserver(o Observer) UserOption {
+	return func(u *User) {
+		u.observer = o
+	}
+}
+
+// release releases everything the user was registered with by its options, e.g. a Scheduler.
+func (u *User) release() {
+	for _, fn := range u.releases {
+		fn()
+	}
+}
+
+// WithScheduler lets the scheduler adapt the delay between user's position refreshes,
+// overriding any refresh set before. The scheduler is added to user's observers, so any
+// observer should be set before this option.
+//
+// Users removed from a Watcher are untracked automatically, other users have to be untracked
+// with Scheduler.Untrack once they aren't used anymore.
+func WithScheduler(s *Scheduler) UserOption {
+	return func(u *User) {
+		u.observer = MultiObserver(u.observer, s)
+		u.releases = append(u.releases, func() { s.Untrack(u.UID) })
+		s.Track(u)
+	}
+}
+
+// WithTracer sets a tracer starting spans around every request, poll and event of the user.
+func WithTracer(t Tracer) UserOption {
+	return func(u *User) {
+		u.tracer = t
+	}
+}
+
+// WithMiddleware appends middlewares wrapping every request of the user.
+func WithMiddleware(mws ...Middleware) UserOption {
+	return func(u *User) {
+		u.mws = append(u.mws, mws...)
+	}
+}
+
 // WithTestnet uses the testnet API
 func WithTestnet() UserOption {
 	return func(u *User) {
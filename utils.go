@@ -2,54 +2,34 @@ package bfldb
 
 import (
 	"context"
-
-	"golang.org/x/sync/errgroup"
 )
 
-type aggregatedNickname struct {
-	Nickname string   // Nickmane this struct belongs to
-	UIDs     []string // User IDs associated with the nickname
-}
-
 // NicknamesToUIDs gets a list of UIDs for the nicknames provided.
 // Returns a map with nicknames mapped to the UIDs and also any errors that might've occured.
 //
-// It fires up one goroutine for each nickname and fetches the UIDs.
-func NicknamesToUIDs(pCtx context.Context, nicks []string) (map[string][]string, error) {
-	idC := make(chan aggregatedNickname)
-	g, ctx := errgroup.WithContext(pCtx)
-
-	for _, n := range nicks {
-		n := n
-		g.Go(func() error {
-			res, err := SearchNickname(ctx, n)
-
-			if err == nil {
-
-				// map the response to the UIDs only
-				nIds := make([]string, 0, len(res.Data))
-				for _, data := range res.Data {
-					nIds = append(nIds, data.EncryptedUID)
-				}
-
-				idC <- aggregatedNickname{Nickname: n, UIDs: nIds}
-			}
-
-			return err
-		})
+// Nicknames are searched for in a batch, see Client.SearchNicknames for the options. Nicknames that couldn't be
+// searched for are left out of the map and returned as a BatchError, along with the UIDs of all the other ones.
+func NicknamesToUIDs(ctx context.Context, nicks []string, opts ...BatchOption) (map[string][]string, error) {
+	uids := make(map[string][]string, len(nicks))
+	failed := make(BatchError)
+
+	for _, res := range NewClient().SearchNicknames(ctx, nicks, opts...) {
+		if res.Err != nil {
+			failed[res.Nickname] = res.Err
+			continue
+		}
+
+		// map the response to the UIDs only
+		ids := make([]string, 0, len(res.Users))
+		for _, u := range res.Users {
+			ids = append(ids, u.EncryptedUID)
+		}
+		uids[res.Nickname] = ids
 	}
 
-	// close the channel once the requests are done
-	go func() {
-		g.Wait()
-		close(idC)
-	}()
-
-	// agregate it into a map of usernames to the ids
-	aRes := make(map[string][]string, len(nicks))
-	for id := range idC {
-		aRes[id.Nickname] = id.UIDs
+	if len(failed) > 0 {
+		return uids, failed
 	}
 
-	return aRes, g.Wait()
+	return uids, nil
 }
@@ -0,0 +1,198 @@
+package bfldb
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAlreadyWatched = errors.New("user is already being watched")
+	ErrNotWatched     = errors.New("user is not being watched")
+)
+
+// WatchEvent is a position change or an error of one of the users watched by a Watcher.
+type WatchEvent struct {
+	UID      string    // Encrypted UID of the user the event belongs to
+	Time     time.Time // Time the event was received at
+	Position Position  // Position change, empty if Err is set
+	Err      error     // Error that occured during the subscription
+}
+
+// Watcher subscribes to positions of multiple users and merges their updates into a single channel.
+//
+// Users can be added and removed at any time, including while the Watcher is running.
+type Watcher struct {
+	mtx    sync.RWMutex            // Synchronization for users and ctx
+	client *Client                 // client used to create new users
+	opts   []UserOption            // options applied to every new user
+	users  map[string]*watchedUser // watched users, mapped by their UID
+	ctx    context.Context         // context of the running watcher, nil if it's not running
+
+	wg     sync.WaitGroup  // running subscriptions
+	events chan WatchEvent // merged events of all users
+}
+
+// watchedUser is a single user watched by a Watcher.
+type watchedUser struct {
+	user   *User              // the user itself
+	cancel context.CancelFunc // cancels user's subscription, nil if not subscribed
+}
+
+// NewWatcher creates a new Watcher, creating its users with the client and options passed in.
+//
+// If c is nil, a new Client with the default configuration is used.
+func NewWatcher(c *Client, opts ...UserOption) *Watcher {
+	if c == nil {
+		c = NewClient()
+	}
+
+	return &Watcher{
+		client: c,
+		opts:   opts,
+		users:  make(map[string]*watchedUser),
+		events: make(chan WatchEvent),
+	}
+}
+
+// Events returns the channel all position changes and errors are sent through.
+//
+// The channel is closed once Run returns.
+func (w *Watcher) Events() <-chan WatchEvent {
+	return w.events
+}
+
+// Run subscribes to the positions of all users and blocks until the context is cancelled.
+// Users added while Run is in progress are subscribed to right away.
+//
+// Run should only be called once.
+func (w *Watcher) Run(ctx context.Context) error {
+	w.mtx.Lock()
+	w.ctx = ctx
+	for _, wu := range w.users {
+		w.subscribe(wu)
+	}
+	w.mtx.Unlock()
+
+	<-ctx.Done()
+
+	w.mtx.Lock()
+	w.ctx = nil
+	w.mtx.Unlock()
+
+	// all subscriptions are derived from ctx, so they are all finishing now
+	w.wg.Wait()
+	close(w.events)
+
+	return ctx.Err()
+}
+
+// Add starts watching the user with the UID passed in.
+func (w *Watcher) Add(UID string) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if _, ok := w.users[UID]; ok {
+		return ErrAlreadyWatched
+	}
+
+	wu := &watchedUser{user: w.client.NewUser(UID, w.opts...)}
+	w.users[UID] = wu
+
+	if w.ctx != nil {
+		w.subscribe(wu)
+	}
+
+	return nil
+}
+
+// Remove stops watching the user with the UID passed in, untracking it from its Scheduler, if any.
+func (w *Watcher) Remove(UID string) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	wu, ok := w.users[UID]
+	if !ok {
+		return ErrNotWatched
+	}
+
+	if wu.cancel != nil {
+		wu.cancel()
+	}
+	wu.user.release()
+	delete(w.users, UID)
+
+	return nil
+}
+
+// UIDs returns UIDs of all watched users, sorted.
+func (w *Watcher) UIDs() []string {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+
+	uids := make([]string, 0, len(w.users))
+	for uid := range w.users {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+
+	return uids
+}
+
+// User returns the watched user with the UID passed in.
+func (w *Watcher) User(UID string) (*User, bool) {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+
+	wu, ok := w.users[UID]
+	if !ok {
+		return nil, false
+	}
+
+	return wu.user, true
+}
+
+// subscribe subscribes to user's positions and forwards them to the events channel.
+// w.mtx has to be held by the caller.
+func (w *Watcher) subscribe(wu *watchedUser) {
+	ctx, cancel := context.WithCancel(w.ctx)
+	wu.cancel = cancel
+
+	cp, ce := wu.user.SubscribePositions(ctx)
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer cancel()
+
+		// keep reading until both channels are closed, so the subscription never gets stuck sending
+		for cp != nil || ce != nil {
+			ev := WatchEvent{UID: wu.user.UID}
+
+			select {
+			case p, ok := <-cp:
+				if !ok {
+					cp = nil
+					continue
+				}
+				ev.Position = p
+			case err, ok := <-ce:
+				if !ok {
+					ce = nil
+					continue
+				}
+				ev.Err = err
+			}
+
+			ev.Time = time.Now()
+
+			select {
+			case w.events <- ev:
+			case <-ctx.Done():
+				// user was removed or the watcher stopped, nobody's interested anymore
+			}
+		}
+	}()
+}
@@ -0,0 +1,57 @@
+package bfldb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher(t *testing.T) {
+	var calls int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// no positions on the first fetch, one opened position afterwards
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Write([]byte(`{"success":true,"data":{"otherPositionRetList":[]}}`))
+			return
+		}
+		w.Write([]byte(`{"success":true,"data":{"otherPositionRetList":[{"symbol":"BTCUSDT","amount":1,"leverage":10}]}}`))
+	}))
+	defer api.Close()
+
+	w := NewWatcher(NewClient(WithClientAPIBase(api.URL)), WithCustomRefresh(time.Millisecond))
+	require.NoError(t, w.Add("A"))
+	require.ErrorIs(t, w.Add("A"), ErrAlreadyWatched)
+	require.Equal(t, []string{"A"}, w.UIDs())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- w.Run(ctx)
+	}()
+
+	ev := <-w.Events()
+	require.NoError(t, ev.Err)
+	require.Equal(t, "A", ev.UID)
+	require.Equal(t, Position{Type: Opened, Direction: Long, Ticker: "BTCUSDT", Amount: 1, Leverage: 10}, ev.Position)
+
+	u, ok := w.User("A")
+	require.True(t, ok)
+	require.Len(t, u.Positions(), 1)
+	require.True(t, u.Synced())
+	require.False(t, NewUser("B").Synced())
+
+	require.NoError(t, w.Remove("A"))
+	require.ErrorIs(t, w.Remove("A"), ErrNotWatched)
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+
+	// events channel gets closed once the watcher stops
+	for range w.Events() {
+	}
+}